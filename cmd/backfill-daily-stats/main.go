@@ -0,0 +1,49 @@
+// backfill-daily-stats 由 rent 重建指定日期區間的每日彙總（lot_daily_stat）
+//
+//	go run ./cmd/backfill-daily-stats -from 2024-01-01 -to 2024-12-31
+package main
+
+import (
+	"flag"
+	"log"
+	"project01/database"
+	"project01/models"
+	"project01/services"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	fromStr := flag.String("from", "", "起始日期 YYYY-MM-DD（必填）")
+	toStr := flag.String("to", yesterday, "結束日期 YYYY-MM-DD（含），預設為昨天")
+	flag.Parse()
+
+	if *fromStr == "" {
+		log.Fatalf("-from is required")
+	}
+	cstZone := time.FixedZone("CST", 8*60*60)
+	from, err := time.ParseInLocation("2006-01-02", *fromStr, cstZone)
+	if err != nil {
+		log.Fatalf("Invalid -from %q, expected YYYY-MM-DD", *fromStr)
+	}
+	to, err := time.ParseInLocation("2006-01-02", *toStr, cstZone)
+	if err != nil {
+		log.Fatalf("Invalid -to %q, expected YYYY-MM-DD", *toStr)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env file found, using default environment variables: %v", err)
+	}
+	database.InitDB()
+	if err := database.DB.AutoMigrate(&models.LotDailyStat{}); err != nil {
+		log.Fatalf("Failed to migrate lot_daily_stat: %v", err)
+	}
+
+	rows, err := services.RebuildDailyStats(from, to)
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+	log.Printf("Backfill completed: %d rows from %s to %s", rows, *fromStr, *toStr)
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"log"
+	"strings"
+)
+
+// SpatialEnabled 表示 parking_lot.location 空間欄位與索引是否可用
+var SpatialEnabled bool
+
+// locationColumnDef parking_lot.location 的欄位定義（經度在前）
+const locationColumnDef = "POINT SRID 4326 " +
+	"AS (ST_SRID(POINT(COALESCE(longitude, 0), COALESCE(latitude, 0)), 4326)) STORED NOT NULL"
+
+// EnsureParkingLotLocation 建立 parking_lot.location（由經緯度產生的 POINT SRID 4326）與空間索引
+// 失敗時僅記錄錯誤，附近查詢會退回哈弗辛計算
+func EnsureParkingLotLocation() {
+	SpatialEnabled = false
+
+	// 產生欄位須為 NOT NULL 才能建立空間索引；缺少經緯度的停車場以 POINT(0 0) 佔位，
+	// 原欄位保持 NULL，查詢時以 latitude/longitude IS NOT NULL 排除
+	if !DB.Migrator().HasColumn("parking_lot", "location") {
+		err := DB.Exec("ALTER TABLE parking_lot ADD COLUMN location " + locationColumnDef).Error
+		if err != nil {
+			log.Printf("Failed to add parking_lot.location column: %v", err)
+			return
+		}
+		log.Println("Added parking_lot.location column")
+	} else {
+		// 舊版欄位未處理 NULL 經緯度，改為新的產生式
+		var expr string
+		if err := DB.Raw("SELECT GENERATION_EXPRESSION FROM information_schema.COLUMNS " +
+			"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'parking_lot' AND COLUMN_NAME = 'location'").
+			Scan(&expr).Error; err != nil {
+			log.Printf("Failed to check parking_lot.location column: %v", err)
+			return
+		}
+		if !strings.Contains(strings.ToLower(expr), "coalesce") {
+			if err := DB.Exec("ALTER TABLE parking_lot MODIFY COLUMN location " + locationColumnDef).Error; err != nil {
+				log.Printf("Failed to update parking_lot.location column: %v", err)
+				return
+			}
+			log.Println("Updated parking_lot.location column to keep null coordinates")
+		}
+	}
+
+	if !DB.Migrator().HasIndex("parking_lot", "idx_parking_lot_location") {
+		if err := DB.Exec("CREATE SPATIAL INDEX idx_parking_lot_location ON parking_lot (location)").Error; err != nil {
+			log.Printf("Failed to create spatial index on parking_lot.location: %v", err)
+			return
+		}
+		log.Println("Created spatial index idx_parking_lot_location")
+	}
+
+	SpatialEnabled = true
+	log.Println("Spatial index for parking lot search is enabled")
+}
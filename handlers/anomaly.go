@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveAnomalyInput 處理異常的輸入結構體
+type ResolveAnomalyInput struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+// GetAnomalies 查詢異常清單 (admin only)
+func GetAnomalies(c *gin.Context) {
+	filter := services.AnomalyFilter{
+		Type:   c.Query("type"),
+		Status: c.DefaultQuery("status", "open"),
+	}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+	if lotIDStr := c.Query("parking_lot_id"); lotIDStr != "" {
+		lotID, err := strconv.Atoi(lotIDStr)
+		if err != nil || lotID <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_id", "")
+			return
+		}
+		filter.ParkingLotID = lotID
+	}
+
+	anomalies, err := services.GetAnomalies(filter)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", anomalies)
+}
+
+// ResolveAnomaly 標記異常為已處理 (admin only)
+func ResolveAnomaly(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的異常ID", "")
+		return
+	}
+
+	// note 為選填，允許不帶 body
+	var input ResolveAnomalyInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	anomaly, err := services.ResolveAnomaly(id, input.Note, c.GetInt("member_id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			ErrorResponse(c, http.StatusNotFound, "異常不存在", err.Error())
+		} else if strings.Contains(err.Error(), "already resolved") {
+			ErrorResponse(c, http.StatusConflict, "異常已處理", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "處理失敗", err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "處理成功", anomaly)
+}
+
+// RunAnomalyDetection 立即執行一次異常偵測 (admin only)
+func RunAnomalyDetection(c *gin.Context) {
+	services.RunAnomalyDetection()
+	SuccessResponse(c, http.StatusOK, "異常偵測已執行", nil)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project01/models"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginResponse 登入成功時回傳的 token 與會員資料
+func loginResponse(tokens *services.TokenPair, member *models.Member) gin.H {
+	return gin.H{
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.AccessExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"member":             member.ToResponse(),
+	}
+}
+
+// RefreshToken 以 refresh token 換發新的 access token 與 refresh token
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	tokens, err := services.RefreshTokenPair(input.RefreshToken, c.ClientIP())
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "2fa setup required"):
+			ErrorResponse(c, http.StatusForbidden, "管理員須啟用兩步驟驗證，請重新登入並完成設定", msg, "ERR_2FA_SETUP_REQUIRED")
+		case strings.Contains(msg, "expired"):
+			ErrorResponse(c, http.StatusUnauthorized, "refresh token 已過期，請重新登入", msg)
+		case strings.Contains(msg, "invalid") || strings.Contains(msg, "revoked"):
+			ErrorResponse(c, http.StatusUnauthorized, "無效的 refresh token，請重新登入", msg)
+		default:
+			ErrorResponse(c, http.StatusInternalServerError, "換發 token 失敗", msg)
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "換發成功", tokens)
+}
+
+// Logout 登出目前裝置，撤銷目前的 access token 與其 refresh token
+func Logout(c *gin.Context) {
+	memberID := c.GetInt("member_id")
+	expiresAt, _ := c.Get("token_exp")
+	exp, _ := expiresAt.(time.Time)
+
+	if err := services.Logout(memberID, c.GetString("jti"), c.GetString("sid"), exp); err != nil {
+		log.Printf("Logout failed for member %d: %v", memberID, err)
+		ErrorResponse(c, http.StatusInternalServerError, "登出失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "登出成功", nil)
+}
+
+// LogoutAll 登出所有裝置，所有已簽發的 token 立即失效
+func LogoutAll(c *gin.Context) {
+	memberID := c.GetInt("member_id")
+	if err := services.RevokeAllMemberTokens(memberID); err != nil {
+		log.Printf("Logout all failed for member %d: %v", memberID, err)
+		ErrorResponse(c, http.StatusInternalServerError, "登出失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "已登出所有裝置", nil)
+}
+
+// sessionError 將工作階段相關錯誤對應到 HTTP 狀態碼
+func sessionError(c *gin.Context, message string, err error) {
+	msg := err.Error()
+	if strings.Contains(msg, "not found") {
+		ErrorResponse(c, http.StatusNotFound, "會員或工作階段不存在", msg)
+		return
+	}
+	ErrorResponse(c, http.StatusInternalServerError, message, msg)
+}
+
+// GetMySessions 查詢自己目前登入中的裝置
+func GetMySessions(c *gin.Context) {
+	sessions, err := services.GetMemberSessions(c.GetInt("member_id"), c.GetString("sid"))
+	if err != nil {
+		sessionError(c, "查詢失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", sessions)
+}
+
+// RevokeMySession 登出自己的某一個裝置
+func RevokeMySession(c *gin.Context) {
+	if err := services.RevokeMemberSession(c.GetInt("member_id"), c.Param("sid")); err != nil {
+		sessionError(c, "登出裝置失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "已登出該裝置", nil)
+}
+
+// GetMemberSessions 查詢特定會員登入中的裝置 (admin only)
+func GetMemberSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的會員ID", "")
+		return
+	}
+
+	// 管理員查詢的是其他會員的工作階段，不標記目前裝置
+	sessions, err := services.GetMemberSessions(id, "")
+	if err != nil {
+		sessionError(c, "查詢失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", sessions)
+}
+
+// RevokeMemberSession 強制登出特定會員的某一個裝置 (admin only)
+func RevokeMemberSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的會員ID", "")
+		return
+	}
+
+	if err := services.RevokeMemberSession(id, c.Param("sid")); err != nil {
+		sessionError(c, "登出裝置失敗", err)
+		return
+	}
+
+	log.Printf("Admin %d revoked session %s of member %d", c.GetInt("member_id"), c.Param("sid"), id)
+	SuccessResponse(c, http.StatusOK, "已登出該裝置", nil)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"project01/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDashboard 管理員首頁儀表板：各停車場與整體的佔用率、週轉率、停車時間、收入與尖峰時段 (admin only)
+// 未指定 start_date / end_date 時為最近 7 天（含今天）
+func GetDashboard(c *gin.Context) {
+	startStr, endStr := c.Query("start_date"), c.Query("end_date")
+	if startStr == "" && endStr == "" {
+		today := time.Now().In(time.FixedZone("CST", 8*60*60))
+		startStr = today.AddDate(0, 0, -6).Format("2006-01-02")
+		endStr = today.Format("2006-01-02")
+	}
+	startDate, endDate, err := parseDateRange(startStr, endStr)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的日期區間", err.Error())
+		return
+	}
+
+	lotIDs, err := parseLotIDs(c.Query("parking_lot_ids"))
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_ids", err.Error())
+		return
+	}
+
+	dashboard, err := services.GetDashboard(lotIDs, startDate, endDate, c.Query("refresh") == "true")
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", dashboard)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportContentTypes 各匯出格式的 Content-Type
+var exportContentTypes = map[string]string{
+	services.ExportFormatCSV:  "text/csv; charset=utf-8",
+	services.ExportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// parseExportFilter 解析 format、columns、start_date、end_date、parking_lot_ids
+func parseExportFilter(c *gin.Context, defaults []string) (services.ExportFilter, error) {
+	filter := services.ExportFilter{Format: c.DefaultQuery("format", services.ExportFormatCSV)}
+	if _, ok := exportContentTypes[filter.Format]; !ok {
+		return filter, fmt.Errorf("invalid export format %q, expected csv or xlsx", filter.Format)
+	}
+
+	startDate, endDate, err := parseDateRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		return filter, err
+	}
+	filter.StartDate, filter.EndDate = startDate, endDate
+
+	if filter.ParkingLotIDs, err = parseLotIDs(c.Query("parking_lot_ids")); err != nil {
+		return filter, err
+	}
+
+	if s := c.Query("columns"); s != "" {
+		for _, key := range strings.Split(s, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				filter.Columns = append(filter.Columns, key)
+			}
+		}
+	}
+	// 開始串流後就無法回傳 JSON 錯誤，欄位須先驗證
+	if _, err := services.ResolveExportColumns(filter.Columns, defaults); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// streamExport 設定下載標頭並串流輸出；串流中途失敗只能記錄並中斷連線
+func streamExport(c *gin.Context, name string, filter services.ExportFilter,
+	stream func(w gin.ResponseWriter, filter services.ExportFilter) (int, error)) {
+	filename := fmt.Sprintf("%s_%s_%s.%s", name,
+		filter.StartDate.Format("20060102"), filter.EndDate.Format("20060102"), filter.Format)
+	c.Header("Content-Type", exportContentTypes[filter.Format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	start := time.Now()
+	rows, err := stream(c.Writer, filter)
+	if err != nil {
+		log.Printf("EXPORT_FAILED | name=%s rows=%d err=%v", name, rows, err)
+		c.Abort()
+		return
+	}
+	log.Printf("EXPORT_DONE | name=%s format=%s rows=%d elapsed=%s", name, filter.Format, rows, time.Since(start))
+}
+
+// ExportParkingIncome 匯出收入明細為 CSV 或 XLSX (admin only)
+func ExportParkingIncome(c *gin.Context) {
+	filter, err := parseExportFilter(c, services.DefaultIncomeExportColumns)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的匯出條件", err.Error())
+		return
+	}
+
+	streamExport(c, "income", filter, func(w gin.ResponseWriter, f services.ExportFilter) (int, error) {
+		return services.StreamIncomeExport(w, f)
+	})
+}
+
+// ExportRentHistory 匯出租用紀錄；一般會員僅能匯出自己車輛的紀錄，管理員可匯出全部或指定 member_id
+func ExportRentHistory(c *gin.Context) {
+	filter, err := parseExportFilter(c, services.DefaultRentHistoryExportColumns)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的匯出條件", err.Error())
+		return
+	}
+
+	if c.GetString("role") == "admin" {
+		if s := c.Query("member_id"); s != "" {
+			memberID, err := strconv.Atoi(s)
+			if err != nil || memberID <= 0 {
+				ErrorResponse(c, http.StatusBadRequest, "無效的 member_id", "")
+				return
+			}
+			filter.MemberID = memberID
+		}
+	} else {
+		filter.MemberID = c.GetInt("member_id")
+		if filter.MemberID == 0 {
+			ErrorResponse(c, http.StatusUnauthorized, "未授權", "member_id not found")
+			return
+		}
+	}
+
+	streamExport(c, "rent_history", filter, func(w gin.ResponseWriter, f services.ExportFilter) (int, error) {
+		return services.StreamRentHistoryExport(w, f)
+	})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForecastAvailability 預測抵達時間的剩餘車位
+// 提供 parking_lot_id 查單一停車場，或提供 latitude、longitude（與選填 radius）查附近停車場
+func ForecastAvailability(c *gin.Context) {
+	arrivalStr := c.Query("arrival_time")
+	if arrivalStr == "" {
+		ErrorResponse(c, http.StatusBadRequest, "請提供 arrival_time", "")
+		return
+	}
+	arrival, err := parseTimeWithCST(arrivalStr)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的 arrival_time", err.Error())
+		return
+	}
+
+	if lotIDStr := c.Query("parking_lot_id"); lotIDStr != "" {
+		lotID, err := strconv.Atoi(lotIDStr)
+		if err != nil || lotID <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_id", "")
+			return
+		}
+
+		forecast, err := services.ForecastParkingLot(lotID, arrival)
+		if err != nil {
+			forecastError(c, err)
+			return
+		}
+		SuccessResponse(c, http.StatusOK, "預測成功", forecast)
+		return
+	}
+
+	latitude, errLat := strconv.ParseFloat(c.Query("latitude"), 64)
+	longitude, errLon := strconv.ParseFloat(c.Query("longitude"), 64)
+	if errLat != nil || errLon != nil || latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		ErrorResponse(c, http.StatusBadRequest, "請提供 parking_lot_id，或有效的 latitude 和 longitude", "")
+		return
+	}
+	radius := 0.0
+	if s := c.Query("radius"); s != "" {
+		radius, err = strconv.ParseFloat(s, 64)
+		if err != nil || radius < 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 radius", "")
+			return
+		}
+	}
+
+	forecasts, err := services.ForecastNearbyParkingLots(latitude, longitude, radius, arrival)
+	if err != nil {
+		forecastError(c, err)
+		return
+	}
+	SuccessResponse(c, http.StatusOK, "預測成功", gin.H{
+		"arrival_time": arrival.Format(time.RFC3339),
+		"lots":         forecasts,
+	})
+}
+
+// forecastError 預測錯誤對應
+func forecastError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		ErrorResponse(c, http.StatusNotFound, "停車場不存在", msg)
+	case strings.Contains(msg, "archived"):
+		ErrorResponse(c, http.StatusConflict, "停車場已停用", msg, "ERR_PARKING_LOT_ARCHIVED")
+	case strings.Contains(msg, "invalid arrival time"):
+		ErrorResponse(c, http.StatusBadRequest, "無效的 arrival_time", msg)
+	default:
+		ErrorResponse(c, http.StatusInternalServerError, "預測失敗", msg)
+	}
+}
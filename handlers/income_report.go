@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseDateRange 解析 start_date、end_date（YYYY-MM-DD，CST），end_date 含當天
+func parseDateRange(startStr, endStr string) (time.Time, time.Time, error) {
+	if startStr == "" || endStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_date and end_date are required")
+	}
+
+	cstZone := time.FixedZone("CST", 8*60*60)
+	startDate, err := time.ParseInLocation("2006-01-02", startStr, cstZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start_date %q, expected YYYY-MM-DD", startStr)
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", endStr, cstZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end_date %q, expected YYYY-MM-DD", endStr)
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date must not be before start_date")
+	}
+
+	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	return startDate, endDate, nil
+}
+
+// parseLotIDs 解析逗號分隔的 parking_lot_ids
+func parseLotIDs(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid parking_lot_ids: %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetIncomeReport 多停車場收入報表，依日／週／月與停車場分組 (admin only)
+func GetIncomeReport(c *gin.Context) {
+	startDate, endDate, err := parseDateRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的日期區間", err.Error())
+		return
+	}
+
+	lotIDs, err := parseLotIDs(c.Query("parking_lot_ids"))
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_ids", err.Error())
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", services.GroupByDay)
+	switch groupBy {
+	case services.GroupByDay, services.GroupByWeek, services.GroupByMonth:
+	default:
+		ErrorResponse(c, http.StatusBadRequest, "無效的 group_by", "group_by 應為 day、week 或 month")
+		return
+	}
+
+	report, err := services.GetIncomeReport(services.IncomeReportFilter{
+		ParkingLotIDs: lotIDs,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		GroupBy:       groupBy,
+	})
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", report)
+}
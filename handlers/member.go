@@ -6,13 +6,11 @@ import (
 	"project01/database"
 	"project01/models"
 	"project01/services"
-	"project01/utils"
 	"regexp"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // 電子郵件驗證 regex
@@ -139,13 +137,23 @@ func LoginMember(c *gin.Context) {
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"member_id": member.MemberID,
-		"role":      member.Role,
-		"exp":       time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// 已啟用兩步驟驗證（或政策要求）時，改為回傳第二步驟所需的 mfa_token
+	challenge, err := services.StartSecondFactor(member)
+	if err != nil {
+		log.Printf("Failed to start second factor for member %d: %v", member.MemberID, err)
+		ErrorResponse(c, http.StatusInternalServerError, "登入失敗，請稍後再試", err.Error())
+		return
+	}
+	if challenge != nil {
+		message := "請輸入兩步驟驗證碼"
+		if challenge.SetupRequired {
+			message = "管理員須先設定兩步驟驗證"
+		}
+		SuccessResponse(c, http.StatusOK, message, challenge)
+		return
+	}
 
-	tokenString, err := token.SignedString(utils.JWTSecret)
+	tokens, err := services.IssueTokenPair(member, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		ErrorResponse(c, http.StatusInternalServerError, "無法生成 token", err.Error())
@@ -153,10 +161,7 @@ func LoginMember(c *gin.Context) {
 	}
 
 	log.Printf("Member logged in successfully: email=%s, member_id=%d, role=%s", member.Email, member.MemberID, member.Role)
-	SuccessResponse(c, http.StatusOK, "登入成功", gin.H{
-		"token":  tokenString,
-		"member": member.ToResponse(),
-	})
+	SuccessResponse(c, http.StatusOK, "登入成功", loginResponse(tokens, member))
 }
 
 // 根據會員資料檢查
@@ -322,19 +327,26 @@ func GetMemberRentHistory(c *gin.Context) {
 		return
 	}
 
-	rents, err := services.GetMemberRentHistory(id)
+	filter, err := parseRentSearchFilter(c)
 	if err != nil {
-		log.Printf("Failed to get rent history for member %d: %v", id, err)
-		ErrorResponse(c, http.StatusInternalServerError, "查詢租賃歷史失敗", err.Error())
+		ErrorResponse(c, http.StatusBadRequest, "無效的查詢條件", err.Error())
 		return
 	}
 
-	// 將 rents 轉換為回應格式（假設 Rent 已有 ToResponse 方法）
-	rentResponses := make([]models.RentResponse, len(rents))
-	for i, rent := range rents {
-		rentResponses[i] = rent.ToResponse()
+	rents, nextCursor, err := services.GetMemberRentHistory(id, filter)
+	if err != nil {
+		log.Printf("Failed to get rent history for member %d: %v", id, err)
+		switch {
+		case strings.Contains(err.Error(), "invalid"):
+			ErrorResponse(c, http.StatusBadRequest, "查詢條件錯誤", err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			ErrorResponse(c, http.StatusNotFound, "會員不存在", err.Error())
+		default:
+			ErrorResponse(c, http.StatusInternalServerError, "查詢租賃歷史失敗", err.Error())
+		}
+		return
 	}
 
-	SuccessResponse(c, http.StatusOK, "查詢成功", rentResponses)
+	respondRentPage(c, rents, nextCursor)
 	log.Printf("Successfully retrieved rent history for member %d", id)
 }
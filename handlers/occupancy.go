@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOccupancySeries 查詢停車場佔用時間序列 (admin only)
+// from / to 預設為最近 24 小時，granularity 可為 5m、hourly、daily
+func GetOccupancySeries(c *gin.Context) {
+	lotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || lotID <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的停車場ID", "")
+		return
+	}
+
+	to := time.Now()
+	if s := c.Query("to"); s != "" {
+		if to, err = parseTimeWithCST(s); err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 to", err.Error())
+			return
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if s := c.Query("from"); s != "" {
+		if from, err = parseTimeWithCST(s); err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 from", err.Error())
+			return
+		}
+	}
+	granularity := c.DefaultQuery("granularity", services.GranularityHourly)
+
+	points, err := services.GetOccupancySeries(lotID, from, to, granularity)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", gin.H{
+		"parking_lot_id": lotID,
+		"granularity":    granularity,
+		"from":           from.Format(time.RFC3339),
+		"to":             to.Format(time.RFC3339),
+		"points":         points,
+	})
+}
+
+// GetOccupancyProfile 查詢停車場星期幾 × 小時的平均佔用 (admin only)
+func GetOccupancyProfile(c *gin.Context) {
+	lotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || lotID <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的停車場ID", "")
+		return
+	}
+
+	weeks := 8
+	if s := c.Query("weeks"); s != "" {
+		weeks, err = strconv.Atoi(s)
+		if err != nil || weeks <= 0 || weeks > 52 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 weeks，應為 1 到 52", "")
+			return
+		}
+	}
+
+	profile, err := services.GetOccupancyProfile(lotID, weeks)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", gin.H{
+		"parking_lot_id": lotID,
+		"weeks":          weeks,
+		"profile":        profile,
+	})
+}
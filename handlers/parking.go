@@ -4,12 +4,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"project01/database"
 	"project01/models"
 	"project01/services"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -70,9 +68,69 @@ func GetAvailableParkingLots(c *gin.Context) {
 		}
 	}
 
+	query := services.AvailableLotQuery{
+		Latitude:     latitude,
+		Longitude:    longitude,
+		Radius:       radius,
+		Type:         c.Query("type"),
+		VehicleClass: c.Query("vehicle_class"),
+		SortBy:       c.DefaultQuery("sort_by", services.SortByDistance),
+		Cursor:       c.Query("cursor"),
+	}
+	if query.Type != "" && query.Type != "flat" && query.Type != "mechanical" {
+		ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "type 應為 flat 或 mechanical")
+		return
+	}
+	if query.VehicleClass != "" && query.VehicleClass != "car" && query.VehicleClass != "scooter" {
+		ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "vehicle_class 應為 car 或 scooter")
+		return
+	}
+	switch query.SortBy {
+	case services.SortByDistance, services.SortByPrice, services.SortByRemaining:
+	default:
+		ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "sort_by 應為 distance、price 或 remaining")
+		return
+	}
+	if s := c.Query("max_hourly_rate"); s != "" {
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil || rate < 0 {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "無效的 max_hourly_rate")
+			return
+		}
+		query.MaxHourlyRate = &rate
+	}
+	if s := c.Query("min_free_spots"); s != "" {
+		spots, err := strconv.Atoi(s)
+		if err != nil || spots < 0 {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "無效的 min_free_spots")
+			return
+		}
+		query.MinFreeSpots = spots
+	}
+	if s := c.Query("open_now"); s != "" {
+		openNow, err := strconv.ParseBool(s)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "無效的 open_now")
+			return
+		}
+		query.OpenNow = openNow
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", "無效的 limit")
+			return
+		}
+		query.Limit = limit
+	}
+
 	// 調用服務層函數
-	parkingLots, err := services.GetAvailableParkingLots(latitude, longitude, radius)
+	parkingLots, nextCursor, err := services.GetAvailableParkingLots(query)
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid cursor") {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", err.Error())
+			return
+		}
 		log.Printf("Failed to get parking lots: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  false,
@@ -87,9 +145,10 @@ func GetAvailableParkingLots(c *gin.Context) {
 	if len(parkingLots) == 0 {
 		message := fmt.Sprintf("所選條件（經緯度：%s, %s）目前沒有符合的停車場！請調整篩選條件。", latitudeStr, longitudeStr)
 		c.JSON(http.StatusOK, gin.H{
-			"status":  false,
-			"message": message,
-			"data":    []models.ParkingLotResponse{},
+			"status":      false,
+			"message":     message,
+			"data":        []models.ParkingLotResponse{},
+			"next_cursor": "",
 		})
 		return
 	}
@@ -101,9 +160,10 @@ func GetAvailableParkingLots(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":  true,
-		"message": "查詢成功",
-		"data":    availableLotsResponse,
+		"status":      true,
+		"message":     "查詢成功",
+		"data":        availableLotsResponse,
+		"next_cursor": nextCursor,
 	})
 }
 
@@ -158,6 +218,10 @@ func CreateParkingLot(c *gin.Context) {
 
 	if err := services.CreateParkingLot(&lot); err != nil {
 		log.Printf("Failed to create parking lot: %v", err)
+		if isParkingLotSettingError(err) {
+			ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+			return
+		}
 		ErrorResponse(c, http.StatusInternalServerError, "新增停車場失敗", err.Error())
 		return
 	}
@@ -194,6 +258,28 @@ func UpdateParkingLot(c *gin.Context) {
 	if req.Latitude != nil {
 		updates["latitude"] = *req.Latitude
 	}
+	if req.AllowlistOnly != nil {
+		updates["allowlist_only"] = *req.AllowlistOnly
+	}
+	if req.StaleAfterHours != nil {
+		updates["stale_after_hours"] = *req.StaleAfterHours
+	}
+	if req.VehicleClasses != nil {
+		updates["vehicle_classes"] = *req.VehicleClasses
+	}
+	if req.OpenTime != nil {
+		updates["open_time"] = *req.OpenTime
+	}
+	if req.CloseTime != nil {
+		updates["close_time"] = *req.CloseTime
+	}
+	if req.ExternalRef != nil {
+		if *req.ExternalRef == "" {
+			updates["external_ref"] = nil
+		} else {
+			updates["external_ref"] = *req.ExternalRef
+		}
+	}
 
 	if len(updates) == 0 {
 		ErrorResponse(c, http.StatusBadRequest, "未提供任何更新字段", "")
@@ -202,6 +288,10 @@ func UpdateParkingLot(c *gin.Context) {
 
 	lot, err := services.UpdateParkingLot(id, updates)
 	if err != nil {
+		if isParkingLotSettingError(err) {
+			ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+			return
+		}
 		ErrorResponse(c, http.StatusInternalServerError, "更新失敗", err.Error())
 		return
 	}
@@ -209,7 +299,7 @@ func UpdateParkingLot(c *gin.Context) {
 	SuccessResponse(c, http.StatusOK, "更新成功", lot.ToResponse())
 }
 
-// DeleteParkingLot 刪除停車場 (admin only)
+// DeleteParkingLot 停用停車場 (admin only)，rent 紀錄保留供收入與歷史查詢
 func DeleteParkingLot(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -219,37 +309,80 @@ func DeleteParkingLot(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeleteParkingLot(id); err != nil {
-		log.Printf("Failed to delete parking lot with ID %d: %v", id, err)
-		if strings.Contains(err.Error(), "not found") {
-			ErrorResponse(c, http.StatusNotFound, "停車場不存在", err.Error())
-		} else {
-			ErrorResponse(c, http.StatusInternalServerError, "刪除停車場失敗", err.Error())
-		}
+	// 停用而非刪除，保留收入與歷史紀錄
+	lot, err := services.ArchiveParkingLot(id)
+	if err != nil {
+		log.Printf("Failed to archive parking lot with ID %d: %v", id, err)
+		parkingLotLifecycleError(c, "停用停車場失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "停車場已停用", lot.ToResponse())
+}
+
+// RestoreParkingLot 重新啟用已停用的停車場 (admin only)
+func RestoreParkingLot(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的停車場ID", err.Error())
+		return
+	}
+
+	lot, err := services.RestoreParkingLot(id)
+	if err != nil {
+		log.Printf("Failed to restore parking lot with ID %d: %v", id, err)
+		parkingLotLifecycleError(c, "重新啟用停車場失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "停車場已重新啟用", lot.ToResponse())
+}
+
+// PurgeParkingLot 永久刪除已停用且超過保留期限的停車場 (admin only)
+func PurgeParkingLot(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的停車場ID", err.Error())
 		return
 	}
 
-	SuccessResponse(c, http.StatusOK, "刪除成功", nil)
+	if err := services.PurgeParkingLot(id); err != nil {
+		log.Printf("Failed to purge parking lot with ID %d: %v", id, err)
+		parkingLotLifecycleError(c, "永久刪除停車場失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "停車場已永久刪除", nil)
+}
+
+// parkingLotLifecycleError 停用／重新啟用／永久刪除的錯誤對應
+func parkingLotLifecycleError(c *gin.Context, message string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		ErrorResponse(c, http.StatusNotFound, "停車場不存在", msg)
+	case strings.Contains(msg, "active parking sessions"),
+		strings.Contains(msg, "already archived"),
+		strings.Contains(msg, "not archived"),
+		strings.Contains(msg, "retention period not reached"):
+		ErrorResponse(c, http.StatusConflict, message, msg)
+	default:
+		ErrorResponse(c, http.StatusInternalServerError, message, msg)
+	}
 }
 
 // GetAllParkingLots 查詢所有停車場
 func GetAllParkingLots(c *gin.Context) {
-	var lots []models.ParkingLot
-	if err := database.DB.Find(&lots).Error; err != nil {
+	// 管理員可用 include_archived=true 一併列出已停用的停車場
+	includeArchived := c.GetString("role") == "admin" && c.Query("include_archived") == "true"
+
+	// 剩餘車位由 occupancy 一次計算
+	lots, err := services.GetAllParkingLots(includeArchived)
+	if err != nil {
 		ErrorResponse(c, http.StatusInternalServerError, "Failed to query parking lots", err.Error())
 		return
 	}
 
-	// 關鍵：每一筆都要計算剩餘車位！
-	for i := range lots {
-		available, _ := services.CheckParkingAvailability(lots[i].ParkingLotID)
-		remaining := int(available)
-		if remaining < 0 {
-			remaining = 0
-		}
-		lots[i].RemainingSpots = remaining
-	}
-
 	responses := make([]models.ParkingLotResponse, len(lots))
 	for i, lot := range lots {
 		responses[i] = lot.ToResponse()
@@ -276,17 +409,12 @@ func GetParkingIncome(c *gin.Context) {
 		return
 	}
 
-	startStr := c.Query("start_date")
-	endStr := c.Query("end_date")
-	if startStr == "" || endStr == "" {
-		ErrorResponse(c, http.StatusBadRequest, "請提供 start_date 和 end_date", "")
+	startDate, endDate, err := parseDateRange(c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的日期區間", err.Error())
 		return
 	}
 
-	startDate, _ := time.Parse("2006-01-02", startStr)
-	endDate, _ := time.Parse("2006-01-02", endStr)
-	endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-
 	report, err := services.GetParkingLotIncome(lotID, startDate, endDate)
 	if err != nil {
 		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
@@ -295,3 +423,9 @@ func GetParkingIncome(c *gin.Context) {
 
 	SuccessResponse(c, http.StatusOK, "查詢成功", report)
 }
+
+// isParkingLotSettingError 停車場設定（車種、營業時間）驗證失敗
+func isParkingLotSettingError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "invalid vehicle class") || strings.Contains(msg, "invalid opening time")
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"project01/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize 匯入檔大小上限（10MB）
+const maxImportFileSize = 10 << 20
+
+// ImportParkingLots 批次匯入停車場 (admin only)
+// 支援 multipart 欄位 file 或直接以 request body 上傳；format=csv|geojson，未指定時依副檔名或 Content-Type 判斷
+func ImportParkingLots(c *gin.Context) {
+	dryRun := false
+	if s := c.Query("dry_run"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 dry_run", err.Error())
+			return
+		}
+		dryRun = v
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	format := strings.ToLower(c.Query("format"))
+	var body io.Reader = c.Request.Body
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無法讀取上傳檔案", err.Error())
+			return
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = importFormatFromName(fileHeader.Filename)
+		}
+	}
+	if format == "" {
+		contentType := c.ContentType()
+		switch {
+		case strings.Contains(contentType, "csv"):
+			format = "csv"
+		case strings.Contains(contentType, "json"):
+			format = "geojson"
+		}
+	}
+
+	var (
+		rows []services.ParkingLotImportRow
+		err  error
+	)
+	switch format {
+	case "csv":
+		rows, err = services.ParseParkingLotCSV(body)
+	case "geojson":
+		rows, err = services.ParseParkingLotGeoJSON(body)
+	default:
+		ErrorResponse(c, http.StatusBadRequest, "無法判斷匯入格式", "format 應為 csv 或 geojson")
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "匯入檔格式錯誤", err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		ErrorResponse(c, http.StatusBadRequest, "匯入檔沒有資料", "")
+		return
+	}
+
+	result, err := services.ImportParkingLots(rows, dryRun)
+	if err != nil {
+		log.Printf("Failed to import parking lots: %v", err)
+		ErrorResponse(c, http.StatusInternalServerError, "匯入失敗", err.Error())
+		return
+	}
+
+	if result.Failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, APIResponse{
+			Status:  false,
+			Message: "部分資料驗證失敗，未寫入任何資料",
+			Data:    result,
+			Code:    "ERR_IMPORT_VALIDATION",
+		})
+		return
+	}
+
+	message := "匯入成功"
+	if dryRun {
+		message = "驗證成功（dry run，未寫入）"
+	}
+	SuccessResponse(c, http.StatusOK, message, result)
+}
+
+// ExportParkingLotsGeoJSON 匯出所有停車場為 GeoJSON（含即時剩餘車位）
+func ExportParkingLotsGeoJSON(c *gin.Context) {
+	fc, err := services.ExportParkingLotsGeoJSON()
+	if err != nil {
+		log.Printf("Failed to export parking lots: %v", err)
+		ErrorResponse(c, http.StatusInternalServerError, "匯出失敗", err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="parking_lots.geojson"`)
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, fc)
+}
+
+// importFormatFromName 依副檔名判斷匯入格式
+func importFormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".geojson", ".json":
+		return "geojson"
+	}
+	return ""
+}
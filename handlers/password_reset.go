@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"project01/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestPasswordReset 申請重設密碼；不論 email 是否已註冊都回傳相同訊息
+func RequestPasswordReset(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	if !emailRegex.MatchString(input.Email) {
+		ErrorResponse(c, http.StatusBadRequest, "請提供有效的電子郵件地址", "invalid email format")
+		return
+	}
+
+	services.RequestPasswordReset(input.Email, c.ClientIP())
+	SuccessResponse(c, http.StatusOK, "若此電子郵件已註冊，重設密碼的信件將寄送至該信箱", nil)
+}
+
+// ConfirmPasswordReset 以重設 token 設定新密碼
+func ConfirmPasswordReset(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	if err := services.ConfirmPasswordReset(input.Token, input.NewPassword); err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "password must be"):
+			ErrorResponse(c, http.StatusBadRequest, "密碼必須至少8個字符，包含字母和數字", msg)
+		case strings.Contains(msg, "invalid or expired"):
+			ErrorResponse(c, http.StatusBadRequest, "重設連結無效或已過期", msg)
+		default:
+			ErrorResponse(c, http.StatusInternalServerError, "重設密碼失敗", msg)
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "密碼已重設，請重新登入", nil)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project01/models"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlateListInput 新增黑白名單的輸入結構體
+type PlateListInput struct {
+	LicensePlate string `json:"license_plate" binding:"required"`
+	ParkingLotID *int   `json:"parking_lot_id" binding:"omitempty,min=1"` // 不填代表全域
+	ListType     string `json:"list_type" binding:"required,oneof=block allow"`
+	Reason       string `json:"reason" binding:"required,max=200"`
+	ExpiresAt    string `json:"expires_at"` // 可選，RFC 3339 或 YYYY-MM-DDThh:mm:ss
+}
+
+// CreatePlateListEntry 新增黑白名單 (admin only)
+func CreatePlateListEntry(c *gin.Context) {
+	var input PlateListInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	entry := models.PlateListEntry{
+		LicensePlate: input.LicensePlate,
+		ParkingLotID: input.ParkingLotID,
+		ListType:     input.ListType,
+		Reason:       input.Reason,
+		CreatedBy:    c.GetInt("member_id"),
+		CreatedAt:    time.Now(),
+	}
+	if input.ExpiresAt != "" {
+		expiresAt, err := parseTimeWithCST(input.ExpiresAt)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的到期時間", err.Error())
+			return
+		}
+		entry.ExpiresAt = &expiresAt
+	}
+
+	if err := services.CreatePlateListEntry(&entry); err != nil {
+		log.Printf("Failed to create plate list entry: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			ErrorResponse(c, http.StatusNotFound, "停車場不存在", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusBadRequest, "新增名單失敗", err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusCreated, "新增成功", entry)
+}
+
+// GetPlateListEntries 查詢黑白名單 (admin only)
+func GetPlateListEntries(c *gin.Context) {
+	filter := services.PlateListFilter{
+		LicensePlate:   c.Query("license_plate"),
+		ListType:       c.Query("list_type"),
+		IncludeExpired: c.Query("include_expired") == "true",
+	}
+	if filter.ListType != "" && filter.ListType != "block" && filter.ListType != "allow" {
+		ErrorResponse(c, http.StatusBadRequest, "無效的 list_type", "list_type must be 'block' or 'allow'")
+		return
+	}
+	if lotIDStr := c.Query("parking_lot_id"); lotIDStr != "" {
+		lotID, err := strconv.Atoi(lotIDStr)
+		if err != nil || lotID <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_id", "")
+			return
+		}
+		filter.ParkingLotID = &lotID
+	}
+
+	entries, err := services.GetPlateListEntries(filter)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", entries)
+}
+
+// DeletePlateListEntry 刪除黑白名單 (admin only)
+func DeletePlateListEntry(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的名單ID", "")
+		return
+	}
+
+	if err := services.DeletePlateListEntry(id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			ErrorResponse(c, http.StatusNotFound, "名單項目不存在", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "刪除失敗", err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "刪除成功", nil)
+}
+
+// GetEntryDenials 查詢拒絕進場紀錄 (admin only)
+func GetEntryDenials(c *gin.Context) {
+	filter := services.EntryDenialFilter{
+		LicensePlate: c.Query("license_plate"),
+	}
+	if lotIDStr := c.Query("parking_lot_id"); lotIDStr != "" {
+		lotID, err := strconv.Atoi(lotIDStr)
+		if err != nil || lotID <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_id", "")
+			return
+		}
+		filter.ParkingLotID = lotID
+	}
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := parseTimeWithCST(fromStr)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的起始時間", err.Error())
+			return
+		}
+		filter.From = &from
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := parseTimeWithCST(toStr)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的結束時間", err.Error())
+			return
+		}
+		filter.To = &to
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 limit", "")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	denials, err := services.GetEntryDenials(filter)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", denials)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project01/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecommendationInput 停車場推薦的輸入結構體
+type RecommendationInput struct {
+	Latitude        *float64                  `json:"latitude" binding:"required,gte=-90,lte=90"`
+	Longitude       *float64                  `json:"longitude" binding:"required,gte=-180,lte=180"`
+	DurationMinutes int                       `json:"duration_minutes" binding:"required,gte=1,lte=10080"`
+	ArrivalTime     string                    `json:"arrival_time"`
+	Preferences     RecommendationPreferences `json:"preferences"`
+	Limit           int                       `json:"limit" binding:"omitempty,gte=1,lte=50"`
+}
+
+// RecommendationPreferences 選填的推薦偏好
+type RecommendationPreferences struct {
+	MaxWalkKm          float64  `json:"max_walk_km" binding:"omitempty,gt=0,lte=5"`
+	MaxCost            *float64 `json:"max_cost" binding:"omitempty,gte=0"`
+	Type               string   `json:"type" binding:"omitempty,oneof=flat mechanical"`
+	VehicleClass       string   `json:"vehicle_class" binding:"omitempty,oneof=car scooter"`
+	DistanceWeight     float64  `json:"distance_weight" binding:"omitempty,gte=0"`
+	CostWeight         float64  `json:"cost_weight" binding:"omitempty,gte=0"`
+	AvailabilityWeight float64  `json:"availability_weight" binding:"omitempty,gte=0"`
+}
+
+// RecommendParkingLots 依出發地、停車時間與偏好推薦停車場
+func RecommendParkingLots(c *gin.Context) {
+	var input RecommendationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	query := services.RecommendationQuery{
+		Latitude:        *input.Latitude,
+		Longitude:       *input.Longitude,
+		DurationMinutes: input.DurationMinutes,
+		MaxWalkKm:       input.Preferences.MaxWalkKm,
+		MaxCost:         input.Preferences.MaxCost,
+		Type:            input.Preferences.Type,
+		VehicleClass:    input.Preferences.VehicleClass,
+		Weights: services.RecommendationWeights{
+			Distance:     input.Preferences.DistanceWeight,
+			Cost:         input.Preferences.CostWeight,
+			Availability: input.Preferences.AvailabilityWeight,
+		},
+		Limit: input.Limit,
+	}
+	if input.ArrivalTime != "" {
+		arrival, err := parseTimeWithCST(input.ArrivalTime)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 arrival_time", err.Error())
+			return
+		}
+		query.ArrivalTime = arrival
+	}
+
+	recommendations, err := services.RecommendParkingLots(query)
+	if err != nil {
+		log.Printf("Failed to recommend parking lots: %v", err)
+		if strings.Contains(err.Error(), "invalid") {
+			ErrorResponse(c, http.StatusBadRequest, "推薦失敗", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "推薦失敗", err.Error())
+		}
+		return
+	}
+
+	arrival := query.ArrivalTime
+	if arrival.IsZero() {
+		arrival = time.Now()
+	}
+	SuccessResponse(c, http.StatusOK, "推薦成功", gin.H{
+		"arrival_time":     arrival.Format(time.RFC3339),
+		"duration_minutes": input.DurationMinutes,
+		"recommendations":  recommendations,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"project01/models"
 	"project01/services"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,9 +23,15 @@ type RentInput struct {
 // LeaveInput 定義出場請求的輸入結構體
 type LeaveInput struct {
 	LicensePlate string `json:"license_plate" binding:"required"`
+	ParkingLotID int    `json:"parking_lot_id" binding:"omitempty,min=1"` // 可選，提供時辨識失敗會在該停車場模糊比對
 	EndTime      string `json:"end_time" binding:"required"`
 }
 
+// ResolveExitReviewInput 人工確認出場車牌的輸入結構體
+type ResolveExitReviewInput struct {
+	LicensePlate string `json:"license_plate" binding:"required"`
+}
+
 // NotificationInput 定義通知請求的輸入結構體
 type NotificationInput struct {
 	RentID int `json:"rent_id" binding:"required"`
@@ -70,7 +77,15 @@ func EnterParkingSpot(c *gin.Context) {
 
 	if err := services.EnterParkingSpot(input.LicensePlate, input.ParkingLotID, startTime); err != nil {
 		log.Printf("Failed to enter parking spot: license_plate=%s, error=%v", input.LicensePlate, err)
-		ErrorResponse(c, http.StatusInternalServerError, "進場失敗", err.Error())
+		if strings.Contains(err.Error(), "entry denied") {
+			ErrorResponse(c, http.StatusForbidden, "禁止進場", err.Error(), "ERR_ENTRY_DENIED")
+		} else if strings.Contains(err.Error(), "not verified") {
+			ErrorResponse(c, http.StatusForbidden, "車主尚未完成電子郵件與電話驗證", err.Error(), "ERR_MEMBER_NOT_VERIFIED")
+		} else if strings.Contains(err.Error(), "archived") {
+			ErrorResponse(c, http.StatusConflict, "停車場已停用", err.Error(), "ERR_PARKING_LOT_ARCHIVED")
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "進場失敗", err.Error())
+		}
 		return
 	}
 
@@ -92,10 +107,14 @@ func LeaveParkingSpot(c *gin.Context) {
 	}
 
 	// 改這裡！收到 rent 物件
-	rentRecord, err := services.LeaveParkingSpot(input.LicensePlate, endTime)
+	rentRecord, err := services.LeaveParkingSpot(input.LicensePlate, input.ParkingLotID, endTime)
 	if err != nil {
 		log.Printf("Leave parking failed: %v", err)
-		ErrorResponse(c, http.StatusBadRequest, "出場失敗", err.Error())
+		if strings.Contains(err.Error(), "pending attendant review") {
+			ErrorResponse(c, http.StatusConflict, "車牌辨識不確定，已送交人工確認", err.Error(), "ERR_EXIT_REVIEW_PENDING")
+		} else {
+			ErrorResponse(c, http.StatusBadRequest, "出場失敗", err.Error())
+		}
 		return
 	}
 
@@ -138,20 +157,25 @@ func GetRentRecordsByMember(c *gin.Context) {
 		return
 	}
 
-	// 直接用 member_id 去查所有車牌的租賃紀錄
-	rents, err := services.GetRentRecordsByMemberID(memberID)
+	filter, err := parseRentSearchFilter(c)
 	if err != nil {
-		log.Printf("Failed to get rent records for member %d: %v", memberID, err)
-		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		ErrorResponse(c, http.StatusBadRequest, "無效的查詢條件", err.Error())
 		return
 	}
 
-	responses := make([]models.RentResponse, len(rents))
-	for i, r := range rents {
-		responses[i] = r.ToResponse()
+	// 以 member_id 查詢名下所有車牌的租賃紀錄
+	rents, nextCursor, err := services.GetRentRecordsByMemberID(memberID, filter)
+	if err != nil {
+		log.Printf("Failed to get rent records for member %d: %v", memberID, err)
+		if strings.Contains(err.Error(), "invalid") {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		}
+		return
 	}
 
-	SuccessResponse(c, http.StatusOK, "查詢成功", responses)
+	respondRentPage(c, rents, nextCursor)
 }
 
 // GetTotalCostByLicensePlate 查詢總費用
@@ -208,3 +232,85 @@ func CheckParkingAvailability(c *gin.Context) {
 	}
 	SuccessResponse(c, http.StatusOK, "查詢成功", gin.H{"available_spots": availableSpots})
 }
+
+// GetExitReviews 查詢出場人工確認佇列 (admin only)
+func GetExitReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", "pending")
+	if status != "pending" && status != "resolved" && status != "rejected" && status != "all" {
+		ErrorResponse(c, http.StatusBadRequest, "無效的 status", "status must be pending, resolved, rejected or all")
+		return
+	}
+	if status == "all" {
+		status = ""
+	}
+
+	parkingLotID := 0
+	if lotIDStr := c.Query("parking_lot_id"); lotIDStr != "" {
+		var err error
+		parkingLotID, err = strconv.Atoi(lotIDStr)
+		if err != nil || parkingLotID <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 parking_lot_id", "")
+			return
+		}
+	}
+
+	reviews, err := services.GetExitReviews(status, parkingLotID)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", reviews)
+}
+
+// ResolveExitReview 人工指定正確車牌並完成出場 (admin only)
+func ResolveExitReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的確認項目ID", "")
+		return
+	}
+
+	var input ResolveExitReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	rentRecord, err := services.ResolveExitReview(id, input.LicensePlate, c.GetInt("member_id"))
+	if err != nil {
+		log.Printf("Failed to resolve exit review %d: %v", id, err)
+		if strings.Contains(err.Error(), "not found") {
+			ErrorResponse(c, http.StatusNotFound, "確認失敗", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusBadRequest, "確認失敗", err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "出場成功，本次停車費用已計算", map[string]interface{}{
+		"parking_record": rentRecord.ToResponse(),
+		"total_cost":     rentRecord.TotalCost,
+		"duration_hours": rentRecord.EndTime.Sub(rentRecord.StartTime).Hours(),
+	})
+}
+
+// RejectExitReview 駁回出場確認項目 (admin only)
+func RejectExitReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的確認項目ID", "")
+		return
+	}
+
+	if err := services.RejectExitReview(id, c.GetInt("member_id")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			ErrorResponse(c, http.StatusNotFound, "駁回失敗", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusBadRequest, "駁回失敗", err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "已駁回", nil)
+}
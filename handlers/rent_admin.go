@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RentKeyInput 以車牌與進場時間指定停車紀錄
+type RentKeyInput struct {
+	LicensePlate string `json:"license_plate" binding:"required"`
+	StartTime    string `json:"start_time" binding:"required"`
+	Reason       string `json:"reason" binding:"required,max=255"`
+}
+
+// ForceCloseRentInput 強制結束的輸入結構體
+type ForceCloseRentInput struct {
+	RentKeyInput
+	EndTime string `json:"end_time" binding:"required"`
+}
+
+// EditRentInput 修改進出場時間的輸入結構體
+type EditRentInput struct {
+	RentKeyInput
+	NewStartTime string `json:"new_start_time"`
+	NewEndTime   string `json:"new_end_time"`
+}
+
+// MoveRentInput 移動停車場的輸入結構體
+type MoveRentInput struct {
+	RentKeyInput
+	ParkingLotID int `json:"parking_lot_id" binding:"required,min=1"`
+}
+
+// toRentKey 解析紀錄主鍵
+func (in RentKeyInput) toRentKey() (services.RentKey, error) {
+	startTime, err := parseTimeWithCST(in.StartTime)
+	if err != nil {
+		return services.RentKey{}, err
+	}
+	return services.RentKey{LicensePlate: in.LicensePlate, StartTime: startTime}, nil
+}
+
+// ForceCloseRent 強制結束停車紀錄 (admin only)
+func ForceCloseRent(c *gin.Context) {
+	var input ForceCloseRentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	key, err := input.toRentKey()
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的開始時間", err.Error())
+		return
+	}
+	endTime, err := parseTimeWithCST(input.EndTime)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的結束時間", err.Error())
+		return
+	}
+
+	rent, err := services.ForceCloseRent(key, endTime, input.Reason, c.GetInt("member_id"))
+	if err != nil {
+		rentAdminError(c, "強制結束失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "強制結束成功", rent.ToResponse())
+}
+
+// EditRent 修改停車紀錄進出場時間 (admin only)
+func EditRent(c *gin.Context) {
+	var input EditRentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	key, err := input.toRentKey()
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的開始時間", err.Error())
+		return
+	}
+
+	var edit services.RentEdit
+	if input.NewStartTime != "" {
+		t, err := parseTimeWithCST(input.NewStartTime)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的新開始時間", err.Error())
+			return
+		}
+		edit.StartTime = &t
+	}
+	if input.NewEndTime != "" {
+		t, err := parseTimeWithCST(input.NewEndTime)
+		if err != nil {
+			ErrorResponse(c, http.StatusBadRequest, "無效的新結束時間", err.Error())
+			return
+		}
+		edit.EndTime = &t
+	}
+
+	rent, err := services.EditRent(key, edit, input.Reason, c.GetInt("member_id"))
+	if err != nil {
+		rentAdminError(c, "修改失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "修改成功", rent.ToResponse())
+}
+
+// VoidRent 作廢停車紀錄 (admin only)
+func VoidRent(c *gin.Context) {
+	var input RentKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	key, err := input.toRentKey()
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的開始時間", err.Error())
+		return
+	}
+
+	rent, err := services.VoidRent(key, input.Reason, c.GetInt("member_id"))
+	if err != nil {
+		rentAdminError(c, "作廢失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "作廢成功", rent.ToResponse())
+}
+
+// MoveRent 將停車紀錄移到其他停車場 (admin only)
+func MoveRent(c *gin.Context) {
+	var input MoveRentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	key, err := input.toRentKey()
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的開始時間", err.Error())
+		return
+	}
+
+	rent, err := services.MoveRent(key, input.ParkingLotID, input.Reason, c.GetInt("member_id"))
+	if err != nil {
+		rentAdminError(c, "移動失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "移動成功", rent.ToResponse())
+}
+
+// GetRentAudits 查詢停車紀錄稽核軌跡 (admin only)
+func GetRentAudits(c *gin.Context) {
+	filter := services.RentAuditFilter{
+		LicensePlate: c.Query("license_plate"),
+		Action:       c.Query("action"),
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 limit", "")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	audits, err := services.GetRentAudits(filter)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", audits)
+}
+
+// rentAdminError 依錯誤內容回傳對應狀態碼
+func rentAdminError(c *gin.Context, message string, err error) {
+	log.Printf("%s: %v", message, err)
+	if strings.Contains(err.Error(), "not found") {
+		ErrorResponse(c, http.StatusNotFound, message, err.Error())
+	} else if strings.Contains(err.Error(), "failed to") {
+		ErrorResponse(c, http.StatusInternalServerError, message, err.Error())
+	} else {
+		ErrorResponse(c, http.StatusBadRequest, message, err.Error())
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"project01/models"
+	"project01/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseRentSearchFilter 解析租用紀錄查詢參數：start_date、end_date（YYYY-MM-DD，可只給一邊）、
+// license_plate、parking_lot_id、status、cursor、limit
+func parseRentSearchFilter(c *gin.Context) (services.RentSearchFilter, error) {
+	filter := services.RentSearchFilter{
+		LicensePlate: strings.TrimSpace(c.Query("license_plate")),
+		Status:       c.Query("status"),
+		Cursor:       c.Query("cursor"),
+	}
+
+	cstZone := time.FixedZone("CST", 8*60*60)
+	if s := c.Query("start_date"); s != "" {
+		from, err := time.ParseInLocation("2006-01-02", s, cstZone)
+		if err != nil {
+			return filter, fmt.Errorf("invalid start_date %q, expected YYYY-MM-DD", s)
+		}
+		filter.From = &from
+	}
+	if s := c.Query("end_date"); s != "" {
+		to, err := time.ParseInLocation("2006-01-02", s, cstZone)
+		if err != nil {
+			return filter, fmt.Errorf("invalid end_date %q, expected YYYY-MM-DD", s)
+		}
+		to = to.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+		filter.To = &to
+	}
+
+	if s := c.Query("parking_lot_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("invalid parking_lot_id: %q", s)
+		}
+		filter.ParkingLotID = id
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > services.MaxRentPageSize {
+			return filter, fmt.Errorf("invalid limit: must be between 1 and %d", services.MaxRentPageSize)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// respondRentPage 回傳一頁租用紀錄與下一頁游標
+func respondRentPage(c *gin.Context, rents []models.Rent, nextCursor string) {
+	responses := make([]models.RentResponse, len(rents))
+	for i, r := range rents {
+		responses[i] = r.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      true,
+		"message":     "查詢成功",
+		"data":        responses,
+		"next_cursor": nextCursor,
+	})
+}
+
+// SearchRents 管理員查詢所有會員的租用紀錄，可再以 member_id 篩選 (admin only)
+func SearchRents(c *gin.Context) {
+	filter, err := parseRentSearchFilter(c)
+	if err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的查詢條件", err.Error())
+		return
+	}
+	if s := c.Query("member_id"); s != "" {
+		memberID, err := strconv.Atoi(s)
+		if err != nil || memberID <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 member_id", "")
+			return
+		}
+		filter.MemberID = memberID
+	}
+
+	rents, nextCursor, err := services.SearchRentRecords(filter)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", err.Error())
+		} else {
+			ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		}
+		return
+	}
+
+	respondRentPage(c, rents, nextCursor)
+}
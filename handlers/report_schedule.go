@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"project01/models"
+	"project01/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportScheduleInput 新增／更新報表排程的輸入結構體
+type ReportScheduleInput struct {
+	Name          string `json:"name" binding:"required,max=100"`
+	ReportType    string `json:"report_type" binding:"required,oneof=income rent_history"`
+	ParkingLotIDs string `json:"parking_lot_ids" binding:"max=255"`
+	SplitByLot    bool   `json:"split_by_lot"`
+	Period        string `json:"period" binding:"required,oneof=previous_day previous_week previous_month"`
+	Format        string `json:"format" binding:"required,oneof=csv xlsx"`
+	CronSpec      string `json:"cron_spec" binding:"required,max=64"`
+	Destination   string `json:"destination" binding:"required,oneof=directory email"`
+	Target        string `json:"target" binding:"max=255"`
+	Enabled       *bool  `json:"enabled"`
+}
+
+func (in ReportScheduleInput) toModel() models.ReportSchedule {
+	enabled := true
+	if in.Enabled != nil {
+		enabled = *in.Enabled
+	}
+	return models.ReportSchedule{
+		Name:          in.Name,
+		ReportType:    in.ReportType,
+		ParkingLotIDs: in.ParkingLotIDs,
+		SplitByLot:    in.SplitByLot,
+		Period:        in.Period,
+		Format:        in.Format,
+		CronSpec:      in.CronSpec,
+		Destination:   in.Destination,
+		Target:        in.Target,
+		Enabled:       enabled,
+	}
+}
+
+// reportScheduleError 將報表排程的錯誤對應到 HTTP 狀態碼
+func reportScheduleError(c *gin.Context, message string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found") && !strings.Contains(msg, "invalid"):
+		ErrorResponse(c, http.StatusNotFound, "報表排程不存在", msg)
+	case strings.Contains(msg, "invalid"):
+		ErrorResponse(c, http.StatusBadRequest, message, msg)
+	case strings.Contains(msg, "already running"):
+		ErrorResponse(c, http.StatusConflict, "報表正在執行中", msg)
+	default:
+		ErrorResponse(c, http.StatusInternalServerError, message, msg)
+	}
+}
+
+// CreateReportSchedule 新增報表排程 (admin only)
+func CreateReportSchedule(c *gin.Context) {
+	var input ReportScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	schedule := input.toModel()
+	schedule.CreatedBy = c.GetInt("member_id")
+	if err := services.CreateReportSchedule(&schedule); err != nil {
+		reportScheduleError(c, "新增失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusCreated, "新增成功", schedule)
+}
+
+// GetReportSchedules 查詢所有報表排程 (admin only)
+func GetReportSchedules(c *gin.Context) {
+	schedules, err := services.GetReportSchedules()
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", schedules)
+}
+
+// UpdateReportSchedule 更新報表排程 (admin only)
+func UpdateReportSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的排程ID", "")
+		return
+	}
+
+	var input ReportScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	schedule, err := services.UpdateReportSchedule(id, input.toModel())
+	if err != nil {
+		reportScheduleError(c, "更新失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "更新成功", schedule)
+}
+
+// DeleteReportSchedule 刪除報表排程 (admin only)
+func DeleteReportSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的排程ID", "")
+		return
+	}
+
+	if err := services.DeleteReportSchedule(id); err != nil {
+		reportScheduleError(c, "刪除失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "刪除成功", nil)
+}
+
+// RunReportSchedule 立即於背景執行一次報表排程 (admin only)
+func RunReportSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的排程ID", "")
+		return
+	}
+
+	adminID := c.GetInt("member_id")
+	run, err := services.RunReportSchedule(id, "manual", &adminID, true)
+	if err != nil {
+		reportScheduleError(c, "執行失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusAccepted, "報表已開始產生", run)
+}
+
+// GetReportRuns 查詢報表執行紀錄，可用 schedule_id 篩選 (admin only)
+func GetReportRuns(c *gin.Context) {
+	scheduleID := 0
+	if s := c.Query("schedule_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的 schedule_id", "")
+			return
+		}
+		scheduleID = id
+	}
+	if s := c.Param("id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			ErrorResponse(c, http.StatusBadRequest, "無效的排程ID", "")
+			return
+		}
+		scheduleID = id
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	runs, err := services.GetReportRuns(scheduleID, limit)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", runs)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"project01/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyStatements 列出自己已保存的月結單
+func GetMyStatements(c *gin.Context) {
+	memberID := c.GetInt("member_id")
+	if memberID == 0 {
+		ErrorResponse(c, http.StatusUnauthorized, "未授權", "member_id not found")
+		return
+	}
+
+	statements, err := services.GetMemberStatements(memberID)
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", statements)
+}
+
+// GetMyStatement 下載自己某月（YYYY-MM）的月結單，format 可為 json、csv、pdf
+func GetMyStatement(c *gin.Context) {
+	memberID := c.GetInt("member_id")
+	if memberID == 0 {
+		ErrorResponse(c, http.StatusUnauthorized, "未授權", "member_id not found")
+		return
+	}
+	renderStatement(c, memberID, false)
+}
+
+// GetMemberStatement 下載指定會員某月的月結單，regenerate=true 時重新產生 (admin only)
+func GetMemberStatement(c *gin.Context) {
+	memberID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || memberID <= 0 {
+		ErrorResponse(c, http.StatusBadRequest, "無效的會員ID", "")
+		return
+	}
+	renderStatement(c, memberID, c.Query("regenerate") == "true")
+}
+
+// renderStatement 產生月結單並依 format 輸出
+func renderStatement(c *gin.Context, memberID int, regenerate bool) {
+	month := c.Param("month")
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "pdf" {
+		ErrorResponse(c, http.StatusBadRequest, "無效的 format", "format 應為 json、csv 或 pdf")
+		return
+	}
+
+	st, err := services.GetMemberStatement(memberID, month, regenerate)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid"):
+			ErrorResponse(c, http.StatusBadRequest, "查詢失敗", err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			ErrorResponse(c, http.StatusNotFound, "會員不存在", err.Error())
+		default:
+			ErrorResponse(c, http.StatusInternalServerError, "查詢失敗", err.Error())
+		}
+		return
+	}
+
+	if format == "json" {
+		SuccessResponse(c, http.StatusOK, "查詢成功", st)
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = services.WriteStatementPDF(&buf, st)
+	} else {
+		err = services.WriteStatementCSV(&buf, st)
+	}
+	if err != nil {
+		ErrorResponse(c, http.StatusInternalServerError, "產生月結單失敗", err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement_%d_%s.%s"`, memberID, st.Month, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project01/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// totpError 將兩步驟驗證相關錯誤對應到 HTTP 狀態碼
+func totpError(c *gin.Context, message string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "invalid or expired mfa token"):
+		ErrorResponse(c, http.StatusUnauthorized, "登入驗證已失效，請重新登入", msg, "ERR_MFA_TOKEN_INVALID")
+	case strings.Contains(msg, "temporarily locked"):
+		ErrorResponse(c, http.StatusTooManyRequests, "驗證失敗次數過多，請稍後再試", msg, "ERR_2FA_LOCKED")
+	case strings.Contains(msg, "invalid 2fa code"):
+		ErrorResponse(c, http.StatusUnauthorized, "驗證碼錯誤", msg, "ERR_2FA_CODE_INVALID")
+	case strings.Contains(msg, "required by policy"):
+		ErrorResponse(c, http.StatusForbidden, "管理員必須使用兩步驟驗證", msg)
+	case strings.Contains(msg, "already enabled"):
+		ErrorResponse(c, http.StatusConflict, "已啟用兩步驟驗證", msg)
+	case strings.Contains(msg, "not enabled") || strings.Contains(msg, "not started"):
+		ErrorResponse(c, http.StatusBadRequest, message, msg)
+	case strings.Contains(msg, "not found"):
+		ErrorResponse(c, http.StatusNotFound, "會員不存在", msg)
+	default:
+		ErrorResponse(c, http.StatusInternalServerError, message, msg)
+	}
+}
+
+// CompleteLoginSecondFactor 登入第二步驟：以驗證碼或備用碼換取 token
+func CompleteLoginSecondFactor(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	if (input.Code == "") == (input.RecoveryCode == "") {
+		ErrorResponse(c, http.StatusBadRequest, "請提供驗證碼或備用碼其中一項", "exactly one of code or recovery_code is required")
+		return
+	}
+
+	tokens, member, err := services.CompleteSecondFactor(input.MFAToken, input.Code, input.RecoveryCode, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		log.Printf("Second factor failed: %v", err)
+		totpError(c, "登入失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "登入成功", loginResponse(tokens, member))
+}
+
+// BeginLoginTOTPSetup 政策要求設定兩步驟驗證時，以 mfa_token 取得金鑰
+func BeginLoginTOTPSetup(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	enrollment, err := services.BeginChallengeEnrollment(input.MFAToken)
+	if err != nil {
+		totpError(c, "設定失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "請以驗證器 App 掃描並輸入驗證碼", enrollment)
+}
+
+// ConfirmLoginTOTPSetup 以 mfa_token 確認兩步驟驗證設定並完成登入
+func ConfirmLoginTOTPSetup(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	tokens, codes, member, err := services.CompleteChallengeEnrollment(input.MFAToken, input.Code, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		totpError(c, "設定失敗", err)
+		return
+	}
+
+	response := loginResponse(tokens, member)
+	response["recovery_codes"] = codes
+	SuccessResponse(c, http.StatusOK, "已啟用兩步驟驗證，請妥善保存備用碼", response)
+}
+
+// GetTOTPStatus 查詢自己的兩步驟驗證狀態
+func GetTOTPStatus(c *gin.Context) {
+	status, err := services.GetTOTPStatus(c.GetInt("member_id"))
+	if err != nil {
+		totpError(c, "查詢失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "查詢成功", status)
+}
+
+// BeginTOTPEnrollment 開始設定兩步驟驗證，回傳金鑰與 otpauth URI
+func BeginTOTPEnrollment(c *gin.Context) {
+	enrollment, err := services.BeginTOTPEnrollment(c.GetInt("member_id"))
+	if err != nil {
+		totpError(c, "設定失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "請以驗證器 App 掃描並輸入驗證碼", enrollment)
+}
+
+// ConfirmTOTPEnrollment 輸入驗證碼啟用兩步驟驗證，回傳備用碼
+func ConfirmTOTPEnrollment(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	codes, err := services.ConfirmTOTPEnrollment(c.GetInt("member_id"), input.Code)
+	if err != nil {
+		totpError(c, "設定失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "已啟用兩步驟驗證，請妥善保存備用碼", gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes 重新產生備用碼
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(c.GetInt("member_id"), input.Code)
+	if err != nil {
+		totpError(c, "產生備用碼失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "已產生新的備用碼，舊的備用碼已失效", gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP 停用兩步驟驗證
+func DisableTOTP(c *gin.Context) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+	if (input.Code == "") == (input.RecoveryCode == "") {
+		ErrorResponse(c, http.StatusBadRequest, "請提供驗證碼或備用碼其中一項", "exactly one of code or recovery_code is required")
+		return
+	}
+
+	if err := services.DisableTOTP(c.GetInt("member_id"), input.Code, input.RecoveryCode); err != nil {
+		totpError(c, "停用失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "已停用兩步驟驗證", nil)
+}
@@ -5,6 +5,8 @@ import (
 	"project01/database"
 	"project01/models"
 	"project01/services"
+	"project01/utils"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -54,7 +56,9 @@ func CreateVehicle(c *gin.Context) {
 	}
 
 	if err := services.CreateVehicle(&vehicle); err != nil {
-		if err.Error() == "LicensePlate "+input.LicensePlate+" Already used" {
+		if strings.Contains(err.Error(), "not verified") {
+			ErrorResponse(c, http.StatusForbidden, "請先完成電子郵件與電話驗證", err.Error(), "ERR_MEMBER_NOT_VERIFIED")
+		} else if err.Error() == "LicensePlate "+input.LicensePlate+" Already used" {
 			ErrorResponse(c, http.StatusConflict, "This LicensePlate Already used", err.Error())
 		} else {
 			ErrorResponse(c, http.StatusBadRequest, "Vehicle created failed", err.Error())
@@ -80,6 +84,7 @@ func UpdateVehicle(c *gin.Context) {
 		ErrorResponse(c, http.StatusBadRequest, "輸入格式錯誤", err.Error())
 		return
 	}
+	input.LicensePlate = utils.NormalizeLicensePlate(input.LicensePlate)
 
 	updates := make(map[string]interface{})
 	if input.Brand != nil {
@@ -161,6 +166,7 @@ func SetDefaultVehicle(c *gin.Context) {
 		ErrorResponse(c, http.StatusBadRequest, "請提供 license_plate", err.Error())
 		return
 	}
+	input.LicensePlate = utils.NormalizeLicensePlate(input.LicensePlate)
 
 	if err := services.SetDefaultVehicle(input.LicensePlate, memberID); err != nil {
 		ErrorResponse(c, http.StatusBadRequest, "設定失敗", err.Error())
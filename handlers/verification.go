@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"project01/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verificationError 將驗證相關錯誤對應到 HTTP 狀態碼
+func verificationError(c *gin.Context, message string, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "too frequently"):
+		ErrorResponse(c, http.StatusTooManyRequests, "驗證碼申請過於頻繁，請稍後再試", msg)
+	case strings.Contains(msg, "already verified"):
+		ErrorResponse(c, http.StatusConflict, "已完成驗證", msg)
+	case strings.Contains(msg, "invalid"):
+		ErrorResponse(c, http.StatusBadRequest, message, msg)
+	case strings.Contains(msg, "not found"):
+		ErrorResponse(c, http.StatusNotFound, "會員不存在", msg)
+	default:
+		ErrorResponse(c, http.StatusInternalServerError, message, msg)
+	}
+}
+
+// SendVerificationCode 寄送電子郵件或電話驗證碼
+func SendVerificationCode(c *gin.Context) {
+	var input struct {
+		Channel string `json:"channel" binding:"required,oneof=email phone"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	if err := services.SendVerificationCode(c.GetInt("member_id"), input.Channel); err != nil {
+		verificationError(c, "寄送驗證碼失敗", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "驗證碼已寄出", nil)
+}
+
+// ConfirmVerificationCode 以驗證碼完成電子郵件或電話驗證
+func ConfirmVerificationCode(c *gin.Context) {
+	var input struct {
+		Channel string `json:"channel" binding:"required,oneof=email phone"`
+		Code    string `json:"code" binding:"required,len=6,numeric"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		ErrorResponse(c, http.StatusBadRequest, "無效的輸入資料", err.Error())
+		return
+	}
+
+	if err := services.ConfirmVerificationCode(c.GetInt("member_id"), input.Channel, input.Code); err != nil {
+		verificationError(c, "驗證碼錯誤或已過期", err)
+		return
+	}
+
+	SuccessResponse(c, http.StatusOK, "驗證成功", nil)
+}
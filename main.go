@@ -6,8 +6,10 @@ import (
 	"project01/database"
 	"project01/models"
 	"project01/routes"
+	"project01/services"
 	"project01/utils"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -29,24 +31,87 @@ func main() {
 	// 初始化 JWTSecret
 	utils.InitJWTSecret()
 
+	// 載入車牌格式規則
+	if err := utils.InitPlateRules(); err != nil {
+		log.Fatalf("Failed to initialize plate rules: %v", err)
+	}
+
+	// 郵件寄送方式
+	if err := services.InitMailer(); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// 簡訊寄送方式
+	if err := services.InitSMSSender(); err != nil {
+		log.Fatalf("Failed to initialize sms sender: %v", err)
+	}
+
 	// 初始化資料庫
 	database.InitDB()
 
+	// 驗證欄位加入前註冊的會員視為已驗證，遷移後補上驗證時間
+	backfillVerification := !database.DB.Migrator().HasColumn(&models.Member{}, "email_verified_at")
+	// 停車紀錄記錄進場當下的車主，欄位加入前的紀錄以目前車主補上
+	backfillRentMember := !database.DB.Migrator().HasColumn(&models.Rent{}, "member_id")
+
 	// 執行資料庫遷移
 	database.DB.AutoMigrate(
 		&models.Member{},
 		&models.ParkingLot{},
 		&models.Vehicle{},
 		&models.Rent{},
+		&models.PlateListEntry{},
+		&models.EntryDenial{},
+		&models.ExitReview{},
+		&models.RentAudit{},
+		&models.Anomaly{},
+		&models.OccupancySnapshot{},
+		&models.LotDailyStat{},
+		&models.ReportSchedule{},
+		&models.ReportRun{},
+		&models.MemberStatement{},
+		&models.RefreshToken{},
+		&models.RevokedAccessToken{},
+		&models.LoginSession{},
+		&models.PasswordResetToken{},
+		&models.VerificationCode{},
+		&models.MemberTOTP{},
+		&models.TOTPRecoveryCode{},
+		&models.MFAChallenge{},
 	)
 	log.Println("Database migration completed")
 
+	if backfillVerification {
+		result := database.DB.Model(&models.Member{}).
+			Where("email_verified_at IS NULL").
+			Updates(map[string]interface{}{"email_verified_at": time.Now(), "phone_verified_at": time.Now()})
+		if result.Error != nil {
+			log.Fatalf("Failed to backfill member verification: %v", result.Error)
+		}
+		log.Printf("Marked %d existing members as verified", result.RowsAffected)
+	}
+
+	if backfillRentMember {
+		result := database.DB.Exec("UPDATE rent JOIN vehicle ON vehicle.license_plate = rent.license_plate " +
+			"SET rent.member_id = vehicle.member_id WHERE rent.member_id IS NULL")
+		if result.Error != nil {
+			log.Fatalf("Failed to backfill rent owners: %v", result.Error)
+		}
+		log.Printf("Backfilled owner of %d existing rents", result.RowsAffected)
+	}
+
+	// 建立停車場座標空間欄位與索引
+	database.EnsureParkingLotLocation()
+
 	// 確保預設管理員存在
 	ensureAdminExists()
 
 	// 檢查並更新現有密碼和 payment_info
 	updatePasswordsAndPaymentInfo()
 
+	// 將現有車牌正規化
+	normalizeLicensePlates()
+
 	// 設置 Gin 模式為 release
 	gin.SetMode(gin.ReleaseMode)
 	ginMode := os.Getenv("GIN_MODE")
@@ -69,7 +134,60 @@ func main() {
 	c := cron.New()
 
 	// 移除預約超時檢查（因無預約需求）
-	// 可保留空殼以供未來擴展
+
+	// 停車異常偵測（逾時未出場、同車牌多場、超過車位數）
+	if os.Getenv("ANOMALY_NOTIFY") == "log" {
+		services.SetAnomalyNotifier(services.LogAnomalyNotifier{})
+	}
+	anomalySpec := os.Getenv("ANOMALY_CRON")
+	if anomalySpec == "" {
+		anomalySpec = "*/10 * * * *"
+	}
+	if _, err := c.AddFunc(anomalySpec, services.RunAnomalyDetection); err != nil {
+		log.Fatalf("Failed to schedule anomaly detection: %v", err)
+	}
+
+	// 定時記錄各停車場佔用數
+	occupancySpec := os.Getenv("OCCUPANCY_SNAPSHOT_CRON")
+	if occupancySpec == "" {
+		occupancySpec = "*/5 * * * *"
+	}
+	if _, err := c.AddFunc(occupancySpec, services.RecordOccupancySnapshots); err != nil {
+		log.Fatalf("Failed to schedule occupancy snapshots: %v", err)
+	}
+
+	// 夜間重建每日彙總，修正作廢、補登等事後更正
+	dailyStatsSpec := os.Getenv("DAILY_STATS_CRON")
+	if dailyStatsSpec == "" {
+		dailyStatsSpec = "30 3 * * *"
+	}
+	if _, err := c.AddFunc(dailyStatsSpec, services.RunDailyStatsRebuild); err != nil {
+		log.Fatalf("Failed to schedule daily stats rebuild: %v", err)
+	}
+
+	// 清除已過期的 refresh token、工作階段與 access token 撤銷清單
+	tokenCleanupSpec := os.Getenv("AUTH_TOKEN_CLEANUP_CRON")
+	if tokenCleanupSpec == "" {
+		tokenCleanupSpec = "15 * * * *"
+	}
+	if _, err := c.AddFunc(tokenCleanupSpec, services.PurgeExpiredTokens); err != nil {
+		log.Fatalf("Failed to schedule token cleanup: %v", err)
+	}
+
+	// 每月 1 日保存所有會員上個月的月結單
+	statementSpec := os.Getenv("STATEMENT_SNAPSHOT_CRON")
+	if statementSpec == "" {
+		statementSpec = "10 0 1 * *"
+	}
+	if _, err := c.AddFunc(statementSpec, services.RunMonthlyStatementSnapshot); err != nil {
+		log.Fatalf("Failed to schedule monthly statement snapshot: %v", err)
+	}
+
+	// 管理員設定的定期報表
+	if err := services.InitReportScheduler(c); err != nil {
+		log.Printf("Failed to initialize report scheduler: %v", err)
+	}
+
 	c.Start()
 	log.Println("Cron jobs started")
 
@@ -106,6 +224,10 @@ func ensureAdminExists() {
 		Role:        "admin",
 		Name:        "adminj0j0",
 	}
+	// 預設管理員不需經過驗證流程
+	now := time.Now()
+	admin.EmailVerifiedAt = &now
+	admin.PhoneVerifiedAt = &now
 	// 插入資料庫
 	if err := database.DB.Create(&admin).Error; err != nil {
 		log.Fatalf("Failed to create default admin: %v", err)
@@ -154,3 +276,53 @@ func updatePasswordsAndPaymentInfo() {
 	}
 	log.Println("Password and payment_info update check completed")
 }
+
+// normalizeLicensePlates 將各表中的現有車牌轉為標準格式
+func normalizeLicensePlates() {
+	tables := []string{"vehicle", "rent", "plate_list_entry", "entry_denial"}
+	// 因衝突而未正規化的車輛車牌，其停車紀錄也保持原樣，避免紀錄與車輛對不上
+	collided := make(map[string]bool)
+
+	for _, table := range tables {
+		var plates []string
+		if err := database.DB.Table(table).Distinct("license_plate").Pluck("license_plate", &plates).Error; err != nil {
+			log.Printf("Failed to fetch license plates from %s: %v", table, err)
+			continue
+		}
+
+		updated := 0
+		for _, plate := range plates {
+			normalized := utils.NormalizeLicensePlate(plate)
+			if normalized == plate || normalized == "" {
+				continue
+			}
+
+			// vehicle 以車牌為主鍵，正規化後若與既有車牌衝突則跳過，交由管理員處理
+			if table == "vehicle" {
+				var count int64
+				database.DB.Table(table).Where("license_plate = ? AND license_plate <> ?", normalized, plate).Count(&count)
+				if count > 0 {
+					log.Printf("Skip normalizing vehicle %s: %s already exists", plate, normalized)
+					collided[plate] = true
+					continue
+				}
+			}
+			if table == "rent" && collided[plate] {
+				log.Printf("Skip normalizing rents of %s: vehicle plate collided", plate)
+				continue
+			}
+
+			if err := database.DB.Table(table).
+				Where("license_plate = ?", plate).
+				Update("license_plate", normalized).Error; err != nil {
+				log.Printf("Failed to normalize license plate %s in %s: %v", plate, table, err)
+				continue
+			}
+			updated++
+		}
+		if updated > 0 {
+			log.Printf("Normalized %d license plates in %s", updated, table)
+		}
+	}
+	log.Println("License plate normalization check completed")
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Anomaly 排程偵測到的停車異常
+type Anomaly struct {
+	ID             int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	Type           string     `gorm:"type:enum('stale_session', 'duplicate_plate', 'over_capacity');column:type;index:idx_anomaly_type" json:"type"`
+	ParkingLotID   *int       `gorm:"column:parking_lot_id;index:idx_anomaly_lot" json:"parking_lot_id,omitempty"`
+	LicensePlate   string     `gorm:"size:20;column:license_plate" json:"license_plate,omitempty"`
+	Detail         string     `gorm:"size:255;column:detail" json:"detail"`
+	Status         string     `gorm:"type:enum('open', 'resolved');default:'open';column:status;index:idx_anomaly_status" json:"status"`
+	DetectedAt     time.Time  `gorm:"column:detected_at" json:"detected_at"`
+	ResolvedBy     *int       `gorm:"column:resolved_by" json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `gorm:"column:resolved_at" json:"resolved_at,omitempty"`
+	ResolutionNote string     `gorm:"size:255;column:resolution_note" json:"resolution_note,omitempty"`
+}
+
+func (Anomaly) TableName() string {
+	return "anomaly"
+}
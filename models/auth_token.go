@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// RefreshToken 伺服器端保存的 refresh token（只存 SHA-256 雜湊）
+// 每次換發都會撤銷舊的 token，同一次登入換發出的 token 共用 FamilyID
+type RefreshToken struct {
+	ID         int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	MemberID   int        `gorm:"column:member_id;index:idx_refresh_token_member" json:"member_id"`
+	FamilyID   string     `gorm:"type:char(32);column:family_id;index:idx_refresh_token_family" json:"family_id"`
+	TokenHash  string     `gorm:"type:char(64);column:token_hash;uniqueIndex:idx_refresh_token_hash" json:"-"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;index:idx_refresh_token_expires" json:"expires_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	ReplacedBy *int       `gorm:"column:replaced_by" json:"replaced_by,omitempty"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_token"
+}
+
+// RevokedAccessToken 已登出但尚未過期的 access token（依 jti）
+type RevokedAccessToken struct {
+	JTI       string    `gorm:"primaryKey;type:char(32);column:jti" json:"jti"`
+	MemberID  int       `gorm:"column:member_id" json:"member_id"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index:idx_revoked_access_expires" json:"expires_at"`
+}
+
+func (RevokedAccessToken) TableName() string {
+	return "revoked_access_token"
+}
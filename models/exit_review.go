@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ExitReview 出場車牌辨識模糊、需人工確認的佇列
+type ExitReview struct {
+	ID            int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	ParkingLotID  int        `gorm:"column:parking_lot_id;index:idx_exit_review_lot" json:"parking_lot_id"`
+	PlateRead     string     `gorm:"size:20;column:plate_read" json:"plate_read"`  // 攝影機辨識到的車牌
+	Candidates    string     `gorm:"size:255;column:candidates" json:"candidates"` // 候選車牌，以逗號分隔
+	EndTime       time.Time  `gorm:"column:end_time" json:"end_time"`              // 出場時間
+	Status        string     `gorm:"type:enum('pending', 'resolved', 'rejected');default:'pending';column:status;index:idx_exit_review_status" json:"status"`
+	ResolvedPlate string     `gorm:"size:20;column:resolved_plate" json:"resolved_plate,omitempty"`
+	ResolvedBy    *int       `gorm:"column:resolved_by" json:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time `gorm:"column:resolved_at" json:"resolved_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (ExitReview) TableName() string {
+	return "exit_review"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LoginSession 登入工作階段（每次登入一筆），SessionID 即 refresh token 的 FamilyID 與 access token 的 sid
+type LoginSession struct {
+	SessionID  string     `gorm:"primaryKey;type:char(32);column:session_id" json:"session_id"`
+	MemberID   int        `gorm:"column:member_id;index:idx_login_session_member" json:"member_id"`
+	UserAgent  string     `gorm:"type:varchar(255);column:user_agent" json:"user_agent"`
+	IPAddress  string     `gorm:"type:varchar(45);column:ip_address" json:"ip_address"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;index:idx_login_session_expires" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	Current    bool       `gorm:"-" json:"current"` // 是否為目前請求使用的工作階段
+}
+
+func (LoginSession) TableName() string {
+	return "login_session"
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LotDailyStat 每個停車場每天的彙總（依出場日計算，只含已結束、未作廢的紀錄）
+type LotDailyStat struct {
+	ParkingLotID  int       `gorm:"primaryKey;autoIncrement:false;column:parking_lot_id" json:"parking_lot_id"`
+	Day           time.Time `gorm:"primaryKey;type:date;column:day;index:idx_lot_daily_stat_day" json:"day"`
+	Sessions      int64     `gorm:"column:sessions;default:0" json:"sessions"`                  // 當天出場次數
+	Revenue       float64   `gorm:"type:decimal(12,2);column:revenue;default:0" json:"revenue"` // 當天出場紀錄的費用合計
+	StayMinutes   int64     `gorm:"column:stay_minutes;default:0" json:"stay_minutes"`          // 當天出場紀錄的停車分鐘數合計
+	ParkedMinutes int64     `gorm:"column:parked_minutes;default:0" json:"parked_minutes"`      // 已結束紀錄落在當天的佔用分鐘數
+	PeakOccupancy int       `gorm:"column:peak_occupancy;default:0" json:"peak_occupancy"`      // 當天同時在場的最高車輛數
+	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (LotDailyStat) TableName() string {
+	return "lot_daily_stat"
+}
@@ -1,19 +1,30 @@
 package models
 
+import "time"
+
 type Member struct {
-	MemberID    int    `json:"member_id" gorm:"primaryKey;autoIncrement;type:INT"`
-	Email       string `json:"email" gorm:"type:varchar(50);unique" binding:"omitempty,email,max=50"`
-	Phone       string `json:"phone" gorm:"type:varchar(10);unique" binding:"omitempty,max=10"`
-	Password    string `json:"-" gorm:"type:varchar(100)"`
-	PaymentInfo string `json:"payment_info" gorm:"type:varchar(100)"`
-	Role        string `json:"role" gorm:"type:enum('renter', 'admin');default:'renter'"`
-	Name        string `json:"name" gorm:"type:varchar(50)"`
+	MemberID     int    `json:"member_id" gorm:"primaryKey;autoIncrement;type:INT"`
+	Email        string `json:"email" gorm:"type:varchar(50);unique" binding:"omitempty,email,max=50"`
+	Phone        string `json:"phone" gorm:"type:varchar(10);unique" binding:"omitempty,max=10"`
+	Password     string `json:"-" gorm:"type:varchar(100)"`
+	PaymentInfo  string `json:"payment_info" gorm:"type:varchar(100)"`
+	Role         string `json:"role" gorm:"type:enum('renter', 'admin');default:'renter'"`
+	Name         string `json:"name" gorm:"type:varchar(50)"`
+	TokenVersion int    `json:"-" gorm:"default:0"` // 遞增後，先前簽發的 access token 全部失效
+	// 電子郵件與電話完成驗證的時間；未驗證為 NULL，變更後需重新驗證
+	EmailVerifiedAt *time.Time `json:"-" gorm:"column:email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"-" gorm:"column:phone_verified_at"`
 }
 
 func (Member) TableName() string {
 	return "member"
 }
 
+// IsVerified 電子郵件與電話是否都已驗證
+func (m *Member) IsVerified() bool {
+	return m.EmailVerifiedAt != nil && m.PhoneVerifiedAt != nil
+}
+
 type MemberResponse struct {
 	MemberID    int    `json:"member_id"`
 	Email       string `json:"email"`
@@ -21,6 +32,9 @@ type MemberResponse struct {
 	PaymentInfo string `json:"payment_info"`
 	Role        string `json:"role"`
 	Name        string `json:"name"`
+	// 驗證狀態
+	EmailVerified bool `json:"email_verified"`
+	PhoneVerified bool `json:"phone_verified"`
 }
 
 func (m *Member) ToResponse() MemberResponse {
@@ -31,5 +45,8 @@ func (m *Member) ToResponse() MemberResponse {
 		PaymentInfo: m.PaymentInfo,
 		Role:        m.Role,
 		Name:        m.Name,
+
+		EmailVerified: m.EmailVerifiedAt != nil,
+		PhoneVerified: m.PhoneVerifiedAt != nil,
 	}
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// MemberStatement 已產生的會員月結單；Data 為產生當下的 JSON 快照，之後的修正不影響已開立的月結單
+type MemberStatement struct {
+	ID          int       `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	MemberID    int       `gorm:"column:member_id;uniqueIndex:idx_member_statement_month,priority:1" json:"member_id"`
+	Month       string    `gorm:"type:char(7);column:month;uniqueIndex:idx_member_statement_month,priority:2" json:"month"` // YYYY-MM
+	Sessions    int       `gorm:"column:sessions" json:"sessions"`
+	TotalCost   float64   `gorm:"type:decimal(10,2);column:total_cost" json:"total_cost"`
+	Data        string    `gorm:"type:longtext;column:data" json:"-"`
+	GeneratedAt time.Time `gorm:"column:generated_at" json:"generated_at"`
+}
+
+func (MemberStatement) TableName() string {
+	return "member_statement"
+}
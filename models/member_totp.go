@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// MemberTOTP 會員的 TOTP 兩步驟驗證設定；Secret 以 AES 加密保存，ConfirmedAt 為 NULL 表示尚未完成設定
+type MemberTOTP struct {
+	MemberID       int        `gorm:"primaryKey;autoIncrement:false;type:INT;column:member_id" json:"member_id"`
+	Secret         string     `gorm:"type:varchar(255);column:secret" json:"-"`
+	ConfirmedAt    *time.Time `gorm:"column:confirmed_at" json:"confirmed_at,omitempty"`
+	LastUsedStep   int64      `gorm:"column:last_used_step;default:0" json:"-"`  // 最後一次使用的時間區段，防止驗證碼重送
+	FailedAttempts int        `gorm:"column:failed_attempts;default:0" json:"-"` // 跨登入挑戰累計的連續失敗次數，成功後歸零
+	LockedUntil    *time.Time `gorm:"column:locked_until" json:"-"`              // 連續失敗過多時暫停驗證至此時間
+	CreatedAt      time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (MemberTOTP) TableName() string {
+	return "member_totp"
+}
+
+// TOTPRecoveryCode 兩步驟驗證的備用碼（只存雜湊），每組只能使用一次
+type TOTPRecoveryCode struct {
+	ID        int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	MemberID  int        `gorm:"column:member_id;index:idx_totp_recovery_member" json:"member_id"`
+	CodeHash  string     `gorm:"type:char(64);column:code_hash" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (TOTPRecoveryCode) TableName() string {
+	return "totp_recovery_code"
+}
+
+// MFAChallenge 密碼驗證通過後、等待第二步驟的登入挑戰（只存 token 雜湊）
+type MFAChallenge struct {
+	ID        int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	MemberID  int        `gorm:"column:member_id;index:idx_mfa_challenge_member" json:"member_id"`
+	TokenHash string     `gorm:"type:char(64);column:token_hash;uniqueIndex:idx_mfa_challenge_hash" json:"-"`
+	Attempts  int        `gorm:"column:attempts;default:0" json:"attempts"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;index:idx_mfa_challenge_expires" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (MFAChallenge) TableName() string {
+	return "mfa_challenge"
+}
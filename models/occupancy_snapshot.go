@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OccupancySnapshot 定時記錄的停車場佔用數
+type OccupancySnapshot struct {
+	ID           int       `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	ParkingLotID int       `gorm:"column:parking_lot_id;index:idx_occupancy_lot_time,priority:1" json:"parking_lot_id"`
+	CapturedAt   time.Time `gorm:"column:captured_at;index:idx_occupancy_lot_time,priority:2" json:"captured_at"`
+	ActiveCount  int       `gorm:"column:active_count" json:"active_count"`
+	TotalSpots   int       `gorm:"column:total_spots" json:"total_spots"`
+}
+
+func (OccupancySnapshot) TableName() string {
+	return "occupancy_snapshot"
+}
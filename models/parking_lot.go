@@ -1,16 +1,29 @@
 package models
 
+import (
+	"strings"
+	"time"
+)
+
 // ParkingLot 定義停車場模型
 type ParkingLot struct {
-	ParkingLotID   int     `json:"parking_lot_id" gorm:"primaryKey;autoIncrement;type:INT"`
-	Type           string  `json:"type" gorm:"type:enum('flat', 'mechanical')" binding:"omitempty,oneof=flat mechanical"`
-	Address        string  `json:"address" gorm:"type:varchar(100)" binding:"omitempty,max=100"`
-	HourlyRate     float64 `json:"hourly_rate" gorm:"type:decimal(10,2)" binding:"omitempty,gte=0"`
-	TotalSpots     int     `json:"total_spots" gorm:"type:INT" binding:"omitempty,gte=0"`
-	Longitude      float64 `json:"longitude" gorm:"type:decimal(9,6)" binding:"omitempty,gte=-180,lte=180"`
-	Latitude       float64 `json:"latitude" gorm:"type:decimal(9,6)" binding:"omitempty,gte=-90,lte=90"`
-	Rents          []Rent  `gorm:"foreignKey:ParkingLotID" json:"-"`
-	RemainingSpots int     `json:"-" gorm:"-"` // transient，不存DB，用於計算剩餘位子
+	ParkingLotID    int        `json:"parking_lot_id" gorm:"primaryKey;autoIncrement;type:INT"`
+	Type            string     `json:"type" gorm:"type:enum('flat', 'mechanical')" binding:"omitempty,oneof=flat mechanical"`
+	Address         string     `json:"address" gorm:"type:varchar(100)" binding:"omitempty,max=100"`
+	HourlyRate      float64    `json:"hourly_rate" gorm:"type:decimal(10,2)" binding:"omitempty,gte=0"`
+	TotalSpots      int        `json:"total_spots" gorm:"type:INT" binding:"omitempty,gte=0"`
+	Longitude       float64    `json:"longitude" gorm:"type:decimal(9,6)" binding:"omitempty,gte=-180,lte=180"`
+	Latitude        float64    `json:"latitude" gorm:"type:decimal(9,6)" binding:"omitempty,gte=-90,lte=90"`
+	AllowlistOnly   bool       `json:"allowlist_only" gorm:"column:allowlist_only;default:false"`                               // 僅允許白名單車牌進場
+	StaleAfterHours int        `json:"stale_after_hours" gorm:"column:stale_after_hours;default:0" binding:"omitempty,gte=0"`   // 停車超過幾小時視為異常，0 使用系統預設
+	VehicleClasses  string     `json:"vehicle_classes" gorm:"type:set('car', 'scooter');default:'car'"`                         // 可停車種，逗號分隔
+	OpenTime        string     `json:"open_time" gorm:"type:char(5)" binding:"omitempty,datetime=15:04"`                        // 營業開始 HH:MM，空值代表 24 小時
+	CloseTime       string     `json:"close_time" gorm:"type:char(5)" binding:"omitempty,datetime=15:04"`                       // 營業結束 HH:MM，早於開始代表跨夜
+	ExternalRef     *string    `json:"external_ref,omitempty" gorm:"type:varchar(64);uniqueIndex:idx_parking_lot_external_ref"` // 外部系統代碼，批次匯入時用於比對更新
+	ArchivedAt      *time.Time `json:"archived_at,omitempty" gorm:"index:idx_parking_lot_archived_at"`                          // 停用時間，非空代表已停用（不可搜尋、不可進場，保留歷史紀錄）
+	Rents           []Rent     `gorm:"foreignKey:ParkingLotID" json:"-"`
+	RemainingSpots  int        `json:"-" gorm:"-"` // transient，不存DB，用於計算剩餘位子
+	DistanceKm      *float64   `json:"-" gorm:"-"` // transient，附近查詢時與查詢點的距離
 }
 
 func (ParkingLot) TableName() string {
@@ -19,35 +32,101 @@ func (ParkingLot) TableName() string {
 
 // ParkingLotResponse 定義停車場回應結構
 type ParkingLotResponse struct {
-	ParkingLotID   int     `json:"parking_lot_id"`
-	Type           string  `json:"type"`
-	Address        string  `json:"address"`
-	HourlyRate     float64 `json:"hourly_rate"`
-	TotalSpots     int     `json:"total_spots"`
-	Longitude      float64 `json:"longitude"`
-	Latitude       float64 `json:"latitude"`
-	RemainingSpots int     `json:"remaining_spots"` // 新增
+	ParkingLotID    int      `json:"parking_lot_id"`
+	Type            string   `json:"type"`
+	Address         string   `json:"address"`
+	HourlyRate      float64  `json:"hourly_rate"`
+	TotalSpots      int      `json:"total_spots"`
+	Longitude       float64  `json:"longitude"`
+	Latitude        float64  `json:"latitude"`
+	AllowlistOnly   bool     `json:"allowlist_only"`
+	StaleAfterHours int      `json:"stale_after_hours"`
+	VehicleClasses  []string `json:"vehicle_classes"`
+	OpenTime        string   `json:"open_time,omitempty"`
+	CloseTime       string   `json:"close_time,omitempty"`
+	ExternalRef     *string  `json:"external_ref,omitempty"`
+	ArchivedAt      *string  `json:"archived_at,omitempty"`
+	RemainingSpots  int      `json:"remaining_spots"` // 新增
+	DistanceKm      *float64 `json:"distance_km,omitempty"`
 }
 
 func (p *ParkingLot) ToResponse() ParkingLotResponse {
+	var archivedAt *string
+	if p.ArchivedAt != nil {
+		s := p.ArchivedAt.Format(time.RFC3339)
+		archivedAt = &s
+	}
 	return ParkingLotResponse{
-		ParkingLotID:   p.ParkingLotID,
-		Type:           p.Type,
-		Address:        p.Address,
-		HourlyRate:     p.HourlyRate,
-		TotalSpots:     p.TotalSpots,
-		Longitude:      p.Longitude,
-		Latitude:       p.Latitude,
-		RemainingSpots: p.RemainingSpots, // 新增
+		ParkingLotID:    p.ParkingLotID,
+		Type:            p.Type,
+		Address:         p.Address,
+		HourlyRate:      p.HourlyRate,
+		TotalSpots:      p.TotalSpots,
+		Longitude:       p.Longitude,
+		Latitude:        p.Latitude,
+		AllowlistOnly:   p.AllowlistOnly,
+		StaleAfterHours: p.StaleAfterHours,
+		VehicleClasses:  p.VehicleClassList(),
+		OpenTime:        p.OpenTime,
+		CloseTime:       p.CloseTime,
+		ExternalRef:     p.ExternalRef,
+		ArchivedAt:      archivedAt,
+		RemainingSpots:  p.RemainingSpots, // 新增
+		DistanceKm:      p.DistanceKm,
+	}
+}
+
+// VehicleClassList 將 vehicle_classes 拆成清單
+func (p *ParkingLot) VehicleClassList() []string {
+	classes := []string{}
+	for _, c := range strings.Split(p.VehicleClasses, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			classes = append(classes, c)
+		}
+	}
+	return classes
+}
+
+// IsArchived 是否已停用
+func (p *ParkingLot) IsArchived() bool {
+	return p.ArchivedAt != nil
+}
+
+// AcceptsVehicleClass 是否可停指定車種
+func (p *ParkingLot) AcceptsVehicleClass(class string) bool {
+	for _, c := range p.VehicleClassList() {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpenAt 依營業時間（CST）判斷指定時間是否營業，未設定營業時間視為 24 小時
+func (p *ParkingLot) IsOpenAt(t time.Time) bool {
+	if p.OpenTime == "" || p.CloseTime == "" || p.OpenTime == p.CloseTime {
+		return true
+	}
+	now := t.In(time.FixedZone("CST", 8*60*60)).Format("15:04")
+	if p.OpenTime < p.CloseTime {
+		return now >= p.OpenTime && now < p.CloseTime
 	}
+	// 跨夜營業，例如 22:00 ~ 06:00
+	return now >= p.OpenTime || now < p.CloseTime
 }
 
 // UpdateParkingLotRequest 用於 PUT 更新
 type UpdateParkingLotRequest struct {
-	Type       *string  `json:"type" binding:"omitempty,oneof=flat mechanical"`
-	Address    *string  `json:"address" binding:"omitempty,max=100"`
-	HourlyRate *float64 `json:"hourly_rate" binding:"omitempty,gte=0"`
-	TotalSpots *int     `json:"total_spots" binding:"gte=0"` // 移除 omitempty
-	Longitude  *float64 `json:"longitude" binding:"omitempty,gte=-180,lte=180"`
-	Latitude   *float64 `json:"latitude" binding:"omitempty,gte=-90,lte=90"`
+	Type            *string  `json:"type" binding:"omitempty,oneof=flat mechanical"`
+	Address         *string  `json:"address" binding:"omitempty,max=100"`
+	HourlyRate      *float64 `json:"hourly_rate" binding:"omitempty,gte=0"`
+	TotalSpots      *int     `json:"total_spots" binding:"gte=0"` // 移除 omitempty
+	Longitude       *float64 `json:"longitude" binding:"omitempty,gte=-180,lte=180"`
+	Latitude        *float64 `json:"latitude" binding:"omitempty,gte=-90,lte=90"`
+	AllowlistOnly   *bool    `json:"allowlist_only"`
+	StaleAfterHours *int     `json:"stale_after_hours" binding:"omitempty,gte=0"`
+	VehicleClasses  *string  `json:"vehicle_classes"`
+	OpenTime        *string  `json:"open_time"`  // 空字串代表改為 24 小時
+	CloseTime       *string  `json:"close_time"` // 空字串代表改為 24 小時
+	ExternalRef     *string  `json:"external_ref" binding:"omitempty,max=64"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PasswordResetToken 忘記密碼的重設 token（只存 SHA-256 雜湊），使用一次後即失效
+type PasswordResetToken struct {
+	ID        int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	MemberID  int        `gorm:"column:member_id;index:idx_password_reset_member" json:"member_id"`
+	TokenHash string     `gorm:"type:char(64);column:token_hash;uniqueIndex:idx_password_reset_hash" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;index:idx_password_reset_expires" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at,omitempty"`
+	RequestIP string     `gorm:"type:varchar(45);column:request_ip" json:"request_ip"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_token"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PlateListEntry 車牌黑白名單（parking_lot_id 為 NULL 代表全域適用）
+type PlateListEntry struct {
+	ID           int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	LicensePlate string     `gorm:"size:20;column:license_plate;index:idx_plate_list_plate" json:"license_plate"`
+	ParkingLotID *int       `gorm:"column:parking_lot_id;index:idx_plate_list_lot" json:"parking_lot_id,omitempty"`
+	ListType     string     `gorm:"type:enum('block', 'allow');column:list_type" json:"list_type"`
+	Reason       string     `gorm:"size:200;column:reason" json:"reason"`
+	ExpiresAt    *time.Time `gorm:"column:expires_at" json:"expires_at,omitempty"`
+	CreatedBy    int        `gorm:"column:created_by" json:"created_by"`
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (PlateListEntry) TableName() string {
+	return "plate_list_entry"
+}
+
+// EntryDenial 被拒絕進場的紀錄（供管理員查詢）
+type EntryDenial struct {
+	ID           int       `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	LicensePlate string    `gorm:"size:20;column:license_plate;index:idx_denial_plate" json:"license_plate"`
+	ParkingLotID int       `gorm:"column:parking_lot_id;index:idx_denial_lot" json:"parking_lot_id"`
+	Reason       string    `gorm:"size:200;column:reason" json:"reason"`
+	ListEntryID  *int      `gorm:"column:list_entry_id" json:"list_entry_id,omitempty"`
+	DeniedAt     time.Time `gorm:"column:denied_at;index:idx_denial_time" json:"denied_at"`
+}
+
+func (EntryDenial) TableName() string {
+	return "entry_denial"
+}
@@ -8,9 +8,13 @@ import (
 type Rent struct {
 	LicensePlate string     `gorm:"primaryKey;size:20;column:license_plate" json:"license_plate"`
 	ParkingLotID int        `gorm:"column:parking_lot_id" json:"parking_lot_id"`
+	MemberID     *int       `gorm:"column:member_id;index:idx_rent_member" json:"member_id,omitempty"` // 進場當下的車主，月結單依此歸屬
 	StartTime    time.Time  `gorm:"primaryKey;column:start_time" json:"start_time"`
 	EndTime      *time.Time `gorm:"column:end_time" json:"end_time,omitempty"`
 	TotalCost    *float64   `gorm:"type:decimal(6,2);column:total_cost" json:"total_cost,omitempty"`
+	ExitPlate    *string    `gorm:"size:20;column:exit_plate" json:"exit_plate,omitempty"`   // 模糊比對出場時，攝影機辨識到的車牌
+	FuzzyMatched bool       `gorm:"column:fuzzy_matched;default:false" json:"fuzzy_matched"` // 是否經模糊比對結束
+	Voided       bool       `gorm:"column:voided;default:false" json:"voided"`               // 管理員作廢，不計入收入
 	ParkingLot   ParkingLot `gorm:"foreignKey:ParkingLotID;references:ParkingLotID" json:"parking_lot,omitempty"`
 }
 
@@ -25,6 +29,8 @@ type RentResponse struct {
 	StartTime    string  `json:"start_time"`
 	EndTime      *string `json:"end_time,omitempty"`
 	TotalCost    *string `json:"total_cost,omitempty"`
+	FuzzyMatched bool    `json:"fuzzy_matched,omitempty"`
+	Voided       bool    `json:"voided,omitempty"`
 }
 
 func (r *Rent) ToResponse() RentResponse {
@@ -43,5 +49,7 @@ func (r *Rent) ToResponse() RentResponse {
 		StartTime:    r.StartTime.Format(time.RFC3339),
 		EndTime:      endTimeStr,
 		TotalCost:    costStr,
+		FuzzyMatched: r.FuzzyMatched,
+		Voided:       r.Voided,
 	}
 }
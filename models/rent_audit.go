@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RentAudit 管理員修正停車紀錄的稽核軌跡
+type RentAudit struct {
+	ID           int       `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	LicensePlate string    `gorm:"size:20;column:license_plate;index:idx_rent_audit_rent" json:"license_plate"`
+	StartTime    time.Time `gorm:"column:start_time;index:idx_rent_audit_rent" json:"start_time"` // 操作前的進場時間（rent 主鍵）
+	Action       string    `gorm:"type:enum('force_close', 'edit', 'void', 'move');column:action" json:"action"`
+	Reason       string    `gorm:"size:255;column:reason" json:"reason"`
+	Before       string    `gorm:"type:text;column:before_data" json:"before"` // 操作前的紀錄 (JSON)
+	After        string    `gorm:"type:text;column:after_data" json:"after"`   // 操作後的紀錄 (JSON)
+	PerformedBy  int       `gorm:"column:performed_by" json:"performed_by"`
+	CreatedAt    time.Time `gorm:"column:created_at;index:idx_rent_audit_time" json:"created_at"`
+}
+
+func (RentAudit) TableName() string {
+	return "rent_audit"
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// ReportSchedule 管理員設定的定期報表
+type ReportSchedule struct {
+	ID            int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	Name          string     `gorm:"size:100;column:name" json:"name"`
+	ReportType    string     `gorm:"type:enum('income', 'rent_history');column:report_type" json:"report_type"`
+	ParkingLotIDs string     `gorm:"size:255;column:parking_lot_ids" json:"parking_lot_ids"` // 逗號分隔，空值代表所有停車場
+	SplitByLot    bool       `gorm:"column:split_by_lot;default:false" json:"split_by_lot"`  // 每個停車場各產生一個檔案
+	Period        string     `gorm:"type:enum('previous_day', 'previous_week', 'previous_month');column:period" json:"period"`
+	Format        string     `gorm:"type:enum('csv', 'xlsx');column:format" json:"format"`
+	CronSpec      string     `gorm:"size:64;column:cron_spec" json:"cron_spec"`
+	Destination   string     `gorm:"type:enum('directory', 'email');column:destination" json:"destination"`
+	Target        string     `gorm:"size:255;column:target" json:"target"` // 子目錄（相對於報表根目錄）或以逗號分隔的收件者
+	Enabled       bool       `gorm:"column:enabled;default:true" json:"enabled"`
+	CreatedBy     int        `gorm:"column:created_by" json:"created_by"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	LastRunAt     *time.Time `gorm:"column:last_run_at" json:"last_run_at,omitempty"`
+}
+
+func (ReportSchedule) TableName() string {
+	return "report_schedule"
+}
+
+// ReportRun 定期報表的執行紀錄
+type ReportRun struct {
+	ID          int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	ScheduleID  int        `gorm:"column:schedule_id;index:idx_report_run_schedule" json:"schedule_id"`
+	Trigger     string     `gorm:"type:enum('schedule', 'manual');column:run_trigger" json:"trigger"`
+	Status      string     `gorm:"type:enum('running', 'succeeded', 'failed');default:'running';column:status" json:"status"`
+	PeriodStart time.Time  `gorm:"type:date;column:period_start" json:"period_start"`
+	PeriodEnd   time.Time  `gorm:"type:date;column:period_end" json:"period_end"`
+	Files       string     `gorm:"type:text;column:files" json:"files"` // 產生的檔名，逗號分隔
+	Rows        int        `gorm:"column:row_count" json:"rows"`
+	Error       string     `gorm:"size:500;column:error" json:"error,omitempty"`
+	TriggeredBy *int       `gorm:"column:triggered_by" json:"triggered_by,omitempty"`
+	StartedAt   time.Time  `gorm:"column:started_at" json:"started_at"`
+	FinishedAt  *time.Time `gorm:"column:finished_at" json:"finished_at,omitempty"`
+}
+
+func (ReportRun) TableName() string {
+	return "report_run"
+}
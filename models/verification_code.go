@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// VerificationCode 電子郵件／電話驗證碼（只存雜湊），Target 為寄送當下的電子郵件或電話
+type VerificationCode struct {
+	ID         int        `gorm:"primaryKey;autoIncrement;type:INT;column:id" json:"id"`
+	MemberID   int        `gorm:"column:member_id;index:idx_verification_code_member" json:"member_id"`
+	Channel    string     `gorm:"type:enum('email','phone');column:channel" json:"channel"`
+	Target     string     `gorm:"type:varchar(50);column:target" json:"target"`
+	CodeHash   string     `gorm:"type:char(64);column:code_hash" json:"-"`
+	Attempts   int        `gorm:"column:attempts;default:0" json:"attempts"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;index:idx_verification_code_expires" json:"expires_at"`
+	ConsumedAt *time.Time `gorm:"column:consumed_at" json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (VerificationCode) TableName() string {
+	return "verification_code"
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"project01/handlers"
+	"project01/services"
 	"project01/utils"
 	"strconv"
 	"strings"
@@ -42,7 +43,6 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
-		log.Printf("Parsing token: %s", tokenString)
 
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -113,9 +113,52 @@ func AuthMiddleware() gin.HandlerFunc {
 				return
 			}
 
+			jti, jtiOK := claims["jti"].(string)
+			version, verOK := claims["ver"].(float64)
+			if !jtiOK || !verOK || jti == "" {
+				log.Printf("Missing jti or ver in token")
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"status":  false,
+					"message": "無效的 token 內容",
+					"error":   "Missing or invalid jti/ver claim",
+					"code":    "ERR_INVALID_CLAIMS",
+				})
+				c.Abort()
+				return
+			}
+
+			sid, _ := claims["sid"].(string)
+
+			// 檢查 token 是否已被撤銷（登出、撤銷裝置、會員刪除或角色變更），並更新工作階段的最後使用時間
+			if err := services.ValidateAccessToken(int(memberID), role, int(version), jti, sid); err != nil {
+				log.Printf("Token rejected for member_id %d: %v", int(memberID), err)
+				if strings.Contains(err.Error(), "revoked") {
+					c.JSON(http.StatusUnauthorized, gin.H{
+						"status":  false,
+						"message": "token 已失效，請重新登入",
+						"error":   err.Error(),
+						"code":    "ERR_TOKEN_REVOKED",
+					})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"status":  false,
+						"message": "無法驗證 token",
+						"error":   err.Error(),
+						"code":    "ERR_TOKEN_CHECK_FAILED",
+					})
+				}
+				c.Abort()
+				return
+			}
+
+			exp, _ := claims["exp"].(float64)
+
 			log.Printf("Token verified for member_id: %d, role: %s", int(memberID), role)
 			c.Set("member_id", int(memberID))
 			c.Set("role", role)
+			c.Set("jti", jti)
+			c.Set("sid", sid)
+			c.Set("token_exp", time.Unix(int64(exp), 0))
 		} else {
 			log.Printf("Invalid token claims or token is not valid")
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -281,19 +324,41 @@ func Path(router *gin.RouterGroup) {
 		members := v1.Group("/members")
 		{
 			// 公開路由：不需要 token 驗證
-			members.POST("/register", handlers.RegisterMember) //註冊
-			members.POST("/login", handlers.LoginMember)       //登入
+			members.POST("/register", handlers.RegisterMember)                       //註冊
+			members.POST("/login", handlers.LoginMember)                             //登入
+			members.POST("/password/forgot", handlers.RequestPasswordReset)          //申請重設密碼
+			members.POST("/password/reset", handlers.ConfirmPasswordReset)           //以重設 token 設定新密碼
+			members.POST("/login/2fa", handlers.CompleteLoginSecondFactor)           //登入第二步驟（驗證碼或備用碼）
+			members.POST("/login/2fa/setup", handlers.BeginLoginTOTPSetup)           //登入時設定兩步驟驗證（政策要求時）
+			members.POST("/login/2fa/setup/confirm", handlers.ConfirmLoginTOTPSetup) //確認設定並完成登入
+			members.POST("/refresh", handlers.RefreshToken)                          //以 refresh token 換發 token
 
 			// 受保護路由：需要 token 驗證
 			membersWithAuth := members.Group("")
 			membersWithAuth.Use(AuthMiddleware())
 			{
-				membersWithAuth.GET("/profile", RoleMiddleware("renter"), handlers.GetProfile)                    //查看個人資料
-				membersWithAuth.GET("/all", RoleMiddleware("admin"), handlers.GetAllMembers)                      //查詢所有會員
-				membersWithAuth.GET("/:id", RoleMiddleware("admin"), handlers.GetMember)                          //查詢特定會員
-				membersWithAuth.GET("/:id/history", MemberRentHistoryMiddleware(), handlers.GetMemberRentHistory) //查詢特定會員的租賃記錄
-				membersWithAuth.PUT("/:id", RoleMiddleware("renter", "admin"), handlers.UpdateMember)             //更新特定會員的資訊
-				membersWithAuth.DELETE("/:id", RoleMiddleware("admin"), handlers.DeleteMember)                    //刪除特定會員
+				membersWithAuth.POST("/logout", RoleMiddleware("renter", "admin"), handlers.Logout)                              //登出目前裝置
+				membersWithAuth.POST("/logout-all", RoleMiddleware("renter", "admin"), handlers.LogoutAll)                       //登出所有裝置
+				membersWithAuth.POST("/verify/send", RoleMiddleware("renter", "admin"), handlers.SendVerificationCode)           //寄送電子郵件或電話驗證碼
+				membersWithAuth.POST("/verify/confirm", RoleMiddleware("renter", "admin"), handlers.ConfirmVerificationCode)     //以驗證碼完成驗證
+				membersWithAuth.GET("/2fa", RoleMiddleware("renter", "admin"), handlers.GetTOTPStatus)                           //查詢兩步驟驗證狀態
+				membersWithAuth.POST("/2fa/enroll", RoleMiddleware("renter", "admin"), handlers.BeginTOTPEnrollment)             //開始設定兩步驟驗證
+				membersWithAuth.POST("/2fa/confirm", RoleMiddleware("renter", "admin"), handlers.ConfirmTOTPEnrollment)          //確認並啟用兩步驟驗證
+				membersWithAuth.POST("/2fa/recovery-codes", RoleMiddleware("renter", "admin"), handlers.RegenerateRecoveryCodes) //重新產生備用碼
+				membersWithAuth.POST("/2fa/disable", RoleMiddleware("renter", "admin"), handlers.DisableTOTP)                    //停用兩步驟驗證
+				membersWithAuth.GET("/sessions", RoleMiddleware("renter", "admin"), handlers.GetMySessions)                      //查詢登入中的裝置
+				membersWithAuth.DELETE("/sessions/:sid", RoleMiddleware("renter", "admin"), handlers.RevokeMySession)            //登出某一個裝置
+				membersWithAuth.GET("/profile", RoleMiddleware("renter"), handlers.GetProfile)                                   //查看個人資料
+				membersWithAuth.GET("/statements", RoleMiddleware("renter"), handlers.GetMyStatements)                           //查詢已保存的月結單
+				membersWithAuth.GET("/statements/:month", RoleMiddleware("renter"), handlers.GetMyStatement)                     //下載月結單（JSON／CSV／PDF）
+				membersWithAuth.GET("/all", RoleMiddleware("admin"), handlers.GetAllMembers)                                     //查詢所有會員
+				membersWithAuth.GET("/:id", RoleMiddleware("admin"), handlers.GetMember)                                         //查詢特定會員
+				membersWithAuth.GET("/:id/history", MemberRentHistoryMiddleware(), handlers.GetMemberRentHistory)                //查詢特定會員的租賃記錄
+				membersWithAuth.GET("/:id/statements/:month", RoleMiddleware("admin"), handlers.GetMemberStatement)              //查詢特定會員的月結單
+				membersWithAuth.PUT("/:id", RoleMiddleware("renter", "admin"), handlers.UpdateMember)                            //更新特定會員的資訊
+				membersWithAuth.GET("/:id/sessions", RoleMiddleware("admin"), handlers.GetMemberSessions)                        //查詢特定會員登入中的裝置
+				membersWithAuth.DELETE("/:id/sessions/:sid", RoleMiddleware("admin"), handlers.RevokeMemberSession)              //強制登出特定會員的某一個裝置
+				membersWithAuth.DELETE("/:id", RoleMiddleware("admin"), handlers.DeleteMember)                                   //刪除特定會員
 			}
 		}
 
@@ -303,13 +368,24 @@ func Path(router *gin.RouterGroup) {
 			parkingWithAuth := parking.Group("")
 			parkingWithAuth.Use(AuthMiddleware())
 			{
-				parkingWithAuth.POST("", RoleMiddleware("admin"), handlers.CreateParkingLot)                           // 新增停車場
-				parkingWithAuth.GET("/income", RoleMiddleware("admin"), handlers.GetParkingIncome)                     // 查詢停車場收入
-				parkingWithAuth.GET("/available", RoleMiddleware("renter", "admin"), handlers.GetAvailableParkingLots) // 查詢可用停車場
-				parkingWithAuth.GET("/:id", RoleMiddleware("renter", "admin"), handlers.GetParkingLot)                 // 查詢特定停車場詳情 (剩餘位子、經緯度)
-				parkingWithAuth.GET("/all", RoleMiddleware("renter", "admin"), handlers.GetAllParkingLots)             // 查詢所有停車場
-				parkingWithAuth.PUT("/:id", RoleMiddleware("admin"), handlers.UpdateParkingLot)                        // 更新停車場
-				parkingWithAuth.DELETE("/:id", RoleMiddleware("admin"), handlers.DeleteParkingLot)                     // 刪除停車場
+				parkingWithAuth.POST("", RoleMiddleware("admin"), handlers.CreateParkingLot)                               // 新增停車場
+				parkingWithAuth.GET("/income", RoleMiddleware("admin"), handlers.GetParkingIncome)                         // 查詢停車場收入
+				parkingWithAuth.GET("/income/report", RoleMiddleware("admin"), handlers.GetIncomeReport)                   // 多停車場收入報表（依日／週／月）
+				parkingWithAuth.GET("/income/export", RoleMiddleware("admin"), handlers.ExportParkingIncome)               // 匯出收入明細（CSV／XLSX）
+				parkingWithAuth.GET("/dashboard", RoleMiddleware("admin"), handlers.GetDashboard)                          // 管理員儀表板
+				parkingWithAuth.POST("/import", RoleMiddleware("admin"), handlers.ImportParkingLots)                       // 批次匯入停車場 (CSV / GeoJSON)
+				parkingWithAuth.GET("/export", RoleMiddleware("admin"), handlers.ExportParkingLotsGeoJSON)                 // 匯出停車場 GeoJSON
+				parkingWithAuth.GET("/available", RoleMiddleware("renter", "admin"), handlers.GetAvailableParkingLots)     // 查詢可用停車場
+				parkingWithAuth.GET("/forecast", RoleMiddleware("renter", "admin"), handlers.ForecastAvailability)         // 預測抵達時的剩餘車位
+				parkingWithAuth.POST("/recommendations", RoleMiddleware("renter", "admin"), handlers.RecommendParkingLots) // 推薦停車場
+				parkingWithAuth.GET("/:id", RoleMiddleware("renter", "admin"), handlers.GetParkingLot)                     // 查詢特定停車場詳情 (剩餘位子、經緯度)
+				parkingWithAuth.GET("/all", RoleMiddleware("renter", "admin"), handlers.GetAllParkingLots)                 // 查詢所有停車場
+				parkingWithAuth.PUT("/:id", RoleMiddleware("admin"), handlers.UpdateParkingLot)                            // 更新停車場
+				parkingWithAuth.DELETE("/:id", RoleMiddleware("admin"), handlers.DeleteParkingLot)                         // 停用停車場（保留紀錄）
+				parkingWithAuth.GET("/:id/occupancy", RoleMiddleware("admin"), handlers.GetOccupancySeries)                // 查詢停車場佔用時間序列
+				parkingWithAuth.GET("/:id/occupancy/profile", RoleMiddleware("admin"), handlers.GetOccupancyProfile)       // 查詢停車場每週各時段平均佔用
+				parkingWithAuth.POST("/:id/restore", RoleMiddleware("admin"), handlers.RestoreParkingLot)                  // 重新啟用停車場
+				parkingWithAuth.DELETE("/:id/purge", RoleMiddleware("admin"), handlers.PurgeParkingLot)                    // 永久刪除已停用且超過保留期限的停車場
 			}
 		}
 
@@ -323,9 +399,13 @@ func Path(router *gin.RouterGroup) {
 				rentWithAuth.POST("/leave", RoleMiddleware("renter"), handlers.LeaveParkingSpot)                            //離開結算（車牌掃描輸入）
 				rentWithAuth.GET("/currently-rented", RoleMiddleware("renter"), handlers.GetCurrentlyRentedSpots)           //查詢當前租用的車位
 				rentWithAuth.GET("", RoleMiddleware("renter"), handlers.GetRentRecordsByMember)                             //查詢租用紀錄
+				rentWithAuth.GET("/export", RoleMiddleware("renter", "admin"), handlers.ExportRentHistory)                  //匯出租用紀錄（CSV／XLSX）
 				rentWithAuth.GET("/total-cost", RoleMiddleware("renter"), handlers.GetTotalCost)                            //查詢總費用
 				rentWithAuth.GET("/availability", RoleMiddleware("renter", "admin"), handlers.CheckParkingAvailability)     //查詢全部停車場可用位子
 				rentWithAuth.GET("/availability/:id", RoleMiddleware("renter", "admin"), handlers.CheckParkingAvailability) //查詢特定停車場可用位子
+				rentWithAuth.GET("/exit-reviews", RoleMiddleware("admin"), handlers.GetExitReviews)                         //查詢出場人工確認佇列
+				rentWithAuth.POST("/exit-reviews/:id/resolve", RoleMiddleware("admin"), handlers.ResolveExitReview)         //人工確認出場車牌
+				rentWithAuth.POST("/exit-reviews/:id/reject", RoleMiddleware("admin"), handlers.RejectExitReview)           //駁回出場確認項目
 			}
 		}
 
@@ -343,5 +423,61 @@ func Path(router *gin.RouterGroup) {
 			}
 		}
 
+		// 停車紀錄管理路由（修正、強制結束、作廢、移動）
+		sessions := v1.Group("/sessions")
+		{
+			sessionsWithAuth := sessions.Group("")
+			sessionsWithAuth.Use(AuthMiddleware(), RoleMiddleware("admin"))
+			{
+				sessionsWithAuth.GET("", handlers.SearchRents)                 // 查詢所有會員的租用紀錄（篩選、分頁）
+				sessionsWithAuth.POST("/force-close", handlers.ForceCloseRent) // 強制結束並重新計價
+				sessionsWithAuth.PUT("", handlers.EditRent)                    // 修改進出場時間
+				sessionsWithAuth.POST("/void", handlers.VoidRent)              // 作廢紀錄
+				sessionsWithAuth.POST("/move", handlers.MoveRent)              // 移到其他停車場
+				sessionsWithAuth.GET("/audits", handlers.GetRentAudits)        // 查詢稽核軌跡
+			}
+		}
+
+		// 停車異常路由
+		anomalies := v1.Group("/anomalies")
+		{
+			anomaliesWithAuth := anomalies.Group("")
+			anomaliesWithAuth.Use(AuthMiddleware(), RoleMiddleware("admin"))
+			{
+				anomaliesWithAuth.GET("", handlers.GetAnomalies)                // 查詢異常清單
+				anomaliesWithAuth.POST("/:id/resolve", handlers.ResolveAnomaly) // 標記異常已處理
+				anomaliesWithAuth.POST("/run", handlers.RunAnomalyDetection)    // 立即執行偵測
+			}
+		}
+
+		// 定期報表路由
+		reports := v1.Group("/reports")
+		{
+			reportsWithAuth := reports.Group("")
+			reportsWithAuth.Use(AuthMiddleware(), RoleMiddleware("admin"))
+			{
+				reportsWithAuth.GET("/schedules", handlers.GetReportSchedules)          // 查詢報表排程
+				reportsWithAuth.POST("/schedules", handlers.CreateReportSchedule)       // 新增報表排程
+				reportsWithAuth.PUT("/schedules/:id", handlers.UpdateReportSchedule)    // 更新報表排程
+				reportsWithAuth.DELETE("/schedules/:id", handlers.DeleteReportSchedule) // 刪除報表排程
+				reportsWithAuth.POST("/schedules/:id/run", handlers.RunReportSchedule)  // 立即執行報表排程
+				reportsWithAuth.GET("/schedules/:id/runs", handlers.GetReportRuns)      // 查詢排程的執行紀錄
+				reportsWithAuth.GET("/runs", handlers.GetReportRuns)                    // 查詢所有執行紀錄
+			}
+		}
+
+		// 車牌黑白名單路由
+		plates := v1.Group("/plates")
+		{
+			platesWithAuth := plates.Group("")
+			platesWithAuth.Use(AuthMiddleware(), RoleMiddleware("admin"))
+			{
+				platesWithAuth.POST("/lists", handlers.CreatePlateListEntry)       // 新增黑白名單
+				platesWithAuth.GET("/lists", handlers.GetPlateListEntries)         // 查詢黑白名單
+				platesWithAuth.DELETE("/lists/:id", handlers.DeletePlateListEntry) // 刪除黑白名單
+				platesWithAuth.GET("/denials", handlers.GetEntryDenials)           // 查詢拒絕進場紀錄
+			}
+		}
+
 	}
 }
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project01/database"
+	"project01/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultStaleAfterHours 停車場未設定門檻時，停車超過幾小時視為異常
+const DefaultStaleAfterHours = 72
+
+// AnomalyNotifier 偵測到新異常時的通知介面
+type AnomalyNotifier interface {
+	NotifyAnomaly(anomaly models.Anomaly) error
+}
+
+// LogAnomalyNotifier 以 log 輸出異常通知
+type LogAnomalyNotifier struct{}
+
+func (LogAnomalyNotifier) NotifyAnomaly(anomaly models.Anomaly) error {
+	log.Printf("ANOMALY_NOTIFY | id=%d type=%s parking_lot_id=%v license_plate=%s detail=%s",
+		anomaly.ID, anomaly.Type, anomaly.ParkingLotID, anomaly.LicensePlate, anomaly.Detail)
+	return nil
+}
+
+// anomalyNotifier 目前使用的通知器（nil 代表不通知）
+var anomalyNotifier AnomalyNotifier
+
+// SetAnomalyNotifier 設定異常通知器
+func SetAnomalyNotifier(notifier AnomalyNotifier) {
+	anomalyNotifier = notifier
+}
+
+// AnomalyFilter 查詢異常的篩選條件
+type AnomalyFilter struct {
+	Type         string
+	Status       string
+	ParkingLotID int
+}
+
+// RunAnomalyDetection 執行所有異常偵測（供 cron 呼叫）
+func RunAnomalyDetection() {
+	now := time.Now()
+	created := 0
+
+	detectors := []struct {
+		name string
+		fn   func(time.Time) (int, error)
+	}{
+		{"stale_session", detectStaleSessions},
+		{"duplicate_plate", detectDuplicatePlates},
+		{"over_capacity", detectOverCapacity},
+	}
+	for _, d := range detectors {
+		n, err := d.fn(now)
+		if err != nil {
+			log.Printf("Anomaly detection %s failed: %v", d.name, err)
+			continue
+		}
+		created += n
+	}
+
+	log.Printf("ANOMALY_DETECTION | new_anomalies=%d at=%s", created, now.Format(time.RFC3339))
+}
+
+// detectStaleSessions 找出停車時間超過停車場門檻的進行中紀錄
+func detectStaleSessions(now time.Time) (int, error) {
+	defaultHours := DefaultStaleAfterHours
+	if v, err := strconv.Atoi(os.Getenv("ANOMALY_STALE_HOURS")); err == nil && v > 0 {
+		defaultHours = v
+	}
+
+	var rows []struct {
+		LicensePlate string
+		ParkingLotID int
+		StartTime    time.Time
+		Threshold    int
+	}
+	err := database.DB.Table("rent").
+		Select("rent.license_plate, rent.parking_lot_id, rent.start_time, "+
+			"CASE WHEN parking_lot.stale_after_hours > 0 THEN parking_lot.stale_after_hours ELSE ? END AS threshold", defaultHours).
+		Joins("JOIN parking_lot ON parking_lot.parking_lot_id = rent.parking_lot_id").
+		Where(activeRentCondition).
+		Where("TIMESTAMPDIFF(HOUR, rent.start_time, ?) >= CASE WHEN parking_lot.stale_after_hours > 0 THEN parking_lot.stale_after_hours ELSE ? END",
+			now, defaultHours).
+		Scan(&rows).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to query stale sessions: %w", err)
+	}
+
+	created := 0
+	for _, r := range rows {
+		lotID := r.ParkingLotID
+		detail := fmt.Sprintf("session started at %s has been open for %.0f hours (threshold %d)",
+			r.StartTime.Format(time.RFC3339), now.Sub(r.StartTime).Hours(), r.Threshold)
+		if recordAnomaly("stale_session", &lotID, r.LicensePlate, detail, now) {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// detectDuplicatePlates 找出同一車牌同時在多個停車場進行中的紀錄
+func detectDuplicatePlates(now time.Time) (int, error) {
+	var rows []struct {
+		LicensePlate string
+		LotCount     int
+		LotIDs       string
+	}
+	err := database.DB.Table("rent").
+		Select("license_plate, COUNT(DISTINCT parking_lot_id) AS lot_count, GROUP_CONCAT(DISTINCT parking_lot_id) AS lot_ids").
+		Where(activeRentCondition).
+		Group("license_plate").
+		Having("COUNT(DISTINCT parking_lot_id) > 1").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to query duplicate plates: %w", err)
+	}
+
+	created := 0
+	for _, r := range rows {
+		detail := fmt.Sprintf("plate is active in %d parking lots at once: %s", r.LotCount, r.LotIDs)
+		if recordAnomaly("duplicate_plate", nil, r.LicensePlate, detail, now) {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// detectOverCapacity 找出進行中紀錄超過總車位數的停車場
+func detectOverCapacity(now time.Time) (int, error) {
+	counts, err := GetActiveCounts(nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+	lotIDs := make([]int, 0, len(counts))
+	for id := range counts {
+		lotIDs = append(lotIDs, id)
+	}
+
+	var lots []models.ParkingLot
+	if err := database.DB.Select("parking_lot_id, total_spots").
+		Where("parking_lot_id IN ?", lotIDs).
+		Find(&lots).Error; err != nil {
+		return 0, fmt.Errorf("failed to query over-capacity lots: %w", err)
+	}
+
+	created := 0
+	for _, lot := range lots {
+		active := counts[lot.ParkingLotID]
+		if active <= lot.TotalSpots {
+			continue
+		}
+		lotID := lot.ParkingLotID
+		detail := fmt.Sprintf("%d active sessions exceed total_spots %d", active, lot.TotalSpots)
+		if recordAnomaly("over_capacity", &lotID, "", detail, now) {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// recordAnomaly 寫入異常（同類型、同停車場、同車牌已有未處理項目時略過），回傳是否新增
+func recordAnomaly(anomalyType string, parkingLotID *int, licensePlate, detail string, now time.Time) bool {
+	query := database.DB.Model(&models.Anomaly{}).
+		Where("type = ? AND status = ? AND license_plate = ?", anomalyType, "open", licensePlate)
+	if parkingLotID != nil {
+		query = query.Where("parking_lot_id = ?", *parkingLotID)
+	} else {
+		query = query.Where("parking_lot_id IS NULL")
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		log.Printf("Failed to check existing anomaly: %v", err)
+		return false
+	}
+	if count > 0 {
+		return false
+	}
+
+	anomaly := models.Anomaly{
+		Type:         anomalyType,
+		ParkingLotID: parkingLotID,
+		LicensePlate: licensePlate,
+		Detail:       detail,
+		Status:       "open",
+		DetectedAt:   now,
+	}
+	if err := database.DB.Create(&anomaly).Error; err != nil {
+		log.Printf("Failed to record anomaly %s: %v", anomalyType, err)
+		return false
+	}
+
+	if anomalyNotifier != nil {
+		if err := anomalyNotifier.NotifyAnomaly(anomaly); err != nil {
+			log.Printf("Failed to notify anomaly %d: %v", anomaly.ID, err)
+		}
+	}
+	return true
+}
+
+// GetAnomalies 查詢異常清單
+func GetAnomalies(filter AnomalyFilter) ([]models.Anomaly, error) {
+	var anomalies []models.Anomaly
+
+	query := database.DB.Model(&models.Anomaly{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.ParkingLotID > 0 {
+		query = query.Where("parking_lot_id = ?", filter.ParkingLotID)
+	}
+
+	if err := query.Order("detected_at DESC").Find(&anomalies).Error; err != nil {
+		return nil, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	return anomalies, nil
+}
+
+// ResolveAnomaly 將異常標記為已處理
+func ResolveAnomaly(id int, note string, resolvedBy int) (*models.Anomaly, error) {
+	var anomaly models.Anomaly
+	if err := database.DB.First(&anomaly, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("anomaly %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to query anomaly %d: %w", id, err)
+	}
+	if anomaly.Status == "resolved" {
+		return nil, fmt.Errorf("anomaly %d is already resolved", id)
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&anomaly).Updates(map[string]interface{}{
+		"status":          "resolved",
+		"resolved_by":     resolvedBy,
+		"resolved_at":     now,
+		"resolution_note": note,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve anomaly %d: %w", id, err)
+	}
+
+	log.Printf("ANOMALY_RESOLVED | id=%d resolved_by=%d", id, resolvedBy)
+	return &anomaly, nil
+}
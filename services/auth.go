@@ -0,0 +1,456 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// token 預設有效期限
+const (
+	DefaultAccessTokenMinutes = 15
+	DefaultRefreshTokenDays   = 30
+)
+
+// sessionTouchInterval 工作階段最後使用時間的更新間隔，避免每個請求都寫入資料庫
+const sessionTouchInterval = time.Minute
+
+// TokenPair 登入或換發後回傳的 token
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	AccessExpiresAt  time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+}
+
+// accessTokenTTL 讀取 ACCESS_TOKEN_MINUTES，未設定時使用預設值
+func accessTokenTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_MINUTES")); err == nil && v > 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return DefaultAccessTokenMinutes * time.Minute
+}
+
+// refreshTokenTTL 讀取 REFRESH_TOKEN_DAYS，未設定時使用預設值
+func refreshTokenTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DAYS")); err == nil && v > 0 {
+		return time.Duration(v) * 24 * time.Hour
+	}
+	return DefaultRefreshTokenDays * 24 * time.Hour
+}
+
+// randomHex 產生 n 位元組的隨機十六進位字串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken 只保存 token 的 SHA-256，資料庫外洩時無法直接使用
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signAccessToken 簽發 access token；jti 供單一 token 撤銷，ver 對應會員的 token_version，sid 對應登入工作階段
+func signAccessToken(member *models.Member, sessionID string) (string, time.Time, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL())
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"member_id": member.MemberID,
+		"role":      member.Role,
+		"ver":       member.TokenVersion,
+		"sid":       sessionID,
+		"jti":       jti,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+	})
+	signed, err := token.SignedString(utils.JWTSecret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// newRefreshToken 在 tx 中建立 refresh token，回傳明文（只會出現在回應中）
+func newRefreshToken(tx *gorm.DB, memberID int, familyID string) (string, *models.RefreshToken, error) {
+	plain, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.RefreshToken{
+		MemberID:  memberID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(plain),
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+	return plain, record, nil
+}
+
+// truncateString 截斷字串至 n 個字元
+func truncateString(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// IssueTokenPair 登入成功後建立工作階段，並簽發 access token 與新的 refresh token 系列
+func IssueTokenPair(member *models.Member, userAgent, ipAddress string) (*TokenPair, error) {
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain string
+	var record *models.RefreshToken
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plain, record, err = newRefreshToken(tx, member.MemberID, familyID); err != nil {
+			return err
+		}
+		now := time.Now()
+		session := models.LoginSession{
+			SessionID:  familyID,
+			MemberID:   member.MemberID,
+			UserAgent:  truncateString(userAgent, 255),
+			IPAddress:  truncateString(ipAddress, 45),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  record.ExpiresAt,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return fmt.Errorf("failed to create login session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	access, accessExp, err := signAccessToken(member, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("SESSION_CREATED | member_id=%d session_id=%s ip=%s", member.MemberID, familyID, ipAddress)
+
+	return &TokenPair{
+		AccessToken:      access,
+		AccessExpiresAt:  accessExp,
+		RefreshToken:     plain,
+		RefreshExpiresAt: record.ExpiresAt,
+		SessionID:        familyID,
+	}, nil
+}
+
+// RefreshTokenPair 以 refresh token 換發新的 token；舊的 refresh token 立即失效
+// 已撤銷的 refresh token 被再次使用時，視為外洩並撤銷整個系列
+func RefreshTokenPair(refreshToken, ipAddress string) (*TokenPair, error) {
+	var current models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", hashToken(refreshToken)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("invalid refresh token")
+		}
+		return nil, fmt.Errorf("failed to query refresh token: %w", err)
+	}
+
+	now := time.Now()
+	if current.RevokedAt != nil {
+		if err := revokeSession(current.FamilyID, now); err != nil {
+			return nil, err
+		}
+		log.Printf("REFRESH_TOKEN_REUSE | member_id=%d family_id=%s token_id=%d", current.MemberID, current.FamilyID, current.ID)
+		return nil, fmt.Errorf("refresh token revoked")
+	}
+	if now.After(current.ExpiresAt) {
+		return nil, fmt.Errorf("refresh token expired")
+	}
+
+	var session models.LoginSession
+	if err := database.DB.Where("session_id = ?", current.FamilyID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("refresh token revoked")
+		}
+		return nil, fmt.Errorf("failed to query login session: %w", err)
+	}
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("refresh token revoked")
+	}
+
+	var member models.Member
+	if err := database.DB.First(&member, current.MemberID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("invalid refresh token")
+		}
+		return nil, fmt.Errorf("failed to get member %d: %w", current.MemberID, err)
+	}
+	// 政策改為強制兩步驟驗證後，未設定的會員須重新登入並完成設定
+	if err := requireSecondFactorPolicy(&member); err != nil {
+		return nil, err
+	}
+
+	var plain string
+	var next *models.RefreshToken
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plain, next, err = newRefreshToken(tx, member.MemberID, current.FamilyID); err != nil {
+			return err
+		}
+		// 條件式更新，避免同一個 refresh token 被同時使用兩次
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by": next.ID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("refresh token revoked")
+		}
+		if err := tx.Model(&models.LoginSession{}).
+			Where("session_id = ?", current.FamilyID).
+			Updates(map[string]interface{}{
+				"last_seen_at": now,
+				"ip_address":   truncateString(ipAddress, 45),
+				"expires_at":   next.ExpiresAt,
+			}).Error; err != nil {
+			return fmt.Errorf("failed to update login session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	access, accessExp, err := signAccessToken(&member, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("TOKEN_REFRESHED | member_id=%d family_id=%s", member.MemberID, current.FamilyID)
+	return &TokenPair{
+		AccessToken:      access,
+		AccessExpiresAt:  accessExp,
+		RefreshToken:     plain,
+		RefreshExpiresAt: next.ExpiresAt,
+		SessionID:        current.FamilyID,
+	}, nil
+}
+
+// revokeSession 撤銷工作階段及同一系列所有尚未撤銷的 refresh token
+func revokeSession(sessionID string, now time.Time) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.LoginSession{}).
+			Where("session_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke login session: %w", err)
+		}
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		return nil
+	})
+}
+
+// Logout 登出目前的裝置：撤銷這個 access token 以及所屬的工作階段
+func Logout(memberID int, jti, sessionID string, accessExpiresAt time.Time) error {
+	now := time.Now()
+	revoked := models.RevokedAccessToken{JTI: jti, MemberID: memberID, ExpiresAt: accessExpiresAt}
+	if err := database.DB.Save(&revoked).Error; err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	if sessionID != "" {
+		if err := revokeSession(sessionID, now); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("LOGOUT | member_id=%d session_id=%s", memberID, sessionID)
+	return nil
+}
+
+// RevokeAllMemberTokens 遞增會員的 token_version 並撤銷所有 refresh token，
+// 所有裝置上的 access token 立即失效（登出所有裝置、角色或密碼變更時使用）
+func RevokeAllMemberTokens(memberID int) error {
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Member{}).
+			Where("member_id = ?", memberID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return fmt.Errorf("failed to bump token version: %w", err)
+		}
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("member_id = ? AND revoked_at IS NULL", memberID).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		if err := tx.Model(&models.LoginSession{}).
+			Where("member_id = ? AND revoked_at IS NULL", memberID).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke login sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("TOKENS_REVOKED | member_id=%d", memberID)
+	return nil
+}
+
+// ValidateAccessToken 檢查 access token 是否仍有效：會員存在、角色與 token_version 未變、
+// jti 與所屬工作階段未被撤銷；通過時順便更新工作階段的最後使用時間
+func ValidateAccessToken(memberID int, role string, version int, jti, sessionID string) error {
+	var member struct {
+		Role         string
+		TokenVersion int
+	}
+	result := database.DB.Model(&models.Member{}).
+		Select("role, token_version").
+		Where("member_id = ?", memberID).
+		Limit(1).
+		Scan(&member)
+	if result.Error != nil {
+		return fmt.Errorf("failed to verify token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("token revoked: member not found")
+	}
+	if member.TokenVersion != version || member.Role != role {
+		return fmt.Errorf("token revoked")
+	}
+
+	var revoked int64
+	if err := database.DB.Model(&models.RevokedAccessToken{}).Where("jti = ?", jti).Count(&revoked).Error; err != nil {
+		return fmt.Errorf("failed to verify token: %w", err)
+	}
+	if revoked > 0 {
+		return fmt.Errorf("token revoked")
+	}
+
+	var session models.LoginSession
+	result = database.DB.Where("session_id = ? AND member_id = ?", sessionID, memberID).Limit(1).Find(&session)
+	if result.Error != nil {
+		return fmt.Errorf("failed to verify token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || session.RevokedAt != nil {
+		return fmt.Errorf("token revoked: session revoked")
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := database.DB.Model(&models.LoginSession{}).
+			Where("session_id = ?", sessionID).
+			Update("last_seen_at", now).Error; err != nil {
+			log.Printf("Failed to update last seen for session %s: %v", sessionID, err)
+		}
+	}
+	return nil
+}
+
+// GetMemberSessions 查詢會員目前有效的工作階段，依最後使用時間新到舊排序
+func GetMemberSessions(memberID int, currentSessionID string) ([]models.LoginSession, error) {
+	var count int64
+	if err := database.DB.Model(&models.Member{}).Where("member_id = ?", memberID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("member %d not found", memberID)
+	}
+
+	var sessions []models.LoginSession
+	if err := database.DB.
+		Where("member_id = ? AND revoked_at IS NULL AND expires_at > ?", memberID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sessions for member %d: %w", memberID, err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeMemberSession 撤銷會員的單一工作階段，該裝置的 access token 與 refresh token 立即失效
+func RevokeMemberSession(memberID int, sessionID string) error {
+	var session models.LoginSession
+	result := database.DB.Where("session_id = ? AND member_id = ?", sessionID, memberID).Limit(1).Find(&session)
+	if result.Error != nil {
+		return fmt.Errorf("failed to get session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	if err := revokeSession(sessionID, time.Now()); err != nil {
+		return err
+	}
+	log.Printf("SESSION_REVOKED | member_id=%d session_id=%s", memberID, sessionID)
+	return nil
+}
+
+// PurgeExpiredTokens 清除已過期的撤銷清單、refresh token、工作階段、密碼重設 token、驗證碼與登入挑戰（供 cron 呼叫）
+func PurgeExpiredTokens() {
+	now := time.Now()
+	revoked := database.DB.Where("expires_at < ?", now).Delete(&models.RevokedAccessToken{})
+	if revoked.Error != nil {
+		log.Printf("Failed to purge revoked access tokens: %v", revoked.Error)
+		return
+	}
+	refresh := database.DB.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	if refresh.Error != nil {
+		log.Printf("Failed to purge refresh tokens: %v", refresh.Error)
+		return
+	}
+	sessions := database.DB.Where("expires_at < ?", now).Delete(&models.LoginSession{})
+	if sessions.Error != nil {
+		log.Printf("Failed to purge login sessions: %v", sessions.Error)
+		return
+	}
+	resets := database.DB.Where("expires_at < ?", now).Delete(&models.PasswordResetToken{})
+	if resets.Error != nil {
+		log.Printf("Failed to purge password reset tokens: %v", resets.Error)
+		return
+	}
+	// 驗證碼保留一小時，供每小時寄送上限計算
+	codes := database.DB.Where("expires_at < ? AND created_at < ?", now, now.Add(-time.Hour)).Delete(&models.VerificationCode{})
+	if codes.Error != nil {
+		log.Printf("Failed to purge verification codes: %v", codes.Error)
+		return
+	}
+	challenges := database.DB.Where("expires_at < ?", now).Delete(&models.MFAChallenge{})
+	if challenges.Error != nil {
+		log.Printf("Failed to purge mfa challenges: %v", challenges.Error)
+		return
+	}
+	log.Printf("TOKEN_PURGE | revoked_access=%d refresh=%d sessions=%d password_resets=%d verification_codes=%d mfa_challenges=%d",
+		revoked.RowsAffected, refresh.RowsAffected, sessions.RowsAffected, resets.RowsAffected, codes.RowsAffected, challenges.RowsAffected)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authTables token 相關測試使用的資料表
+var authTables = []interface{}{
+	&models.Member{}, &models.MemberTOTP{}, &models.RefreshToken{},
+	&models.LoginSession{}, &models.RevokedAccessToken{},
+}
+
+// setupAuthTest 準備 token 相關資料表與簽章金鑰
+func setupAuthTest(t *testing.T) {
+	t.Helper()
+	setupTestDB(t, authTables...)
+	if len(utils.JWTSecret) == 0 {
+		utils.JWTSecret = []byte("test-jwt-secret-0123456789abcdef")
+	}
+}
+
+// seedMember 建立測試會員
+func seedMember(t *testing.T, n int, role string) *models.Member {
+	t.Helper()
+	member := models.Member{
+		Email: fmt.Sprintf("member%d@example.com", n),
+		Phone: fmt.Sprintf("09%08d", n),
+		Role:  role,
+		Name:  fmt.Sprintf("member %d", n),
+	}
+	if err := database.DB.Create(&member).Error; err != nil {
+		t.Fatalf("failed to seed member: %v", err)
+	}
+	return &member
+}
+
+// accessClaims 取出 access token 中 ValidateAccessToken 需要的欄位
+type accessClaims struct {
+	memberID int
+	role     string
+	version  int
+	jti      string
+	sid      string
+}
+
+func parseAccessClaims(t *testing.T, token string) accessClaims {
+	t.Helper()
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return utils.JWTSecret, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	return accessClaims{
+		memberID: int(claims["member_id"].(float64)),
+		role:     claims["role"].(string),
+		version:  int(claims["ver"].(float64)),
+		jti:      claims["jti"].(string),
+		sid:      claims["sid"].(string),
+	}
+}
+
+func (c accessClaims) validate() error {
+	return ValidateAccessToken(c.memberID, c.role, c.version, c.jti, c.sid)
+}
+
+// TestRefreshTokenReuseRevokesFamily 已換發過的 refresh token 再次使用時，整個系列與工作階段都應撤銷
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	setupAuthTest(t)
+	member := seedMember(t, 1, "renter")
+
+	first, err := IssueTokenPair(member, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshTokenPair(first.RefreshToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("first rotation failed: %v", err)
+	}
+	if second.SessionID != first.SessionID {
+		t.Errorf("rotation changed session %s -> %s", first.SessionID, second.SessionID)
+	}
+	if err := parseAccessClaims(t, second.AccessToken).validate(); err != nil {
+		t.Fatalf("rotated access token rejected: %v", err)
+	}
+
+	if _, err := RefreshTokenPair(first.RefreshToken, "127.0.0.1"); err == nil {
+		t.Fatal("reusing a rotated refresh token succeeded")
+	}
+	if _, err := RefreshTokenPair(second.RefreshToken, "127.0.0.1"); err == nil {
+		t.Error("latest refresh token still works after reuse was detected")
+	}
+	if err := parseAccessClaims(t, second.AccessToken).validate(); err == nil {
+		t.Error("access token still valid after its session was revoked")
+	}
+}
+
+// TestRoleChangeInvalidatesAccessTokens 角色變更後，已簽發的 access token 與 refresh token 都應失效
+func TestRoleChangeInvalidatesAccessTokens(t *testing.T) {
+	setupAuthTest(t)
+	member := seedMember(t, 2, "renter")
+
+	pair, err := IssueTokenPair(member, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := parseAccessClaims(t, pair.AccessToken)
+	if err := claims.validate(); err != nil {
+		t.Fatalf("fresh access token rejected: %v", err)
+	}
+
+	if err := UpdateMember(member.MemberID, map[string]interface{}{"role": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := claims.validate(); err == nil {
+		t.Error("access token still valid after role change")
+	}
+	if _, err := RefreshTokenPair(pair.RefreshToken, "127.0.0.1"); err == nil {
+		t.Error("refresh token still works after role change")
+	}
+}
+
+// TestLogoutRevokesAccessToken 登出後該 access token 立即失效，其他裝置不受影響
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	setupAuthTest(t)
+	member := seedMember(t, 3, "renter")
+
+	phone, err := IssueTokenPair(member, "phone", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, err := IssueTokenPair(member, "laptop", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := parseAccessClaims(t, phone.AccessToken)
+	if err := Logout(member.MemberID, claims.jti, claims.sid, phone.AccessExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	var revoked int64
+	if err := database.DB.Model(&models.RevokedAccessToken{}).Where("jti = ?", claims.jti).Count(&revoked).Error; err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 1 {
+		t.Errorf("jti %s not in revoked list", claims.jti)
+	}
+	if err := claims.validate(); err == nil {
+		t.Error("access token still valid after logout")
+	}
+	if _, err := RefreshTokenPair(phone.RefreshToken, "127.0.0.1"); err == nil {
+		t.Error("refresh token still works after logout")
+	}
+	if err := parseAccessClaims(t, laptop.AccessToken).validate(); err != nil {
+		t.Errorf("other device logged out: %v", err)
+	}
+}
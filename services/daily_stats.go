@@ -0,0 +1,282 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"project01/database"
+	"project01/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultDailyStatsRebuildDays 夜間重建涵蓋的天數（不含今天）
+const DefaultDailyStatsRebuildDays = 7
+
+// cstZone 每日彙總以 CST 切日
+var cstZone = time.FixedZone("CST", 8*60*60)
+
+// upsertDailyStatSQL 累加單日彙總；尖峰佔用取較大值
+const upsertDailyStatSQL = "INSERT INTO lot_daily_stat " +
+	"(parking_lot_id, day, sessions, revenue, stay_minutes, parked_minutes, peak_occupancy, updated_at) " +
+	"VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE " +
+	"sessions = sessions + VALUES(sessions), revenue = revenue + VALUES(revenue), " +
+	"stay_minutes = stay_minutes + VALUES(stay_minutes), parked_minutes = parked_minutes + VALUES(parked_minutes), " +
+	"peak_occupancy = GREATEST(peak_occupancy, VALUES(peak_occupancy)), updated_at = VALUES(updated_at)"
+
+// dayStart 取得 t 所在日（CST）的 00:00
+func dayStart(t time.Time) time.Time {
+	t = t.In(cstZone)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, cstZone)
+}
+
+// dailyStatsRebuildDays 讀取 DAILY_STATS_REBUILD_DAYS，未設定時使用預設值
+func dailyStatsRebuildDays() int {
+	if v, err := strconv.Atoi(os.Getenv("DAILY_STATS_REBUILD_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return DefaultDailyStatsRebuildDays
+}
+
+// recordDailyStatTx 在出場的同一個交易中將紀錄累加到每日彙總
+// 出場次數、費用與停車時間記在出場日，佔用分鐘數依跨越的每一天拆分
+// 與寫入出場時間同一個交易，重建時鎖住彙總列即可確保出場不會被重建覆蓋或重複累加
+func recordDailyStatTx(tx *gorm.DB, rent *models.Rent) error {
+	if rent.EndTime == nil || rent.Voided {
+		return nil
+	}
+	end := *rent.EndTime
+	cost := 0.0
+	if rent.TotalCost != nil {
+		cost = *rent.TotalCost
+	}
+
+	// 出場後的在場數（交易內已看不到這一筆），加一即為當天尖峰的候選值
+	var active int64
+	if err := tx.Model(&models.Rent{}).
+		Where("parking_lot_id = ?", rent.ParkingLotID).
+		Where(activeRentCondition).
+		Count(&active).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	endDay := dayStart(end)
+	for day := dayStart(rent.StartTime); !day.After(endDay); day = day.AddDate(0, 0, 1) {
+		parked := overlapMinutes(rent.StartTime, end, day, day.AddDate(0, 0, 1))
+		var sessions, stay int64
+		var revenue float64
+		peak := 0
+		if day.Equal(endDay) {
+			sessions, revenue, peak = 1, cost, int(active)+1
+			stay = int64(math.Round(end.Sub(rent.StartTime).Minutes()))
+		}
+		if err := tx.Exec(upsertDailyStatSQL, rent.ParkingLotID, day.Format("2006-01-02"),
+			sessions, revenue, stay, parked, peak, now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlapMinutes [start, end) 與 [from, to) 重疊的分鐘數
+func overlapMinutes(start, end, from, to time.Time) int64 {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return int64(math.Round(end.Sub(start).Minutes()))
+}
+
+// RunDailyStatsRebuild 重建最近幾天（不含今天）的每日彙總（供 cron 呼叫）
+func RunDailyStatsRebuild() {
+	today := dayStart(time.Now())
+	from := today.AddDate(0, 0, -dailyStatsRebuildDays())
+	if _, err := RebuildDailyStats(from, today.AddDate(0, 0, -1)); err != nil {
+		log.Printf("Failed to rebuild daily stats: %v", err)
+	}
+}
+
+// RebuildDailyStats 由 rent 重新計算 from 到 to（含）每一天的彙總並取代既有資料，回傳寫入筆數
+func RebuildDailyStats(from, to time.Time) (int, error) {
+	return rebuildDailyStats(from, to, nil)
+}
+
+// rebuildDailyStats 重新計算區間內的彙總，lotIDs 為空時包含所有停車場
+func rebuildDailyStats(from, to time.Time, lotIDs []int) (int, error) {
+	from, to = dayStart(from), dayStart(to)
+	if to.Before(from) {
+		return 0, fmt.Errorf("invalid date range: to is before from")
+	}
+
+	total := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		n, err := rebuildDailyStatsForDay(day, lotIDs)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	log.Printf("DAILY_STATS_REBUILT | from=%s to=%s lots=%v rows=%d",
+		from.Format("2006-01-02"), to.Format("2006-01-02"), lotIDs, total)
+	return total, nil
+}
+
+// refreshDailyStatsForRentChange 管理員修改停車紀錄後，重建修改前後涉及的停車場與日期
+// 每日彙總只在出場時累加，事後的更正須重建才會反映在報表與儀表板
+func refreshDailyStatsForRentChange(before, after models.Rent) {
+	now := time.Now()
+	from := before.StartTime
+	if after.StartTime.Before(from) {
+		from = after.StartTime
+	}
+	to := from
+	for _, r := range []models.Rent{before, after} {
+		end := now
+		if r.EndTime != nil {
+			end = *r.EndTime
+		}
+		if end.After(to) {
+			to = end
+		}
+	}
+	if to.After(now) {
+		to = now
+	}
+
+	lotIDs := []int{before.ParkingLotID}
+	if after.ParkingLotID != before.ParkingLotID {
+		lotIDs = append(lotIDs, after.ParkingLotID)
+	}
+	if _, err := rebuildDailyStats(from, to, lotIDs); err != nil {
+		log.Printf("DAILY_STAT_REFRESH_FAILED | license_plate=%s lots=%v err=%v", after.LicensePlate, lotIDs, err)
+	}
+}
+
+// rebuildDailyStatsForDay 重新計算單日各停車場的彙總，lotIDs 為空時包含所有停車場
+// 佔用分鐘數只計入已結束的紀錄（進行中的紀錄於出場時才累加），尖峰佔用則包含進行中的紀錄
+// 先鎖住當天的彙總列再讀取停車紀錄：出場的累加與出場寫入在同一個交易，
+// 因此出場不是已提交並被重建讀到，就是等重建提交後才累加，不會遺失或重複
+func rebuildDailyStatsForDay(day time.Time, lotIDs []int) (int, error) {
+	var n int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		n, err = rebuildDailyStatsForDayTx(tx, day, lotIDs)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func rebuildDailyStatsForDayTx(tx *gorm.DB, day time.Time, lotIDs []int) (int, error) {
+	dayEnd := day.AddDate(0, 0, 1)
+	dayKey := day.Format("2006-01-02")
+
+	// 鎖住當天的彙總列（不存在時鎖住索引間隙），讓同時出場的累加等待重建完成
+	var locked []models.LotDailyStat
+	lock := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("day = ?", dayKey)
+	if len(lotIDs) > 0 {
+		lock = lock.Where("parking_lot_id IN ?", lotIDs)
+	}
+	if err := lock.Find(&locked).Error; err != nil {
+		return 0, fmt.Errorf("failed to lock daily stats for %s: %w", dayKey, err)
+	}
+
+	var rents []struct {
+		ParkingLotID int
+		StartTime    time.Time
+		EndTime      *time.Time
+		TotalCost    *float64
+	}
+	query := tx.Model(&models.Rent{}).
+		Select("parking_lot_id, start_time, end_time, total_cost").
+		Where("voided = ? AND start_time < ? AND (end_time IS NULL OR end_time >= ?)", false, dayEnd, day)
+	if len(lotIDs) > 0 {
+		query = query.Where("parking_lot_id IN ?", lotIDs)
+	}
+	err := query.Scan(&rents).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to query rents for %s: %w", dayKey, err)
+	}
+
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	stats := map[int]*models.LotDailyStat{}
+	events := map[int][]event{}
+	now := time.Now()
+
+	for _, r := range rents {
+		s := stats[r.ParkingLotID]
+		if s == nil {
+			s = &models.LotDailyStat{ParkingLotID: r.ParkingLotID, Day: day, UpdatedAt: now}
+			stats[r.ParkingLotID] = s
+		}
+
+		start := r.StartTime
+		if start.Before(day) {
+			start = day
+		}
+		events[r.ParkingLotID] = append(events[r.ParkingLotID], event{start, 1})
+
+		if r.EndTime == nil {
+			continue
+		}
+		s.ParkedMinutes += overlapMinutes(r.StartTime, *r.EndTime, day, dayEnd)
+		if r.EndTime.Before(dayEnd) {
+			events[r.ParkingLotID] = append(events[r.ParkingLotID], event{*r.EndTime, -1})
+			s.Sessions++
+			s.StayMinutes += int64(math.Round(r.EndTime.Sub(r.StartTime).Minutes()))
+			if r.TotalCost != nil {
+				s.Revenue += *r.TotalCost
+			}
+		}
+	}
+
+	rows := make([]models.LotDailyStat, 0, len(stats))
+	for lotID, s := range stats {
+		// 同一時間先出場再進場，避免高估尖峰
+		ev := events[lotID]
+		sort.Slice(ev, func(i, j int) bool {
+			if !ev[i].at.Equal(ev[j].at) {
+				return ev[i].at.Before(ev[j].at)
+			}
+			return ev[i].delta < ev[j].delta
+		})
+		current := 0
+		for _, e := range ev {
+			current += e.delta
+			s.PeakOccupancy = max(s.PeakOccupancy, current)
+		}
+		s.Revenue = math.Round(s.Revenue*100) / 100
+		rows = append(rows, *s)
+	}
+
+	stale := tx.Where("day = ?", dayKey)
+	if len(lotIDs) > 0 {
+		stale = stale.Where("parking_lot_id IN ?", lotIDs)
+	}
+	if err := stale.Delete(&models.LotDailyStat{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to clear daily stats for %s: %w", dayKey, err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+		return 0, fmt.Errorf("failed to write daily stats for %s: %w", dayKey, err)
+	}
+	return len(rows), nil
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"project01/database"
+	"project01/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDashboardCacheSeconds 儀表板彙總的快取秒數
+const DefaultDashboardCacheSeconds = 300
+
+// DashboardMetrics 儀表板指標
+type DashboardMetrics struct {
+	TotalSpots         int     `json:"total_spots"`
+	Sessions           int64   `json:"sessions"`           // 區間內進場次數
+	CompletedSessions  int64   `json:"completed_sessions"` // 區間內出場次數
+	OccupancyRate      float64 `json:"occupancy_rate"`     // 佔用車位時數 / 可用車位時數
+	TurnoverPerSpot    float64 `json:"turnover_per_spot"`  // 每車位進場次數
+	AvgStayMinutes     float64 `json:"avg_stay_minutes"`
+	MedianStayMinutes  float64 `json:"median_stay_minutes"`
+	Revenue            float64 `json:"revenue"`
+	RevenuePerSpotHour float64 `json:"revenue_per_spot_hour"` // 每可用車位小時收入
+	PeakHour           *int    `json:"peak_hour"`             // 進場最多的小時（0~23），無資料為 null
+	PeakHourArrivals   int64   `json:"peak_hour_arrivals"`
+	PeakOccupancy      int     `json:"peak_occupancy,omitempty"` // 區間內單日最高同時在場數（僅各停車場）
+	CurrentlyParked    int     `json:"currently_parked"`
+}
+
+// DashboardLot 單一停車場的儀表板指標
+type DashboardLot struct {
+	ParkingLotID int    `json:"parking_lot_id"`
+	Address      string `json:"address"`
+	DashboardMetrics
+}
+
+// Dashboard 管理員儀表板
+type Dashboard struct {
+	StartDate   string           `json:"start_date"`
+	EndDate     string           `json:"end_date"`
+	GeneratedAt string           `json:"generated_at"`
+	Cached      bool             `json:"cached"`
+	Overall     DashboardMetrics `json:"overall"`
+	Lots        []DashboardLot   `json:"lots"`
+}
+
+// dashboardAggregate 區間內單一停車場（或全部，ParkingLotID 為 0）的彙總值
+type dashboardAggregate struct {
+	ParkingLotID    int
+	Completed       int64
+	OccupiedSeconds float64
+	StaySeconds     float64
+	Revenue         float64
+	PeakOccupancy   int
+}
+
+type dashboardCacheEntry struct {
+	dashboard Dashboard
+	expiresAt time.Time
+}
+
+var (
+	dashboardCacheMu sync.Mutex
+	dashboardCache   = map[string]dashboardCacheEntry{}
+)
+
+// dashboardCacheTTL 讀取 DASHBOARD_CACHE_SECONDS，未設定時使用預設值
+func dashboardCacheTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("DASHBOARD_CACHE_SECONDS")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Second
+	}
+	return DefaultDashboardCacheSeconds * time.Second
+}
+
+// GetDashboard 取得區間內各停車場與整體的儀表板指標
+// 彙總值會快取，目前在場車輛數每次即時查詢；refresh 為 true 時略過快取
+func GetDashboard(lotIDs []int, startDate, endDate time.Time, refresh bool) (*Dashboard, error) {
+	if endDate.Before(startDate) {
+		return nil, fmt.Errorf("invalid date range: end_date is before start_date")
+	}
+
+	key := dashboardCacheKey(lotIDs, startDate, endDate)
+	now := time.Now()
+
+	dashboardCacheMu.Lock()
+	entry, ok := dashboardCache[key]
+	dashboardCacheMu.Unlock()
+
+	var dashboard Dashboard
+	if ok && !refresh && now.Before(entry.expiresAt) {
+		dashboard = entry.dashboard
+		dashboard.Lots = append([]DashboardLot(nil), entry.dashboard.Lots...)
+		dashboard.Cached = true
+	} else {
+		built, err := buildDashboard(lotIDs, startDate, endDate, now)
+		if err != nil {
+			return nil, err
+		}
+		dashboard = *built
+
+		if ttl := dashboardCacheTTL(); ttl > 0 {
+			cached := dashboard
+			cached.Lots = append([]DashboardLot(nil), dashboard.Lots...)
+			dashboardCacheMu.Lock()
+			pruneDashboardCache(now)
+			dashboardCache[key] = dashboardCacheEntry{dashboard: cached, expiresAt: now.Add(ttl)}
+			dashboardCacheMu.Unlock()
+		}
+	}
+
+	ids := make([]int, len(dashboard.Lots))
+	for i, lot := range dashboard.Lots {
+		ids[i] = lot.ParkingLotID
+	}
+	counts, err := GetActiveCounts(ids)
+	if err != nil {
+		return nil, err
+	}
+	dashboard.Overall.CurrentlyParked = 0
+	for i := range dashboard.Lots {
+		dashboard.Lots[i].CurrentlyParked = counts[dashboard.Lots[i].ParkingLotID]
+		dashboard.Overall.CurrentlyParked += dashboard.Lots[i].CurrentlyParked
+	}
+
+	return &dashboard, nil
+}
+
+// dashboardCacheKey 以停車場（排序後）與日期區間組成快取鍵
+func dashboardCacheKey(lotIDs []int, startDate, endDate time.Time) string {
+	ids := append([]int(nil), lotIDs...)
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return startDate.Format("2006-01-02") + "|" + endDate.Format("2006-01-02") + "|" + strings.Join(parts, ",")
+}
+
+// pruneDashboardCache 移除過期的快取項目（呼叫端須持有鎖）
+func pruneDashboardCache(now time.Time) {
+	for key, entry := range dashboardCache {
+		if !now.Before(entry.expiresAt) {
+			delete(dashboardCache, key)
+		}
+	}
+}
+
+// buildDashboard 由每日彙總、rent 與 parking_lot 計算儀表板指標（不含目前在場車輛數）
+func buildDashboard(lotIDs []int, startDate, endDate, now time.Time) (*Dashboard, error) {
+	var lots []models.ParkingLot
+	query := database.DB.Model(&models.ParkingLot{})
+	if len(lotIDs) > 0 {
+		query = query.Where("parking_lot_id IN ?", lotIDs)
+	} else {
+		query = query.Where("archived_at IS NULL")
+	}
+	if err := query.Order("parking_lot_id").Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("failed to query parking lots: %w", err)
+	}
+
+	ids := make([]int, len(lots))
+	for i, lot := range lots {
+		ids[i] = lot.ParkingLotID
+	}
+
+	dashboard := &Dashboard{
+		StartDate:   startDate.Format("2006-01-02"),
+		EndDate:     endDate.Format("2006-01-02"),
+		GeneratedAt: now.Format(time.RFC3339),
+		Lots:        make([]DashboardLot, len(lots)),
+	}
+	if len(lots) == 0 {
+		return dashboard, nil
+	}
+
+	// 區間尚未結束時，只計算到目前為止
+	periodEnd := endDate.Add(time.Second)
+	if now.Before(periodEnd) {
+		periodEnd = now
+	}
+	periodHours := math.Max(periodEnd.Sub(startDate).Hours(), 0)
+
+	aggregates, err := queryDashboardAggregates(ids, startDate, endDate, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	medians, overallMedian, err := queryMedianStays(ids, startDate, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	peaks, err := queryArrivalsByHour(ids, startDate, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var overall dashboardAggregate
+	overallPeaks := [24]int64{}
+	totalSpots := 0
+	for i, lot := range lots {
+		agg := aggregates[lot.ParkingLotID]
+		metrics := buildDashboardMetrics(agg, lot.TotalSpots, periodHours, medians[lot.ParkingLotID], peaks[lot.ParkingLotID])
+		dashboard.Lots[i] = DashboardLot{ParkingLotID: lot.ParkingLotID, Address: lot.Address, DashboardMetrics: metrics}
+
+		overall.Completed += agg.Completed
+		overall.OccupiedSeconds += agg.OccupiedSeconds
+		overall.StaySeconds += agg.StaySeconds
+		overall.Revenue += agg.Revenue
+		for h, n := range peaks[lot.ParkingLotID] {
+			overallPeaks[h] += n
+		}
+		totalSpots += lot.TotalSpots
+	}
+	dashboard.Overall = buildDashboardMetrics(overall, totalSpots, periodHours, overallMedian, overallPeaks)
+
+	log.Printf("DASHBOARD_BUILD | lots=%d sessions=%d revenue=%.2f from=%s to=%s",
+		len(lots), dashboard.Overall.Sessions, overall.Revenue, dashboard.StartDate, dashboard.EndDate)
+	return dashboard, nil
+}
+
+// queryDashboardAggregates 由每日彙總取得各停車場的出場數、停車時間、收入與佔用分鐘數，
+// 再加上進行中紀錄與區間重疊的佔用時間（每日彙總只在出場時累加）
+func queryDashboardAggregates(lotIDs []int, startDate, endDate, periodEnd time.Time) (map[int]dashboardAggregate, error) {
+	var rows []dashboardAggregate
+	err := database.DB.Model(&models.LotDailyStat{}).
+		Select("parking_lot_id, COALESCE(SUM(sessions), 0) AS completed, "+
+			"COALESCE(SUM(parked_minutes), 0) * 60 AS occupied_seconds, "+
+			"COALESCE(SUM(stay_minutes), 0) * 60 AS stay_seconds, "+
+			"COALESCE(SUM(revenue), 0) AS revenue, COALESCE(MAX(peak_occupancy), 0) AS peak_occupancy").
+		Where("parking_lot_id IN ? AND day BETWEEN ? AND ?",
+			lotIDs, startDate.Format("2006-01-02"), endDate.Format("2006-01-02")).
+		Group("parking_lot_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate dashboard: %w", err)
+	}
+
+	var active []struct {
+		ParkingLotID    int
+		OccupiedSeconds float64
+	}
+	err = database.DB.Model(&models.Rent{}).
+		Select("parking_lot_id, COALESCE(SUM(TIMESTAMPDIFF(SECOND, GREATEST(start_time, ?), ?)), 0) AS occupied_seconds",
+			startDate, periodEnd).
+		Where("voided = ? AND end_time IS NULL AND parking_lot_id IN ? AND start_time < ?", false, lotIDs, periodEnd).
+		Group("parking_lot_id").
+		Scan(&active).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate active sessions: %w", err)
+	}
+
+	result := make(map[int]dashboardAggregate, len(rows))
+	for _, r := range rows {
+		result[r.ParkingLotID] = r
+	}
+	for _, a := range active {
+		agg := result[a.ParkingLotID]
+		agg.ParkingLotID = a.ParkingLotID
+		agg.OccupiedSeconds += a.OccupiedSeconds
+		result[a.ParkingLotID] = agg
+	}
+	return result, nil
+}
+
+// queryMedianStays 以視窗函式計算區間內出場紀錄的停車分鐘數中位數（各停車場與整體）
+func queryMedianStays(lotIDs []int, from, periodEnd time.Time) (map[int]float64, float64, error) {
+	const stays = "SELECT parking_lot_id, TIMESTAMPDIFF(SECOND, start_time, end_time) / 60 AS minutes FROM rent " +
+		"WHERE voided = false AND parking_lot_id IN ? AND end_time >= ? AND end_time < ?"
+
+	var rows []struct {
+		ParkingLotID int
+		Median       float64
+	}
+	err := database.DB.Raw("SELECT parking_lot_id, AVG(minutes) AS median FROM ("+
+		"SELECT parking_lot_id, minutes, "+
+		"ROW_NUMBER() OVER (PARTITION BY parking_lot_id ORDER BY minutes) AS rn, "+
+		"COUNT(*) OVER (PARTITION BY parking_lot_id) AS cnt FROM ("+stays+") s"+
+		") t WHERE rn IN (FLOOR((cnt + 1) / 2), CEIL((cnt + 1) / 2)) GROUP BY parking_lot_id",
+		lotIDs, from, periodEnd).Scan(&rows).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query median stay: %w", err)
+	}
+
+	var overall struct{ Median float64 }
+	err = database.DB.Raw("SELECT COALESCE(AVG(minutes), 0) AS median FROM ("+
+		"SELECT minutes, ROW_NUMBER() OVER (ORDER BY minutes) AS rn, COUNT(*) OVER () AS cnt FROM ("+stays+") s"+
+		") t WHERE rn IN (FLOOR((cnt + 1) / 2), CEIL((cnt + 1) / 2))",
+		lotIDs, from, periodEnd).Scan(&overall).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query median stay: %w", err)
+	}
+
+	result := make(map[int]float64, len(rows))
+	for _, r := range rows {
+		result[r.ParkingLotID] = r.Median
+	}
+	return result, overall.Median, nil
+}
+
+// queryArrivalsByHour 各停車場區間內每個小時（0~23）的進場次數
+func queryArrivalsByHour(lotIDs []int, from, periodEnd time.Time) (map[int][24]int64, error) {
+	var rows []struct {
+		ParkingLotID int
+		Hour         int
+		Arrivals     int64
+	}
+	err := database.DB.Model(&models.Rent{}).
+		Select("parking_lot_id, HOUR(start_time) AS hour, COUNT(*) AS arrivals").
+		Where("voided = ? AND parking_lot_id IN ? AND start_time >= ? AND start_time < ?", false, lotIDs, from, periodEnd).
+		Group("parking_lot_id, hour").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query arrivals by hour: %w", err)
+	}
+
+	result := map[int][24]int64{}
+	for _, r := range rows {
+		if r.Hour < 0 || r.Hour > 23 {
+			continue
+		}
+		hours := result[r.ParkingLotID]
+		hours[r.Hour] = r.Arrivals
+		result[r.ParkingLotID] = hours
+	}
+	return result, nil
+}
+
+// buildDashboardMetrics 由彙總值計算各項比率；進場次數為各小時進場數加總
+func buildDashboardMetrics(agg dashboardAggregate, totalSpots int, periodHours, medianMinutes float64, arrivals [24]int64) DashboardMetrics {
+	m := DashboardMetrics{
+		TotalSpots:        totalSpots,
+		PeakOccupancy:     agg.PeakOccupancy,
+		CompletedSessions: agg.Completed,
+		Revenue:           math.Round(agg.Revenue*100) / 100,
+		MedianStayMinutes: math.Round(medianMinutes*10) / 10,
+	}
+
+	for h, n := range arrivals {
+		m.Sessions += n
+		if n > m.PeakHourArrivals {
+			hour := h
+			m.PeakHour = &hour
+			m.PeakHourArrivals = n
+		}
+	}
+
+	spotHours := float64(totalSpots) * periodHours
+	if spotHours > 0 {
+		m.OccupancyRate = round3(agg.OccupiedSeconds / 3600 / spotHours)
+		m.RevenuePerSpotHour = math.Round(agg.Revenue/spotHours*100) / 100
+	}
+	if totalSpots > 0 {
+		m.TurnoverPerSpot = math.Round(float64(m.Sessions)/float64(totalSpots)*100) / 100
+	}
+	if agg.Completed > 0 {
+		m.AvgStayMinutes = math.Round(agg.StaySeconds/60/float64(agg.Completed)*10) / 10
+	}
+	return m
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"project01/database"
+	"project01/utils"
+	"strings"
+	"time"
+)
+
+// 匯出格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// csvFlushEvery CSV 每寫幾列清一次緩衝，讓資料持續送出
+const csvFlushEvery = 500
+
+// ExportColumn 可匯出的欄位
+type ExportColumn struct {
+	Key    string
+	Header string
+	value  func(r *exportRentRow) interface{}
+}
+
+// exportRentRow 匯出時逐列掃描的租用紀錄（含停車場地址）
+type exportRentRow struct {
+	LicensePlate string
+	ParkingLotID int
+	Address      string
+	StartTime    time.Time
+	EndTime      *time.Time
+	TotalCost    *float64
+	FuzzyMatched bool
+	Voided       bool
+}
+
+var exportColumns = []ExportColumn{
+	{"license_plate", "車牌", func(r *exportRentRow) interface{} { return r.LicensePlate }},
+	{"parking_lot_id", "停車場 ID", func(r *exportRentRow) interface{} { return r.ParkingLotID }},
+	{"address", "停車場地址", func(r *exportRentRow) interface{} { return r.Address }},
+	{"start_time", "進場時間", func(r *exportRentRow) interface{} { return r.StartTime.Format("2006-01-02 15:04:05") }},
+	{"end_time", "出場時間", func(r *exportRentRow) interface{} {
+		if r.EndTime == nil {
+			return nil
+		}
+		return r.EndTime.Format("2006-01-02 15:04:05")
+	}},
+	{"duration_minutes", "停車分鐘數", func(r *exportRentRow) interface{} {
+		if r.EndTime == nil {
+			return nil
+		}
+		return int(math.Ceil(r.EndTime.Sub(r.StartTime).Minutes()))
+	}},
+	{"total_cost", "費用", func(r *exportRentRow) interface{} {
+		if r.TotalCost == nil {
+			return nil
+		}
+		return math.Round(*r.TotalCost*100) / 100
+	}},
+	{"fuzzy_matched", "模糊比對出場", func(r *exportRentRow) interface{} { return r.FuzzyMatched }},
+	{"voided", "已作廢", func(r *exportRentRow) interface{} { return r.Voided }},
+}
+
+// 各匯出預設欄位；收入匯出只含已結算紀錄，不需作廢欄位
+var (
+	DefaultIncomeExportColumns      = []string{"parking_lot_id", "address", "license_plate", "start_time", "end_time", "duration_minutes", "total_cost"}
+	DefaultRentHistoryExportColumns = []string{"license_plate", "parking_lot_id", "address", "start_time", "end_time", "duration_minutes", "total_cost", "fuzzy_matched", "voided"}
+)
+
+// ExportFilter 匯出條件
+type ExportFilter struct {
+	Format        string
+	Columns       []string // 空值代表預設欄位
+	ParkingLotIDs []int    // 空值代表所有停車場
+	MemberID      int      // 0 代表不限會員
+	StartDate     time.Time
+	EndDate       time.Time // 含當天
+}
+
+// ResolveExportColumns 依欄位代碼取得匯出欄位，未知欄位回傳錯誤
+func ResolveExportColumns(keys []string, defaults []string) ([]ExportColumn, error) {
+	if len(keys) == 0 {
+		keys = defaults
+	}
+	byKey := make(map[string]ExportColumn, len(exportColumns))
+	for _, col := range exportColumns {
+		byKey[col.Key] = col
+	}
+
+	cols := make([]ExportColumn, 0, len(keys))
+	seen := map[string]bool{}
+	for _, key := range keys {
+		col, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid export column: %s", key)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// tableWriter 匯出格式共用的逐列寫入介面
+type tableWriter interface {
+	WriteRow(cells []interface{}) error
+	Close() error
+}
+
+// csvTableWriter 以 UTF-8 BOM 開頭的 CSV，Excel 開啟時才能正確辨識中文
+type csvTableWriter struct {
+	buf  *bufio.Writer
+	w    *csv.Writer
+	rows int
+}
+
+func newCSVTableWriter(out io.Writer) (*csvTableWriter, error) {
+	buf := bufio.NewWriter(out)
+	if _, err := buf.WriteString("\xEF\xBB\xBF"); err != nil {
+		return nil, err
+	}
+	return &csvTableWriter{buf: buf, w: csv.NewWriter(buf)}, nil
+}
+
+func (t *csvTableWriter) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+		case float64:
+			record[i] = fmt.Sprintf("%.2f", v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	if err := t.w.Write(record); err != nil {
+		return err
+	}
+	t.rows++
+	if t.rows%csvFlushEvery == 0 {
+		t.w.Flush()
+		if err := t.w.Error(); err != nil {
+			return err
+		}
+		return t.buf.Flush()
+	}
+	return nil
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		return err
+	}
+	return t.buf.Flush()
+}
+
+// xlsxTableWriter 布林值在 XLSX 以「是／否」呈現
+type xlsxTableWriter struct {
+	*utils.XLSXWriter
+}
+
+func (t xlsxTableWriter) WriteRow(cells []interface{}) error {
+	for i, cell := range cells {
+		if b, ok := cell.(bool); ok {
+			if b {
+				cells[i] = "是"
+			} else {
+				cells[i] = "否"
+			}
+		}
+	}
+	return t.XLSXWriter.WriteRow(cells)
+}
+
+func newTableWriter(format string, out io.Writer, sheetName string) (tableWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVTableWriter(out)
+	case ExportFormatXLSX:
+		w, err := utils.NewXLSXWriter(out, sheetName)
+		if err != nil {
+			return nil, err
+		}
+		return xlsxTableWriter{w}, nil
+	default:
+		return nil, fmt.Errorf("invalid export format: %s", format)
+	}
+}
+
+// StreamIncomeExport 逐列匯出區間內已結算、未作廢的收入明細（依出場時間篩選）
+func StreamIncomeExport(out io.Writer, filter ExportFilter) (int, error) {
+	return streamRentExport(out, filter, true)
+}
+
+// StreamRentHistoryExport 逐列匯出區間內的租用紀錄（依進場時間篩選，含進行中與已作廢）
+func StreamRentHistoryExport(out io.Writer, filter ExportFilter) (int, error) {
+	return streamRentExport(out, filter, false)
+}
+
+// streamRentExport 以資料庫游標逐列讀取並寫出，不將整份結果載入記憶體
+func streamRentExport(out io.Writer, filter ExportFilter, incomeOnly bool) (int, error) {
+	defaults, sheetName := DefaultRentHistoryExportColumns, "租用紀錄"
+	if incomeOnly {
+		defaults, sheetName = DefaultIncomeExportColumns, "收入明細"
+	}
+	cols, err := ResolveExportColumns(filter.Columns, defaults)
+	if err != nil {
+		return 0, err
+	}
+	if filter.EndDate.Before(filter.StartDate) {
+		return 0, fmt.Errorf("invalid date range: end_date is before start_date")
+	}
+
+	query := database.DB.Table("rent").
+		Select("rent.license_plate, rent.parking_lot_id, COALESCE(parking_lot.address, '') AS address, " +
+			"rent.start_time, rent.end_time, rent.total_cost, rent.fuzzy_matched, rent.voided").
+		Joins("LEFT JOIN parking_lot ON parking_lot.parking_lot_id = rent.parking_lot_id")
+	if incomeOnly {
+		query = query.Where("rent.voided = ? AND rent.end_time IS NOT NULL AND rent.end_time BETWEEN ? AND ?",
+			false, filter.StartDate, filter.EndDate).
+			Order("rent.end_time, rent.parking_lot_id, rent.license_plate")
+	} else {
+		query = query.Where("rent.start_time BETWEEN ? AND ?", filter.StartDate, filter.EndDate).
+			Order("rent.start_time, rent.license_plate")
+	}
+	if len(filter.ParkingLotIDs) > 0 {
+		query = query.Where("rent.parking_lot_id IN ?", filter.ParkingLotIDs)
+	}
+	if filter.MemberID > 0 {
+		query = query.Where("rent.member_id = ?", filter.MemberID)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query rent records: %w", err)
+	}
+	defer rows.Close()
+
+	tw, err := newTableWriter(filter.Format, out, sheetName)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]interface{}, len(cols))
+	for i, col := range cols {
+		header[i] = col.Header
+	}
+	if err := tw.WriteRow(header); err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+
+	count := 0
+	cells := make([]interface{}, len(cols))
+	for rows.Next() {
+		var r exportRentRow
+		if err := database.DB.ScanRows(rows, &r); err != nil {
+			return count, fmt.Errorf("failed to scan rent record: %w", err)
+		}
+		for i, col := range cols {
+			cells[i] = col.value(&r)
+		}
+		if err := tw.WriteRow(cells); err != nil {
+			return count, fmt.Errorf("failed to write export: %w", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to read rent records: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return count, fmt.Errorf("failed to finish export: %w", err)
+	}
+
+	keys := make([]string, len(cols))
+	for i, col := range cols {
+		keys[i] = col.Key
+	}
+	log.Printf("RENT_EXPORT | income_only=%t format=%s rows=%d member_id=%d lots=%v columns=%s",
+		incomeOnly, filter.Format, count, filter.MemberID, filter.ParkingLotIDs, strings.Join(keys, ","))
+	return count, nil
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"project01/database"
+	"project01/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// forecastHistoryWeeks 預測時回看的週數（每週取同星期、同時刻一個樣本）
+const forecastHistoryWeeks = 8
+
+// forecastDecayHours 目前佔用偏差對預測的影響隨時間衰減的時間常數
+const forecastDecayHours = 3.0
+
+// LotForecast 單一停車場的車位預測
+type LotForecast struct {
+	ParkingLotID         int      `json:"parking_lot_id"`
+	Address              string   `json:"address"`
+	TotalSpots           int      `json:"total_spots"`
+	ArrivalTime          string   `json:"arrival_time"`
+	PredictedFreeSpots   int      `json:"predicted_free_spots"`
+	PredictedOccupied    float64  `json:"predicted_occupied"`
+	Confidence           float64  `json:"confidence"`            // 0~1
+	ProbabilityAvailable float64  `json:"probability_available"` // 抵達時至少有一個空位的機率
+	CurrentOccupied      int      `json:"current_occupied"`
+	HistoricalSamples    int      `json:"historical_samples"`
+	OpenAtArrival        bool     `json:"open_at_arrival"`
+	DistanceKm           *float64 `json:"distance_km,omitempty"`
+}
+
+// lotHistory 同星期、同時刻的歷史佔用樣本
+type lotHistory struct {
+	atArrival []float64 // 過去各週在抵達時刻的佔用數
+	atNow     []float64 // 過去各週在目前時刻的佔用數
+}
+
+// ForecastParkingLot 預測單一停車場在抵達時間的剩餘車位
+func ForecastParkingLot(parkingLotID int, arrival time.Time) (*LotForecast, error) {
+	var lot models.ParkingLot
+	if err := database.DB.First(&lot, parkingLotID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("parking lot %d not found", parkingLotID)
+		}
+		return nil, fmt.Errorf("failed to get parking lot %d: %w", parkingLotID, err)
+	}
+	if lot.IsArchived() {
+		return nil, fmt.Errorf("parking lot %d is archived", parkingLotID)
+	}
+
+	forecasts, err := ForecastParkingLots([]models.ParkingLot{lot}, arrival)
+	if err != nil {
+		return nil, err
+	}
+	return &forecasts[0], nil
+}
+
+// ForecastNearbyParkingLots 預測查詢點附近所有停車場在抵達時間的剩餘車位
+func ForecastNearbyParkingLots(latitude, longitude, radius float64, arrival time.Time) ([]LotForecast, error) {
+	if radius <= 0 {
+		radius = 3.0
+	}
+	if radius > 50 {
+		radius = 50.0
+	}
+
+	lots, err := QueryNearbyParkingLots(latitude, longitude, radius)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lots {
+		distance := math.Round(haversineKm(latitude, longitude, lots[i].Latitude, lots[i].Longitude)*1000) / 1000
+		lots[i].DistanceKm = &distance
+	}
+	return ForecastParkingLots(lots, arrival)
+}
+
+// ForecastParkingLots 依歷史進出場紀錄（同星期、同時刻）預測抵達時的佔用，並以目前佔用與歷史的偏差修正
+func ForecastParkingLots(lots []models.ParkingLot, arrival time.Time) ([]LotForecast, error) {
+	now := time.Now()
+	if arrival.Before(now.Add(-5 * time.Minute)) {
+		return nil, fmt.Errorf("invalid arrival time: must not be in the past")
+	}
+	if arrival.Before(now) {
+		arrival = now
+	}
+	if arrival.After(now.AddDate(0, 0, 28)) {
+		return nil, fmt.Errorf("invalid arrival time: at most 28 days ahead")
+	}
+	if len(lots) == 0 {
+		return []LotForecast{}, nil
+	}
+
+	ids := make([]int, len(lots))
+	for i, lot := range lots {
+		ids[i] = lot.ParkingLotID
+	}
+
+	history, err := loadLotHistory(ids, arrival, now)
+	if err != nil {
+		return nil, err
+	}
+	current, err := GetActiveCounts(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// 抵達時間越遠，目前偏差的影響越小
+	horizon := arrival.Sub(now).Hours()
+	weight := math.Exp(-horizon / forecastDecayHours)
+
+	forecasts := make([]LotForecast, len(lots))
+	for i, lot := range lots {
+		h := history[lot.ParkingLotID]
+		cur := float64(current[lot.ParkingLotID])
+
+		predicted := cur
+		historicalConfidence := 0.0
+		spread := 0.0
+		if len(h.atArrival) > 0 {
+			mean, std := meanStd(h.atArrival)
+			predicted = mean
+			// 沒有目前時刻的歷史樣本時無法得知目前的偏差，只使用歷史平均
+			if len(h.atNow) > 0 {
+				nowMean, _ := meanStd(h.atNow)
+				predicted += weight * (cur - nowMean)
+			}
+			spread = std
+
+			// 歷史樣本越多、波動越小，信心越高
+			dataFactor := float64(len(h.atArrival)) / forecastHistoryWeeks
+			spreadFactor := 1.0
+			if lot.TotalSpots > 0 {
+				spreadFactor = 1 - math.Min(1, 2*std/float64(lot.TotalSpots))
+			}
+			historicalConfidence = 0.2 + 0.8*dataFactor*spreadFactor
+		}
+		predicted = math.Max(0, math.Min(predicted, float64(lot.TotalSpots)))
+		confidence := weight*0.95 + (1-weight)*historicalConfidence
+
+		free := lot.TotalSpots - int(math.Round(predicted))
+		free = max(0, min(free, lot.TotalSpots))
+
+		forecasts[i] = LotForecast{
+			ParkingLotID:         lot.ParkingLotID,
+			Address:              lot.Address,
+			TotalSpots:           lot.TotalSpots,
+			ArrivalTime:          arrival.Format(time.RFC3339),
+			PredictedFreeSpots:   free,
+			PredictedOccupied:    math.Round(predicted*100) / 100,
+			Confidence:           math.Round(confidence*100) / 100,
+			ProbabilityAvailable: probabilityAvailable(predicted, spread, lot.TotalSpots),
+			CurrentOccupied:      int(cur),
+			HistoricalSamples:    len(h.atArrival),
+			OpenAtArrival:        lot.IsOpenAt(arrival),
+			DistanceKm:           lot.DistanceKm,
+		}
+	}
+	return forecasts, nil
+}
+
+// historySamples 回傳抵達時刻與目前時刻在過去各週的取樣時間（各 forecastHistoryWeeks 個）
+// 抵達時間可能在數週後，從抵達時間往回整週退到不晚於目前時間，避免取到未來的時刻
+func historySamples(arrival, now time.Time) []time.Time {
+	first := arrival.AddDate(0, 0, -7)
+	for first.After(now) {
+		first = first.AddDate(0, 0, -7)
+	}
+
+	samples := make([]time.Time, 0, 2*forecastHistoryWeeks)
+	for k := 0; k < forecastHistoryWeeks; k++ {
+		samples = append(samples, first.AddDate(0, 0, -7*k))
+	}
+	for k := 1; k <= forecastHistoryWeeks; k++ {
+		samples = append(samples, now.AddDate(0, 0, -7*k))
+	}
+	return samples
+}
+
+// loadLotHistory 以單一查詢計算各停車場在過去各週同時刻（抵達時刻與目前時刻）的佔用數
+// 只採用停車場已有紀錄之後的樣本
+func loadLotHistory(lotIDs []int, arrival, now time.Time) (map[int]lotHistory, error) {
+	samples := historySamples(arrival, now)
+
+	earliest, latest := samples[0], samples[0]
+	columns := []string{"parking_lot_id"}
+	args := []interface{}{}
+	for i, t := range samples {
+		if t.Before(earliest) {
+			earliest = t
+		}
+		if t.After(latest) {
+			latest = t
+		}
+		columns = append(columns, fmt.Sprintf(
+			"SUM(CASE WHEN start_time <= ? AND (end_time IS NULL OR end_time > ?) THEN 1 ELSE 0 END) AS s%d", i))
+		args = append(args, t, t)
+	}
+
+	// 各停車場最早的紀錄時間，早於此時間的樣本不採用
+	var firstStarts []struct {
+		ParkingLotID int
+		FirstStart   time.Time
+	}
+	if err := database.DB.Model(&models.Rent{}).
+		Select("parking_lot_id, MIN(start_time) AS first_start").
+		Where("parking_lot_id IN ? AND voided = ?", lotIDs, false).
+		Group("parking_lot_id").
+		Scan(&firstStarts).Error; err != nil {
+		return nil, fmt.Errorf("failed to query rent history: %w", err)
+	}
+
+	rows, err := database.DB.Model(&models.Rent{}).
+		Select(strings.Join(columns, ", "), args...).
+		Where("parking_lot_id IN ? AND voided = ?", lotIDs, false).
+		Where("start_time <= ? AND (end_time IS NULL OR end_time > ?)", latest, earliest).
+		Group("parking_lot_id").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rent history: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[int][]float64{}
+	for rows.Next() {
+		var lotID int
+		values := make([]sql.NullFloat64, len(samples))
+		dest := []interface{}{&lotID}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan rent history: %w", err)
+		}
+		c := make([]float64, len(samples))
+		for i, v := range values {
+			c[i] = v.Float64
+		}
+		counts[lotID] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rent history: %w", err)
+	}
+
+	history := make(map[int]lotHistory, len(firstStarts))
+	for _, fs := range firstStarts {
+		c := counts[fs.ParkingLotID]
+		var h lotHistory
+		for i, t := range samples {
+			if t.Before(fs.FirstStart) {
+				continue
+			}
+			v := 0.0
+			if c != nil {
+				v = c[i]
+			}
+			if i < forecastHistoryWeeks {
+				h.atArrival = append(h.atArrival, v)
+			} else {
+				h.atNow = append(h.atNow, v)
+			}
+		}
+		history[fs.ParkingLotID] = h
+	}
+	return history, nil
+}
+
+// meanStd 平均數與標準差
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// probabilityAvailable 以常態近似估計佔用數小於總車位（至少一個空位）的機率
+func probabilityAvailable(predicted, std float64, totalSpots int) float64 {
+	if totalSpots <= 0 {
+		return 0
+	}
+	std = math.Max(std, 0.5)
+	z := (float64(totalSpots) - 0.5 - predicted) / std
+	p := 0.5 * (1 + math.Erf(z/math.Sqrt2))
+	return math.Round(p*1000) / 1000
+}
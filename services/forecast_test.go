@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHistorySamples 抵達時刻的樣本不可晚於目前時間，且與抵達時刻同星期、同時刻
+func TestHistorySamples(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, cstZone)
+	for _, arrival := range []time.Time{
+		now,
+		now.Add(90 * time.Minute),
+		now.AddDate(0, 0, 6).Add(3 * time.Hour),
+		now.AddDate(0, 0, 7),
+		now.AddDate(0, 0, 20),
+		now.AddDate(0, 0, 28),
+	} {
+		samples := historySamples(arrival, now)
+		if len(samples) != 2*forecastHistoryWeeks {
+			t.Fatalf("arrival %s: got %d samples, want %d", arrival, len(samples), 2*forecastHistoryWeeks)
+		}
+		for i, s := range samples[:forecastHistoryWeeks] {
+			if s.After(now) {
+				t.Errorf("arrival %s: sample %d at %s is in the future", arrival, i, s)
+			}
+			if s.Weekday() != arrival.Weekday() || s.Hour() != arrival.Hour() || s.Minute() != arrival.Minute() {
+				t.Errorf("arrival %s: sample %d at %s is not the same weekday and time", arrival, i, s)
+			}
+			if i > 0 && samples[i-1].Sub(s).Round(time.Hour) != 7*24*time.Hour {
+				t.Errorf("arrival %s: samples %d and %d are not one week apart", arrival, i-1, i)
+			}
+		}
+		// 最近的樣本應在目前時間前一週內
+		if now.Sub(samples[0]) > 7*24*time.Hour {
+			t.Errorf("arrival %s: latest sample %s is more than a week before now", arrival, samples[0])
+		}
+		for i, s := range samples[forecastHistoryWeeks:] {
+			if want := now.AddDate(0, 0, -7*(i+1)); !s.Equal(want) {
+				t.Errorf("arrival %s: current-time sample %d = %s, want %s", arrival, i, s, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"project01/database"
+	"project01/models"
+	"sort"
+	"time"
+)
+
+// 收入報表的分組方式
+const (
+	GroupByDay   = "day"
+	GroupByWeek  = "week"
+	GroupByMonth = "month"
+)
+
+// incomePeriodSQL 各分組方式的期間運算式（週以星期一為起始）
+var incomePeriodSQL = map[string]string{
+	GroupByDay:   "DATE_FORMAT(day, '%Y-%m-%d')",
+	GroupByWeek:  "DATE_FORMAT(DATE_SUB(day, INTERVAL WEEKDAY(day) DAY), '%Y-%m-%d')",
+	GroupByMonth: "DATE_FORMAT(day, '%Y-%m')",
+}
+
+// IncomeReportFilter 收入報表條件
+type IncomeReportFilter struct {
+	ParkingLotIDs []int // 空值代表所有停車場
+	StartDate     time.Time
+	EndDate       time.Time // 含當天
+	GroupBy       string
+}
+
+// IncomeTotals 收入統計
+type IncomeTotals struct {
+	Revenue          float64 `json:"revenue"`
+	Sessions         int64   `json:"sessions"`
+	TotalHours       float64 `json:"total_hours"`
+	AvgDurationHours float64 `json:"avg_duration_hours"`
+	RevenuePerSpot   float64 `json:"revenue_per_spot"`
+}
+
+// IncomeLotRow 某期間內單一停車場的收入
+type IncomeLotRow struct {
+	ParkingLotID int    `json:"parking_lot_id"`
+	Address      string `json:"address"`
+	TotalSpots   int    `json:"total_spots"`
+	IncomeTotals
+}
+
+// IncomePeriod 單一期間的收入（含各停車場明細）
+type IncomePeriod struct {
+	Period string         `json:"period"`
+	Totals IncomeTotals   `json:"totals"`
+	Lots   []IncomeLotRow `json:"lots"`
+}
+
+// IncomeReport 多停車場收入報表
+type IncomeReport struct {
+	StartDate     string         `json:"start_date"`
+	EndDate       string         `json:"end_date"`
+	GroupBy       string         `json:"group_by"`
+	ParkingLotIDs []int          `json:"parking_lot_ids,omitempty"`
+	TotalSpots    int            `json:"total_spots"`
+	Totals        IncomeTotals   `json:"totals"`
+	ByLot         []IncomeLotRow `json:"by_lot"`
+	Periods       []IncomePeriod `json:"periods"`
+}
+
+// incomeRow SQL 分組結果
+type incomeRow struct {
+	Period       string
+	ParkingLotID int
+	Revenue      float64
+	Sessions     int64
+	TotalSeconds float64
+}
+
+// GetIncomeReport 以每日彙總依期間與停車場彙總收入（不掃描 rent）
+func GetIncomeReport(filter IncomeReportFilter) (*IncomeReport, error) {
+	if _, ok := incomePeriodSQL[filter.GroupBy]; !ok {
+		return nil, fmt.Errorf("invalid group_by: %s", filter.GroupBy)
+	}
+	if filter.EndDate.Before(filter.StartDate) {
+		return nil, fmt.Errorf("invalid date range: end_date is before start_date")
+	}
+
+	rows, err := queryIncomeRows(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	lots, err := loadReportLots(filter.ParkingLotIDs, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IncomeReport{
+		StartDate:     filter.StartDate.Format("2006-01-02"),
+		EndDate:       filter.EndDate.Format("2006-01-02"),
+		GroupBy:       filter.GroupBy,
+		ParkingLotIDs: filter.ParkingLotIDs,
+		ByLot:         []IncomeLotRow{},
+		Periods:       []IncomePeriod{},
+	}
+	for _, lot := range lots {
+		report.TotalSpots += lot.TotalSpots
+	}
+
+	periodIndex := map[string]int{}
+	byLot := map[int]*IncomeLotRow{}
+	var grand IncomeTotals
+	var grandSeconds float64
+	periodSeconds := map[string]float64{}
+
+	for _, r := range rows {
+		lot := lots[r.ParkingLotID]
+		row := IncomeLotRow{ParkingLotID: r.ParkingLotID, Address: lot.Address, TotalSpots: lot.TotalSpots}
+		row.IncomeTotals = buildIncomeTotals(r.Revenue, r.Sessions, r.TotalSeconds, lot.TotalSpots)
+
+		idx, ok := periodIndex[r.Period]
+		if !ok {
+			idx = len(report.Periods)
+			periodIndex[r.Period] = idx
+			report.Periods = append(report.Periods, IncomePeriod{Period: r.Period})
+		}
+		p := &report.Periods[idx]
+		p.Lots = append(p.Lots, row)
+		p.Totals.Revenue += r.Revenue
+		p.Totals.Sessions += r.Sessions
+		periodSeconds[r.Period] += r.TotalSeconds
+
+		if byLot[r.ParkingLotID] == nil {
+			byLot[r.ParkingLotID] = &IncomeLotRow{ParkingLotID: r.ParkingLotID, Address: lot.Address, TotalSpots: lot.TotalSpots}
+		}
+		bl := byLot[r.ParkingLotID]
+		bl.Revenue += r.Revenue
+		bl.Sessions += r.Sessions
+		bl.TotalHours += r.TotalSeconds / 3600
+
+		grand.Revenue += r.Revenue
+		grand.Sessions += r.Sessions
+		grandSeconds += r.TotalSeconds
+	}
+
+	for i := range report.Periods {
+		p := &report.Periods[i]
+		p.Totals = buildIncomeTotals(p.Totals.Revenue, p.Totals.Sessions, periodSeconds[p.Period], report.TotalSpots)
+	}
+	for _, bl := range byLot {
+		bl.IncomeTotals = buildIncomeTotals(bl.Revenue, bl.Sessions, bl.TotalHours*3600, bl.TotalSpots)
+		report.ByLot = append(report.ByLot, *bl)
+	}
+	sort.Slice(report.ByLot, func(i, j int) bool { return report.ByLot[i].ParkingLotID < report.ByLot[j].ParkingLotID })
+	report.Totals = buildIncomeTotals(grand.Revenue, grand.Sessions, grandSeconds, report.TotalSpots)
+
+	log.Printf("INCOME_AGGREGATE_REPORT | lots=%d periods=%d group_by=%s sessions=%d revenue=%.2f from=%s to=%s",
+		len(report.ByLot), len(report.Periods), filter.GroupBy, grand.Sessions, grand.Revenue,
+		report.StartDate, report.EndDate)
+	return report, nil
+}
+
+// queryIncomeRows 由每日彙總（lot_daily_stat）依期間與停車場分組彙總收入
+func queryIncomeRows(filter IncomeReportFilter) ([]incomeRow, error) {
+	period := incomePeriodSQL[filter.GroupBy]
+
+	var rows []incomeRow
+	query := database.DB.Model(&models.LotDailyStat{}).
+		Select(period+" AS period, parking_lot_id, "+
+			"COALESCE(SUM(revenue), 0) AS revenue, COALESCE(SUM(sessions), 0) AS sessions, "+
+			"COALESCE(SUM(stay_minutes), 0) * 60 AS total_seconds").
+		Where("day BETWEEN ? AND ? AND sessions > 0",
+			filter.StartDate.Format("2006-01-02"), filter.EndDate.Format("2006-01-02"))
+	if len(filter.ParkingLotIDs) > 0 {
+		query = query.Where("parking_lot_id IN ?", filter.ParkingLotIDs)
+	}
+	if err := query.Group("period, parking_lot_id").
+		Order("period, parking_lot_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate income: %w", err)
+	}
+	return rows, nil
+}
+
+// loadReportLots 取得報表涵蓋的停車場：指定的停車場，或所有啟用中的停車場加上有收入的已停用停車場
+func loadReportLots(lotIDs []int, rows []incomeRow) (map[int]models.ParkingLot, error) {
+	var lots []models.ParkingLot
+	query := database.DB.Model(&models.ParkingLot{})
+	if len(lotIDs) > 0 {
+		query = query.Where("parking_lot_id IN ?", lotIDs)
+	} else {
+		seen := map[int]bool{}
+		ids := []int{}
+		for _, r := range rows {
+			if !seen[r.ParkingLotID] {
+				seen[r.ParkingLotID] = true
+				ids = append(ids, r.ParkingLotID)
+			}
+		}
+		if len(ids) > 0 {
+			query = query.Where("archived_at IS NULL OR parking_lot_id IN ?", ids)
+		} else {
+			query = query.Where("archived_at IS NULL")
+		}
+	}
+	if err := query.Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("failed to query parking lots: %w", err)
+	}
+
+	result := make(map[int]models.ParkingLot, len(lots))
+	for _, lot := range lots {
+		result[lot.ParkingLotID] = lot
+	}
+	return result, nil
+}
+
+// buildIncomeTotals 由彙總值計算平均停車時數與每車位收入
+func buildIncomeTotals(revenue float64, sessions int64, totalSeconds float64, totalSpots int) IncomeTotals {
+	t := IncomeTotals{
+		Revenue:    math.Round(revenue*100) / 100,
+		Sessions:   sessions,
+		TotalHours: math.Round(totalSeconds/3600*100) / 100,
+	}
+	if sessions > 0 {
+		t.AvgDurationHours = math.Round(totalSeconds/3600/float64(sessions)*100) / 100
+	}
+	if totalSpots > 0 {
+		t.RevenuePerSpot = math.Round(revenue/float64(totalSpots)*100) / 100
+	}
+	return t
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 郵件寄送方式（MAIL_DRIVER）
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+	MailDriverSMTP = "smtp"
+)
+
+// DefaultMailFileDir file 寄送方式的輸出目錄（MAIL_FILE_DIR 未設定時）
+const DefaultMailFileDir = "mail"
+
+// MailAttachment 郵件附件
+type MailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// MailMessage 郵件內容（純文字）
+type MailMessage struct {
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []MailAttachment
+}
+
+// Mailer 寄送郵件的介面
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// LogMailer 以 log 記錄郵件（本機開發用）
+// 內文可能含重設密碼連結、驗證碼等機密，只記錄長度；需要檢視內文時請改用 file driver
+type LogMailer struct{}
+
+func (LogMailer) Send(msg MailMessage) error {
+	log.Printf("MAIL | to=%s subject=%s attachments=%d body_bytes=%d (body redacted)",
+		strings.Join(msg.To, ","), msg.Subject, len(msg.Attachments), len(msg.Body))
+	return nil
+}
+
+// FileMailer 將郵件以 .eml 檔寫入目錄（本機測試用）
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(msg MailMessage) error {
+	data, err := buildMailMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	suffix, err := randomHex(4)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.Dir, time.Now().Format("20060102-150405.000000")+"-"+suffix+".eml")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+	log.Printf("MAIL_FILE | to=%s subject=%s path=%s", strings.Join(msg.To, ","), msg.Subject, path)
+	return nil
+}
+
+// SMTPMailer 透過 SMTP 寄送郵件；伺服器支援時會自動使用 STARTTLS
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(msg MailMessage) error {
+	data, err := buildMailMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, m.From, msg.To, data); err != nil {
+		return fmt.Errorf("failed to send mail via %s: %w", addr, err)
+	}
+	log.Printf("MAIL_SENT | to=%s subject=%s", strings.Join(msg.To, ","), msg.Subject)
+	return nil
+}
+
+// mailer 目前使用的郵件寄送器
+var mailer Mailer = LogMailer{}
+
+// SetMailer 設定郵件寄送器
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// InitMailer 依 MAIL_DRIVER 設定郵件寄送器（log／file／smtp，預設 log）
+// 使用 file 或 smtp 時，定期報表的郵件也改由同一個寄送器寄出
+func InitMailer() error {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "", MailDriverLog:
+		// log driver 不會真的寄出郵件，正式環境使用時會員收不到重設密碼與驗證信
+		if os.Getenv("GIN_MODE") == "release" {
+			return fmt.Errorf("MAIL_DRIVER=log is not allowed when GIN_MODE=release, set MAIL_DRIVER=smtp")
+		}
+		SetMailer(LogMailer{})
+		return nil
+	case MailDriverFile:
+		dir := os.Getenv("MAIL_FILE_DIR")
+		if dir == "" {
+			dir = DefaultMailFileDir
+		}
+		SetMailer(FileMailer{Dir: dir, From: from})
+	case MailDriverSMTP:
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER=smtp")
+		}
+		port := 587
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil || p <= 0 {
+				return fmt.Errorf("invalid SMTP_PORT: %s", v)
+			}
+			port = p
+		}
+		SetMailer(SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	default:
+		return fmt.Errorf("invalid MAIL_DRIVER: %s", driver)
+	}
+
+	SetReportMailer(mailerReportMailer{})
+	return nil
+}
+
+// mailerReportMailer 以共用的郵件寄送器寄送報表
+type mailerReportMailer struct{}
+
+func (mailerReportMailer) SendReport(email ReportEmail) error {
+	attachments := make([]MailAttachment, len(email.Attachments))
+	for i, a := range email.Attachments {
+		attachments[i] = MailAttachment{Filename: a.Filename, ContentType: a.ContentType, Data: a.Data}
+	}
+	return mailer.Send(MailMessage{
+		To:          email.To,
+		Subject:     email.Subject,
+		Body:        email.Body,
+		Attachments: attachments,
+	})
+}
+
+// buildMailMessage 組成 MIME 郵件；內文與附件皆以 base64 編碼
+func buildMailMessage(from string, msg MailMessage) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, fmt.Errorf("mail has no recipients")
+	}
+	for _, addr := range append([]string{from}, msg.To...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid mail address: %q", addr)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(msg.Attachments) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64Lines(&buf, []byte(msg.Body))
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mail: %w", err)
+	}
+	writeBase64Lines(part, []byte(msg.Body))
+
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build mail: %w", err)
+		}
+		writeBase64Lines(part, a.Data)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build mail: %w", err)
+	}
+
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// writeBase64Lines 以每行 76 字元寫入 base64 內容
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
@@ -87,6 +87,9 @@ func RegisterMember(member *models.Member) error {
 	}
 
 	log.Printf("Successfully registered member with ID %d, name=%s", member.MemberID, member.Name)
+
+	// 新會員尚未驗證，寄出電子郵件與電話驗證碼
+	SendVerificationCodesAsync(member.MemberID, VerificationChannelEmail, VerificationChannelPhone)
 	return nil
 }
 
@@ -234,6 +237,10 @@ func UpdateMember(id int, updatedFields map[string]interface{}) error {
 
 	// 映射 JSON 字段名到資料庫列名
 	mappedFields := make(map[string]interface{})
+	// 角色或密碼變更時，已簽發的 token 全部失效
+	revokeTokens := false
+	// 電子郵件或電話變更後需重新驗證
+	var reverify []string
 	for key, value := range updatedFields {
 		switch key {
 		case "member_id":
@@ -253,6 +260,10 @@ func UpdateMember(id int, updatedFields map[string]interface{}) error {
 				return fmt.Errorf("failed to check for duplicate phone: %w", err)
 			}
 			mappedFields["phone"] = phoneStr
+			if phoneStr != member.Phone {
+				mappedFields["phone_verified_at"] = nil
+				reverify = append(reverify, VerificationChannelPhone)
+			}
 		case "password":
 			passwordStr, ok := value.(string)
 			if !ok {
@@ -269,6 +280,7 @@ func UpdateMember(id int, updatedFields map[string]interface{}) error {
 				return fmt.Errorf("failed to hash password: %w", err)
 			}
 			mappedFields["password"] = hashedPassword
+			revokeTokens = true
 		case "role":
 			roleStr, ok := value.(string)
 			if !ok {
@@ -278,6 +290,9 @@ func UpdateMember(id int, updatedFields map[string]interface{}) error {
 				return fmt.Errorf("invalid role: must be 'renter' or 'admin'")
 			}
 			mappedFields["role"] = roleStr
+			if roleStr != member.Role {
+				revokeTokens = true
+			}
 		case "payment_info":
 			paymentInfoStr, ok := value.(string)
 			if !ok {
@@ -302,6 +317,10 @@ func UpdateMember(id int, updatedFields map[string]interface{}) error {
 				return fmt.Errorf("failed to check for duplicate email: %w", err)
 			}
 			mappedFields["email"] = emailStr
+			if emailStr != member.Email {
+				mappedFields["email_verified_at"] = nil
+				reverify = append(reverify, VerificationChannelEmail)
+			}
 		case "name":
 			nameStr, ok := value.(string)
 			if !ok {
@@ -322,6 +341,16 @@ func UpdateMember(id int, updatedFields map[string]interface{}) error {
 		return fmt.Errorf("failed to update member with ID %d: %w", id, err)
 	}
 
+	if revokeTokens {
+		if err := RevokeAllMemberTokens(id); err != nil {
+			log.Printf("Failed to revoke tokens for member %d: %v", id, err)
+			return err
+		}
+	}
+	if len(reverify) > 0 {
+		SendVerificationCodesAsync(id, reverify...)
+	}
+
 	log.Printf("Successfully updated member with ID %d, fields: %v", id, mappedFields)
 	return nil
 }
@@ -356,6 +385,37 @@ func DeleteMember(id int) error {
 		return fmt.Errorf("failed to delete rents for member %d: %w", id, err)
 	}
 
+	// 刪除相關的 refresh token、登入工作階段、密碼重設 token 與驗證碼
+	if err := tx.Where("member_id = ?", id).Delete(&models.RefreshToken{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to delete refresh tokens for member %d: %v", id, err)
+		return fmt.Errorf("failed to delete refresh tokens for member %d: %w", id, err)
+	}
+	if err := tx.Where("member_id = ?", id).Delete(&models.LoginSession{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to delete login sessions for member %d: %v", id, err)
+		return fmt.Errorf("failed to delete login sessions for member %d: %w", id, err)
+	}
+	if err := tx.Where("member_id = ?", id).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to delete password reset tokens for member %d: %v", id, err)
+		return fmt.Errorf("failed to delete password reset tokens for member %d: %w", id, err)
+	}
+	if err := tx.Where("member_id = ?", id).Delete(&models.VerificationCode{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to delete verification codes for member %d: %v", id, err)
+		return fmt.Errorf("failed to delete verification codes for member %d: %w", id, err)
+	}
+
+	// 刪除兩步驟驗證設定、備用碼與登入挑戰
+	for _, model := range []interface{}{&models.MemberTOTP{}, &models.TOTPRecoveryCode{}, &models.MFAChallenge{}} {
+		if err := tx.Where("member_id = ?", id).Delete(model).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Failed to delete 2fa data for member %d: %v", id, err)
+			return fmt.Errorf("failed to delete 2fa data for member %d: %w", id, err)
+		}
+	}
+
 	// 提交事務
 	if err := tx.Delete(&member).Error; err != nil {
 		tx.Rollback()
@@ -373,18 +433,25 @@ func DeleteMember(id int) error {
 }
 
 // GetMemberRentHistory 查詢特定會員的租賃歷史記錄
-func GetMemberRentHistory(memberID int) ([]models.Rent, error) {
-	var rents []models.Rent
-	if err := database.DB.
-		Preload("ParkingSpot").
-		Where("member_id = ?", memberID).
-		Find(&rents).Error; err != nil {
+// 依 rent.member_id（進場當下的車主）篩選，車輛過戶後過戶前的紀錄仍歸原車主
+func GetMemberRentHistory(memberID int, filter RentSearchFilter) ([]models.Rent, string, error) {
+	var count int64
+	if err := database.DB.Model(&models.Member{}).Where("member_id = ?", memberID).Count(&count).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	if count == 0 {
+		return nil, "", fmt.Errorf("member %d not found", memberID)
+	}
+
+	filter.MemberID = memberID
+	rents, nextCursor, err := SearchRentRecords(filter)
+	if err != nil {
 		log.Printf("Failed to get rent history for member %d: %v", memberID, err)
-		return nil, fmt.Errorf("failed to get rent history for member %d: %w", memberID, err)
+		return nil, "", err
 	}
 
 	log.Printf("Successfully retrieved %d rent records for member %d", len(rents), memberID)
-	return rents, nil
+	return rents, nextCursor, nil
 }
 
 // GetVehiclesByMemberID 取得某會員的所有車輛
@@ -400,6 +467,17 @@ func GetVehiclesByMemberID(memberID int) ([]models.Vehicle, error) {
 
 // CreateVehicle 新增車輛（自動處理第一台車設為預設）
 func CreateVehicle(vehicle *models.Vehicle) error {
+	// 未完成電子郵件與電話驗證的會員不能新增車輛
+	if err := RequireVerifiedMember(vehicle.MemberID); err != nil {
+		return err
+	}
+
+	plate, err := utils.ValidateLicensePlate(vehicle.LicensePlate)
+	if err != nil {
+		return err
+	}
+	vehicle.LicensePlate = plate
+
 	// 檢查車牌是否重複
 	var existing models.Vehicle
 	if err := database.DB.Where("license_plate = ?", vehicle.LicensePlate).First(&existing).Error; err == nil {
@@ -424,6 +502,8 @@ func CreateVehicle(vehicle *models.Vehicle) error {
 
 // UpdateVehicle 更新車輛資訊
 func UpdateVehicle(licensePlate string, memberID int, updates map[string]interface{}) error {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
 	var vehicle models.Vehicle
 	if err := database.DB.Where("license_plate = ? AND member_id = ?", licensePlate, memberID).First(&vehicle).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -440,6 +520,8 @@ func UpdateVehicle(licensePlate string, memberID int, updates map[string]interfa
 
 // DeleteVehicle 刪除車輛
 func DeleteVehicle(licensePlate string, memberID int) error {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
 	var vehicle models.Vehicle
 	if err := database.DB.Where("license_plate = ? AND member_id = ?", licensePlate, memberID).First(&vehicle).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -456,6 +538,8 @@ func DeleteVehicle(licensePlate string, memberID int) error {
 
 // SetDefaultVehicle 設為預設車輛（其他車取消預設）
 func SetDefaultVehicle(licensePlate string, memberID int) error {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
 	// 先取消所有預設
 	if err := database.DB.Model(&models.Vehicle{}).
 		Where("member_id = ?", memberID).
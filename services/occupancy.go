@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"project01/database"
+	"project01/models"
+)
+
+// activeRentCondition 進行中停車紀錄的判定條件（未結束且未作廢），佔用相關的統計與偵測都應使用
+const activeRentCondition = "rent.end_time IS NULL AND rent.voided = false"
+
+// GetActiveCounts 以單一分組查詢取得各停車場進行中（end_time IS NULL 且未作廢）的停車數
+// lotIDs 為空時查詢所有停車場
+func GetActiveCounts(lotIDs []int) (map[int]int, error) {
+	var rows []struct {
+		ParkingLotID int
+		ActiveCount  int
+	}
+
+	query := database.DB.Model(&models.Rent{}).
+		Select("parking_lot_id, COUNT(*) AS active_count").
+		Where(activeRentCondition)
+	if len(lotIDs) > 0 {
+		query = query.Where("parking_lot_id IN ?", lotIDs)
+	}
+	if err := query.Group("parking_lot_id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active parking: %w", err)
+	}
+
+	counts := make(map[int]int, len(rows))
+	for _, r := range rows {
+		counts[r.ParkingLotID] = r.ActiveCount
+	}
+	return counts, nil
+}
+
+// ApplyRemainingSpots 為多個停車場填入剩餘車位（不足時為 0）
+func ApplyRemainingSpots(lots []models.ParkingLot) error {
+	if len(lots) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(lots))
+	for i, lot := range lots {
+		ids[i] = lot.ParkingLotID
+	}
+	counts, err := GetActiveCounts(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range lots {
+		lots[i].RemainingSpots = max(lots[i].TotalSpots-counts[lots[i].ParkingLotID], 0)
+	}
+	return nil
+}
+
+// ApplyRemainingSpot 為單一停車場填入剩餘車位
+func ApplyRemainingSpot(lot *models.ParkingLot) error {
+	lots := []models.ParkingLot{*lot}
+	if err := ApplyRemainingSpots(lots); err != nil {
+		return err
+	}
+	lot.RemainingSpots = lots[0].RemainingSpots
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"project01/database"
+	"project01/models"
+	"time"
+)
+
+// 佔用時間序列的時間粒度
+const (
+	Granularity5Min   = "5m"
+	GranularityHourly = "hourly"
+	GranularityDaily  = "daily"
+)
+
+// occupancyBucketSQL 各粒度的分組運算式（DATETIME 以 CST 牆上時間儲存，直接格式化即可）
+var occupancyBucketSQL = map[string]string{
+	Granularity5Min:   "CONCAT(DATE_FORMAT(captured_at, '%Y-%m-%d %H:'), LPAD(FLOOR(MINUTE(captured_at) / 5) * 5, 2, '0'))",
+	GranularityHourly: "DATE_FORMAT(captured_at, '%Y-%m-%d %H:00')",
+	GranularityDaily:  "DATE_FORMAT(captured_at, '%Y-%m-%d 00:00')",
+}
+
+// occupancyMaxRange 各粒度允許查詢的最大區間
+var occupancyMaxRange = map[string]time.Duration{
+	Granularity5Min:   7 * 24 * time.Hour,
+	GranularityHourly: 92 * 24 * time.Hour,
+	GranularityDaily:  731 * 24 * time.Hour,
+}
+
+// OccupancyPoint 時間序列中的一個區間
+type OccupancyPoint struct {
+	BucketStart   string  `json:"bucket_start"`
+	AvgOccupied   float64 `json:"avg_occupied"`
+	MaxOccupied   int     `json:"max_occupied"`
+	TotalSpots    int     `json:"total_spots"`
+	OccupancyRate float64 `json:"occupancy_rate"` // 平均佔用率 0~1
+	Samples       int     `json:"samples"`
+}
+
+// OccupancyProfileEntry 星期幾 × 小時的平均佔用
+type OccupancyProfileEntry struct {
+	Weekday          int     `json:"weekday"` // 0 = 星期日
+	Hour             int     `json:"hour"`
+	AvgOccupied      float64 `json:"avg_occupied"`
+	AvgOccupancyRate float64 `json:"avg_occupancy_rate"`
+	Samples          int     `json:"samples"`
+}
+
+// RecordOccupancySnapshots 記錄所有啟用中停車場目前的佔用數（供 cron 呼叫）
+func RecordOccupancySnapshots() {
+	var lots []models.ParkingLot
+	if err := database.DB.Where("archived_at IS NULL").Find(&lots).Error; err != nil {
+		log.Printf("Failed to load parking lots for occupancy snapshot: %v", err)
+		return
+	}
+	if len(lots) == 0 {
+		return
+	}
+
+	// 使用實際進行中的停車數，超過車位數時也如實記錄（剩餘車位會被限制為 0）
+	counts, err := GetActiveCounts(nil)
+	if err != nil {
+		log.Printf("Failed to count active parking for occupancy snapshot: %v", err)
+		return
+	}
+
+	capturedAt := time.Now().Truncate(time.Minute)
+	snapshots := make([]models.OccupancySnapshot, len(lots))
+	for i, lot := range lots {
+		snapshots[i] = models.OccupancySnapshot{
+			ParkingLotID: lot.ParkingLotID,
+			CapturedAt:   capturedAt,
+			ActiveCount:  counts[lot.ParkingLotID],
+			TotalSpots:   lot.TotalSpots,
+		}
+	}
+
+	if err := database.DB.CreateInBatches(snapshots, 500).Error; err != nil {
+		log.Printf("Failed to record occupancy snapshots: %v", err)
+		return
+	}
+	log.Printf("OCCUPANCY_SNAPSHOT | lots=%d at=%s", len(snapshots), capturedAt.Format(time.RFC3339))
+}
+
+// GetOccupancySeries 查詢停車場在區間內的佔用時間序列
+func GetOccupancySeries(parkingLotID int, from, to time.Time, granularity string) ([]OccupancyPoint, error) {
+	bucket, ok := occupancyBucketSQL[granularity]
+	if !ok {
+		return nil, fmt.Errorf("invalid granularity: %s", granularity)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid time range: to must be after from")
+	}
+	if to.Sub(from) > occupancyMaxRange[granularity] {
+		return nil, fmt.Errorf("invalid time range: at most %d days for %s granularity",
+			int(occupancyMaxRange[granularity].Hours()/24), granularity)
+	}
+
+	var rows []struct {
+		Bucket      string
+		AvgOccupied float64
+		MaxOccupied int
+		TotalSpots  int
+		Samples     int
+	}
+	err := database.DB.Model(&models.OccupancySnapshot{}).
+		Select(bucket+" AS bucket, AVG(active_count) AS avg_occupied, MAX(active_count) AS max_occupied, "+
+			"MAX(total_spots) AS total_spots, COUNT(*) AS samples").
+		Where("parking_lot_id = ? AND captured_at >= ? AND captured_at < ?", parkingLotID, from, to).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query occupancy series: %w", err)
+	}
+
+	points := make([]OccupancyPoint, len(rows))
+	for i, r := range rows {
+		points[i] = OccupancyPoint{
+			BucketStart:   r.Bucket,
+			AvgOccupied:   math.Round(r.AvgOccupied*100) / 100,
+			MaxOccupied:   r.MaxOccupied,
+			TotalSpots:    r.TotalSpots,
+			OccupancyRate: occupancyRate(r.AvgOccupied, r.TotalSpots),
+			Samples:       r.Samples,
+		}
+	}
+	return points, nil
+}
+
+// GetOccupancyProfile 以最近 weeks 週的快照計算星期幾 × 小時的平均佔用
+func GetOccupancyProfile(parkingLotID int, weeks int) ([]OccupancyProfileEntry, error) {
+	if weeks <= 0 {
+		weeks = 8
+	}
+	since := time.Now().AddDate(0, 0, -7*weeks)
+
+	var rows []struct {
+		Weekday     int
+		Hour        int
+		AvgOccupied float64
+		AvgRate     float64
+		Samples     int
+	}
+	err := database.DB.Model(&models.OccupancySnapshot{}).
+		Select("DAYOFWEEK(captured_at) - 1 AS weekday, HOUR(captured_at) AS hour, "+
+			"AVG(active_count) AS avg_occupied, AVG(CASE WHEN total_spots > 0 THEN active_count / total_spots ELSE 0 END) AS avg_rate, "+
+			"COUNT(*) AS samples").
+		Where("parking_lot_id = ? AND captured_at >= ?", parkingLotID, since).
+		Group("weekday, hour").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query occupancy profile: %w", err)
+	}
+
+	// 固定回傳 7 × 24 格，沒有資料的時段 samples 為 0
+	profile := make([]OccupancyProfileEntry, 7*24)
+	for i := range profile {
+		profile[i] = OccupancyProfileEntry{Weekday: i / 24, Hour: i % 24}
+	}
+	for _, r := range rows {
+		if r.Weekday < 0 || r.Weekday > 6 || r.Hour < 0 || r.Hour > 23 {
+			continue
+		}
+		profile[r.Weekday*24+r.Hour] = OccupancyProfileEntry{
+			Weekday:          r.Weekday,
+			Hour:             r.Hour,
+			AvgOccupied:      math.Round(r.AvgOccupied*100) / 100,
+			AvgOccupancyRate: math.Round(r.AvgRate*1000) / 1000,
+			Samples:          r.Samples,
+		}
+	}
+	return profile, nil
+}
+
+// occupancyRate 佔用率，四捨五入到小數第三位
+func occupancyRate(occupied float64, totalSpots int) float64 {
+	if totalSpots <= 0 {
+		return 0
+	}
+	return math.Round(occupied/float64(totalSpots)*1000) / 1000
+}
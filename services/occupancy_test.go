@@ -0,0 +1,79 @@
+package services
+
+import (
+	"project01/database"
+	"project01/models"
+	"testing"
+	"time"
+)
+
+// TestActiveCountsAndRemainingSpots 剩餘車位應等於車位數減去實際進行中的停車數
+// 已結束與已作廢的紀錄不計入，超過車位數時剩餘車位為 0
+func TestActiveCountsAndRemainingSpots(t *testing.T) {
+	setupTestDB(t, &models.ParkingLot{}, &models.Rent{})
+
+	lots := []models.ParkingLot{
+		{Type: "flat", Address: "mixed", HourlyRate: 30, TotalSpots: 3},
+		{Type: "flat", Address: "over capacity", HourlyRate: 30, TotalSpots: 1},
+		{Type: "flat", Address: "empty", HourlyRate: 30, TotalSpots: 5},
+	}
+	if err := database.DB.Create(&lots).Error; err != nil {
+		t.Fatalf("failed to seed parking lots: %v", err)
+	}
+	mixed, over, empty := lots[0].ParkingLotID, lots[1].ParkingLotID, lots[2].ParkingLotID
+
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+	cost := 30.0
+	rents := []models.Rent{
+		{LicensePlate: "AAA-0001", ParkingLotID: mixed, StartTime: start},
+		{LicensePlate: "AAA-0002", ParkingLotID: mixed, StartTime: start},
+		{LicensePlate: "AAA-0003", ParkingLotID: mixed, StartTime: start, EndTime: &end, TotalCost: &cost},
+		{LicensePlate: "AAA-0004", ParkingLotID: mixed, StartTime: start, EndTime: &end, Voided: true},
+		{LicensePlate: "AAA-0005", ParkingLotID: mixed, StartTime: start, Voided: true},
+		{LicensePlate: "BBB-0001", ParkingLotID: over, StartTime: start},
+		{LicensePlate: "BBB-0002", ParkingLotID: over, StartTime: start},
+		{LicensePlate: "BBB-0003", ParkingLotID: over, StartTime: start},
+	}
+	if err := database.DB.Omit("ParkingLot").Create(&rents).Error; err != nil {
+		t.Fatalf("failed to seed rents: %v", err)
+	}
+
+	counts, err := GetActiveCounts([]int{mixed, over, empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCounts := map[int]int{mixed: 2, over: 3, empty: 0}
+	for id, want := range wantCounts {
+		if counts[id] != want {
+			t.Errorf("GetActiveCounts()[%d] = %d, want %d", id, counts[id], want)
+		}
+	}
+
+	all, err := GetActiveCounts(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[mixed] != 2 || all[over] != 3 {
+		t.Errorf("GetActiveCounts(nil) = %v, want map[%d:2 %d:3]", all, mixed, over)
+	}
+
+	if err := ApplyRemainingSpots(lots); err != nil {
+		t.Fatal(err)
+	}
+	wantRemaining := map[int]int{mixed: 1, over: 0, empty: 5}
+	for _, lot := range lots {
+		if lot.RemainingSpots != wantRemaining[lot.ParkingLotID] {
+			t.Errorf("lot %q remaining = %d, want %d", lot.Address, lot.RemainingSpots, wantRemaining[lot.ParkingLotID])
+		}
+	}
+
+	single := lots[0]
+	single.RemainingSpots = -1
+	if err := ApplyRemainingSpot(&single); err != nil {
+		t.Fatal(err)
+	}
+	if single.RemainingSpots != 1 {
+		t.Errorf("ApplyRemainingSpot remaining = %d, want 1", single.RemainingSpots)
+	}
+}
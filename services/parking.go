@@ -5,62 +5,84 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
 	"project01/database"
 	"project01/models"
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// GetAvailableParkingLots 查詢附近有剩餘車位的停車場（用 pending rent 計算！）
-func GetAvailableParkingLots(latitude, longitude, radius float64) ([]models.ParkingLot, error) {
-	var lots []models.ParkingLot
-
-	if radius <= 0 {
-		radius = 3.0
+// GetAvailableParkingLots 查詢附近符合條件的停車場（依 end_time IS NULL 計算剩餘車位），回傳一頁結果與下一頁游標
+func GetAvailableParkingLots(q AvailableLotQuery) ([]models.ParkingLot, string, error) {
+	if q.Radius <= 0 {
+		q.Radius = 3.0
 	}
-	if radius > 50 {
-		radius = 50.0
+	if q.Radius > 50 {
+		q.Radius = 50.0
+	}
+	if q.MinFreeSpots <= 0 {
+		q.MinFreeSpots = 1
+	}
+	if q.SortBy == "" {
+		q.SortBy = SortByDistance
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 100 {
+		q.Limit = 100
 	}
 
-	// 哈弗辛公式（括號與 radians(?) 完全對齊！）
-	distanceSQL := `
-        (6371 * acos(
-            cos(radians(?)) * cos(radians(latitude)) * 
-            cos(radians(longitude) - radians(?)) + 
-            sin(radians(?)) * sin(radians(latitude))
-        )) 
-    `
-
-	// 注意：這裡一定要傳 3 次 latitude, 一次 longitude
-	query := database.DB.Where(distanceSQL+" <= ?", latitude, longitude, latitude, radius)
-
-	if err := query.Find(&lots).Error; err != nil {
+	lots, err := QueryNearbyParkingLots(q.Latitude, q.Longitude, q.Radius)
+	if err != nil {
 		log.Printf("Failed to query parking lots: %v", err)
-		return nil, fmt.Errorf("failed to query parking lots: %w", err)
+		return nil, "", err
 	}
 
-	// 計算剩餘車位（已改用 end_time IS NULL）
-	filteredLots := make([]models.ParkingLot, 0, len(lots))
+	now := time.Now()
+	candidates := make([]models.ParkingLot, 0, len(lots))
 	for _, lot := range lots {
-		var parkingCount int64
-		err := database.DB.Model(&models.Rent{}).
-			Where("parking_lot_id = ? AND end_time IS NULL", lot.ParkingLotID).
-			Count(&parkingCount).Error
-		if err != nil {
-			log.Printf("Failed to count active parking for lot %d: %v", lot.ParkingLotID, err)
+		if q.Type != "" && lot.Type != q.Type {
 			continue
 		}
+		if q.MaxHourlyRate != nil && lot.HourlyRate > *q.MaxHourlyRate {
+			continue
+		}
+		if q.VehicleClass != "" && !lot.AcceptsVehicleClass(q.VehicleClass) {
+			continue
+		}
+		if q.OpenNow && !lot.IsOpenAt(now) {
+			continue
+		}
+		candidates = append(candidates, lot)
+	}
 
-		remaining := lot.TotalSpots - int(parkingCount)
-		if remaining > 0 {
-			lot.RemainingSpots = remaining
-			filteredLots = append(filteredLots, lot)
+	// 一次查詢計算所有候選停車場的剩餘車位
+	if err := ApplyRemainingSpots(candidates); err != nil {
+		log.Printf("Failed to count active parking: %v", err)
+		return nil, "", err
+	}
+
+	filteredLots := make([]models.ParkingLot, 0, len(candidates))
+	for _, lot := range candidates {
+		if !q.IncludeFull && lot.RemainingSpots < q.MinFreeSpots {
+			continue
 		}
+		distance := math.Round(haversineKm(q.Latitude, q.Longitude, lot.Latitude, lot.Longitude)*1000) / 1000
+		lot.DistanceKm = &distance
+		filteredLots = append(filteredLots, lot)
+	}
+
+	page, nextCursor, err := sortAndPaginateLots(filteredLots, q)
+	if err != nil {
+		return nil, "", err
 	}
 
-	log.Printf("Found %d parking lots with available spots within %.1f km", len(filteredLots), radius)
-	return filteredLots, nil
+	log.Printf("Found %d parking lots with available spots within %.1f km (page %d, sort=%s)",
+		len(filteredLots), q.Radius, len(page), q.SortBy)
+	return page, nextCursor, nil
 }
 
 // GetParkingLotByID 查詢單一停車場（含即時剩餘車位）
@@ -74,16 +96,8 @@ func GetParkingLotByID(id int) (*models.ParkingLot, error) {
 	}
 
 	// 計算即時剩餘車位
-	var pendingCount int64
-	if err := database.DB.Model(&models.Rent{}).
-		Where("parking_lot_id = ? AND status = ?", id, "pending").
-		Count(&pendingCount).Error; err != nil {
-		lot.RemainingSpots = 0
-	} else {
-		lot.RemainingSpots = lot.TotalSpots - int(pendingCount)
-		if lot.RemainingSpots < 0 {
-			lot.RemainingSpots = 0
-		}
+	if err := ApplyRemainingSpot(&lot); err != nil {
+		return nil, err
 	}
 
 	return &lot, nil
@@ -91,6 +105,18 @@ func GetParkingLotByID(id int) (*models.ParkingLot, error) {
 
 // CreateParkingLot 只建立停車場，不建立任何 spot
 func CreateParkingLot(lot *models.ParkingLot) error {
+	classes, err := NormalizeVehicleClasses(lot.VehicleClasses)
+	if err != nil {
+		return err
+	}
+	lot.VehicleClasses = classes
+	if err := validateOpeningTime(lot.OpenTime); err != nil {
+		return err
+	}
+	if err := validateOpeningTime(lot.CloseTime); err != nil {
+		return err
+	}
+
 	if err := database.DB.Create(lot).Error; err != nil {
 		return fmt.Errorf("failed to create parking lot: %w", err)
 	}
@@ -105,62 +131,186 @@ func UpdateParkingLot(id int, updatedFields map[string]interface{}) (*models.Par
 		return nil, fmt.Errorf("parking lot %d not found: %w", id, err)
 	}
 
+	if v, ok := updatedFields["vehicle_classes"].(string); ok {
+		classes, err := NormalizeVehicleClasses(v)
+		if err != nil {
+			return nil, err
+		}
+		updatedFields["vehicle_classes"] = classes
+	}
+	for _, key := range []string{"open_time", "close_time"} {
+		if v, ok := updatedFields[key].(string); ok {
+			if err := validateOpeningTime(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// 直接更新允許的欄位
 	if err := database.DB.Model(&lot).Updates(updatedFields).Error; err != nil {
 		return nil, fmt.Errorf("failed to update parking lot %d: %w", id, err)
 	}
 
 	// 重新計算剩餘車位
-	var pending int64
-	database.DB.Model(&models.Rent{}).
-		Where("parking_lot_id = ? AND status = ?", id, "pending").
-		Count(&pending)
-	lot.RemainingSpots = lot.TotalSpots - int(pending)
-	if lot.RemainingSpots < 0 {
-		lot.RemainingSpots = 0
+	if err := ApplyRemainingSpot(&lot); err != nil {
+		return nil, err
 	}
 
 	log.Printf("Successfully updated parking lot %d, new total spots: %d", id, lot.TotalSpots)
 	return &lot, nil
 }
 
-// DeleteParkingLot 刪除停車場（連同所有 rent 紀錄一起刪）
-func DeleteParkingLot(id int) error {
-	tx := database.DB.Begin()
+// DefaultPurgeRetentionDays 停用停車場的紀錄須保留多少天後才能永久刪除
+const DefaultPurgeRetentionDays = 1825
 
-	// 刪除該停車場的所有 rent 紀錄
-	if err := tx.Where("parking_lot_id = ?", id).Delete(&models.Rent{}).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to delete rents for lot %d: %w", id, err)
+// purgeRetentionDays 讀取 PARKING_PURGE_RETENTION_DAYS，未設定時使用預設值
+func purgeRetentionDays() int {
+	if v, err := strconv.Atoi(os.Getenv("PARKING_PURGE_RETENTION_DAYS")); err == nil && v > 0 {
+		return v
 	}
+	return DefaultPurgeRetentionDays
+}
 
-	// 刪除停車場
-	if err := tx.Delete(&models.ParkingLot{}, id).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to delete parking lot %d: %w", id, err)
+// ArchiveParkingLot 停用停車場（保留所有 rent 紀錄，停用後不可搜尋、不可進場）
+func ArchiveParkingLot(id int) (*models.ParkingLot, error) {
+	var lot models.ParkingLot
+	if err := database.DB.First(&lot, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("parking lot %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to get parking lot %d: %w", id, err)
+	}
+	if lot.IsArchived() {
+		return nil, fmt.Errorf("parking lot %d is already archived", id)
 	}
 
-	tx.Commit()
-	log.Printf("Successfully deleted parking lot %d and all its rent records", id)
+	counts, err := GetActiveCounts([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	if counts[id] > 0 {
+		return nil, fmt.Errorf("parking lot %d has %d active parking sessions", id, counts[id])
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&lot).Update("archived_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to archive parking lot %d: %w", id, err)
+	}
+	lot.ArchivedAt = &now
+
+	log.Printf("PARKING_LOT_ARCHIVED | parking_lot_id=%d", id)
+	return &lot, nil
+}
+
+// RestoreParkingLot 重新啟用已停用的停車場
+func RestoreParkingLot(id int) (*models.ParkingLot, error) {
+	var lot models.ParkingLot
+	if err := database.DB.First(&lot, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("parking lot %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to get parking lot %d: %w", id, err)
+	}
+	if !lot.IsArchived() {
+		return nil, fmt.Errorf("parking lot %d is not archived", id)
+	}
+
+	if err := database.DB.Model(&lot).Update("archived_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("failed to restore parking lot %d: %w", id, err)
+	}
+	lot.ArchivedAt = nil
+	if err := ApplyRemainingSpot(&lot); err != nil {
+		return nil, err
+	}
+
+	log.Printf("PARKING_LOT_RESTORED | parking_lot_id=%d", id)
+	return &lot, nil
+}
+
+// PurgeParkingLot 永久刪除已停用的停車場與其 rent、統計、名單、異常等相關紀錄
+// 僅在停用時間與最後一筆紀錄都早於保留期限時允許
+func PurgeParkingLot(id int) error {
+	var lot models.ParkingLot
+	if err := database.DB.First(&lot, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("parking lot %d not found", id)
+		}
+		return fmt.Errorf("failed to get parking lot %d: %w", id, err)
+	}
+	if !lot.IsArchived() {
+		return fmt.Errorf("parking lot %d is not archived", id)
+	}
+
+	retentionDays := purgeRetentionDays()
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	if lot.ArchivedAt.After(cutoff) {
+		return fmt.Errorf("retention period not reached: parking lot %d archived at %s, retention %d days",
+			id, lot.ArchivedAt.Format(time.RFC3339), retentionDays)
+	}
+
+	var recent int64
+	if err := database.DB.Model(&models.Rent{}).
+		Where("parking_lot_id = ? AND (end_time IS NULL OR end_time > ?)", id, cutoff).
+		Count(&recent).Error; err != nil {
+		return fmt.Errorf("failed to check rent records for lot %d: %w", id, err)
+	}
+	if recent > 0 {
+		return fmt.Errorf("retention period not reached: parking lot %d has %d rent records within %d days",
+			id, recent, retentionDays)
+	}
+
+	var deletedRents int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("parking_lot_id = ?", id).Delete(&models.Rent{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete rents for lot %d: %w", id, result.Error)
+		}
+		deletedRents = result.RowsAffected
+
+		// 其他依附於停車場的資料一併刪除；全域黑白名單（parking_lot_id 為 NULL）不受影響
+		related := []struct {
+			name  string
+			model interface{}
+		}{
+			{"daily stats", &models.LotDailyStat{}},
+			{"plate list entries", &models.PlateListEntry{}},
+			{"entry denials", &models.EntryDenial{}},
+			{"exit reviews", &models.ExitReview{}},
+			{"anomalies", &models.Anomaly{}},
+			{"occupancy snapshots", &models.OccupancySnapshot{}},
+		}
+		for _, r := range related {
+			if err := tx.Where("parking_lot_id = ?", id).Delete(r.model).Error; err != nil {
+				return fmt.Errorf("failed to delete %s for lot %d: %w", r.name, id, err)
+			}
+		}
+
+		if err := tx.Delete(&models.ParkingLot{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete parking lot %d: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("PARKING_LOT_PURGED | parking_lot_id=%d rents=%d retention_days=%d", id, deletedRents, retentionDays)
 	return nil
 }
 
-// GetAllParkingLots 取得所有停車場（含即時剩餘車位）
-func GetAllParkingLots() ([]models.ParkingLot, error) {
+// GetAllParkingLots 取得所有停車場（含即時剩餘車位），includeArchived 為 false 時排除已停用
+func GetAllParkingLots(includeArchived bool) ([]models.ParkingLot, error) {
 	var lots []models.ParkingLot
-	if err := database.DB.Find(&lots).Error; err != nil {
+	query := database.DB.Model(&models.ParkingLot{})
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	if err := query.Find(&lots).Error; err != nil {
 		return nil, fmt.Errorf("failed to query parking lots: %w", err)
 	}
 
-	for i := range lots {
-		var pending int64
-		database.DB.Model(&models.Rent{}).
-			Where("parking_lot_id = ? AND status = ?", lots[i].ParkingLotID, "pending").
-			Count(&pending)
-		lots[i].RemainingSpots = lots[i].TotalSpots - int(pending)
-		if lots[i].RemainingSpots < 0 {
-			lots[i].RemainingSpots = 0
-		}
+	if err := ApplyRemainingSpots(lots); err != nil {
+		return nil, err
 	}
 
 	return lots, nil
@@ -195,8 +345,8 @@ func GetParkingLotIncome(parkingLotID int, startDate, endDate time.Time) (*Incom
 
 	var rents []models.Rent
 	err := database.DB.
-		Where("parking_lot_id = ? AND end_time IS NOT NULL AND end_time BETWEEN ? AND ?",
-			parkingLotID, startDate, endDate).
+		Where("parking_lot_id = ? AND voided = ? AND end_time IS NOT NULL AND end_time BETWEEN ? AND ?",
+			parkingLotID, false, startDate, endDate).
 		Order("end_time DESC").
 		Find(&rents).Error
 	if err != nil {
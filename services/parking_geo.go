@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"project01/database"
+	"project01/models"
+	"strings"
+)
+
+// kmPerDegreeLat 每一緯度約等於的公里數
+const kmPerDegreeLat = 111.045
+
+// QueryNearbyParkingLots 查詢半徑內的停車場
+// 有空間索引時先以邊界框篩選再精算距離，否則退回哈弗辛全表掃描
+func QueryNearbyParkingLots(latitude, longitude, radius float64) ([]models.ParkingLot, error) {
+	if database.SpatialEnabled {
+		return queryLotsBySpatialIndex(latitude, longitude, radius)
+	}
+	return queryLotsByHaversine(latitude, longitude, radius)
+}
+
+// queryLotsByHaversine 舊的查詢方式：對每一列計算哈弗辛距離，無法使用索引
+func queryLotsByHaversine(latitude, longitude, radius float64) ([]models.ParkingLot, error) {
+	var lots []models.ParkingLot
+
+	// 哈弗辛公式（括號與 radians(?) 完全對齊！）
+	distanceSQL := `
+        (6371 * acos(
+            cos(radians(?)) * cos(radians(latitude)) *
+            cos(radians(longitude) - radians(?)) +
+            sin(radians(?)) * sin(radians(latitude))
+        ))
+    `
+
+	// 注意：這裡一定要傳 3 次 latitude, 一次 longitude
+	query := database.DB.
+		Where("archived_at IS NULL").
+		Where(distanceSQL+" <= ?", latitude, longitude, latitude, radius)
+
+	if err := query.Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("failed to query parking lots: %w", err)
+	}
+	return lots, nil
+}
+
+// queryLotsBySpatialIndex 以 location 空間索引做邊界框篩選，再用 ST_Distance_Sphere 精算距離
+// 缺少經緯度的停車場 location 為佔位的 POINT(0 0)，須排除
+func queryLotsBySpatialIndex(latitude, longitude, radius float64) ([]models.ParkingLot, error) {
+	var lots []models.ParkingLot
+
+	boxes := boundingBoxesWKT(latitude, longitude, radius)
+	conds := make([]string, len(boxes))
+	args := make([]interface{}, len(boxes))
+	for i, box := range boxes {
+		conds[i] = "MBRContains(ST_GeomFromText(?, 4326, 'axis-order=long-lat'), location)"
+		args[i] = box
+	}
+	query := database.DB.
+		Where("archived_at IS NULL").
+		Where("latitude IS NOT NULL AND longitude IS NOT NULL").
+		Where("("+strings.Join(conds, " OR ")+")", args...).
+		Where("ST_Distance_Sphere(location, ST_SRID(POINT(?, ?), 4326)) <= ?", longitude, latitude, radius*1000)
+
+	if err := query.Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("failed to query parking lots: %w", err)
+	}
+	return lots, nil
+}
+
+// boundingBoxesWKT 計算以中心點與半徑（公里）為範圍的矩形 WKT（經度在前）
+// 範圍跨越 ±180 度經線時拆成兩個矩形，涵蓋極區時經度取全範圍
+func boundingBoxesWKT(latitude, longitude, radius float64) []string {
+	dLat := radius / kmPerDegreeLat
+	dLon := 180.0
+	if cosLat := math.Cos(latitude * math.Pi / 180); cosLat > 1e-6 {
+		dLon = math.Min(radius/(kmPerDegreeLat*cosLat), 180)
+	}
+
+	minLat := math.Max(latitude-dLat, -90)
+	maxLat := math.Min(latitude+dLat, 90)
+	if dLon >= 180 {
+		return []string{boxWKT(-180, 180, minLat, maxLat)}
+	}
+
+	minLon := longitude - dLon
+	maxLon := longitude + dLon
+	switch {
+	case minLon < -180:
+		return []string{boxWKT(minLon+360, 180, minLat, maxLat), boxWKT(-180, maxLon, minLat, maxLat)}
+	case maxLon > 180:
+		return []string{boxWKT(minLon, 180, minLat, maxLat), boxWKT(-180, maxLon-360, minLat, maxLat)}
+	}
+	return []string{boxWKT(minLon, maxLon, minLat, maxLat)}
+}
+
+// boxWKT 產生矩形 POLYGON WKT（經度在前）
+func boxWKT(minLon, maxLon, minLat, maxLat float64) string {
+	return fmt.Sprintf("POLYGON((%[1]f %[3]f, %[2]f %[3]f, %[2]f %[4]f, %[1]f %[4]f, %[1]f %[3]f))",
+		minLon, maxLon, minLat, maxLat)
+}
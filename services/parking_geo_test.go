@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"project01/database"
+	"project01/models"
+	"strings"
+	"testing"
+)
+
+// 基準測試的查詢點（台北車站）與半徑（公里）
+const (
+	benchLatitude  = 25.0478
+	benchLongitude = 121.5170
+	benchRadius    = 2.0
+)
+
+// seedNearbyBenchmark 在台灣範圍內隨機建立 n 個停車場並建立空間欄位
+func seedNearbyBenchmark(b *testing.B, n int) {
+	b.Helper()
+	setupTestDB(b, &models.ParkingLot{})
+
+	r := rand.New(rand.NewSource(1))
+	lots := make([]models.ParkingLot, n)
+	for i := range lots {
+		lots[i] = models.ParkingLot{
+			Type:       "flat",
+			Address:    fmt.Sprintf("bench lot %d", i),
+			HourlyRate: 30,
+			TotalSpots: 50,
+			Latitude:   21.9 + r.Float64()*3.4,
+			Longitude:  120.0 + r.Float64()*2.0,
+		}
+	}
+	if err := database.DB.CreateInBatches(lots, 500).Error; err != nil {
+		b.Fatalf("failed to seed parking lots: %v", err)
+	}
+	database.EnsureParkingLotLocation()
+}
+
+// benchmarkNearby 以相同資料比較兩種附近查詢的耗時
+func benchmarkNearby(b *testing.B, n int, query func(latitude, longitude, radius float64) ([]models.ParkingLot, error)) {
+	seedNearbyBenchmark(b, n)
+	if !database.SpatialEnabled {
+		b.Skip("spatial index unavailable")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := query(benchLatitude, benchLongitude, benchRadius); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQueryLotsByHaversine(b *testing.B) {
+	for _, n := range []int{1000, 20000} {
+		b.Run(fmt.Sprintf("lots=%d", n), func(b *testing.B) {
+			benchmarkNearby(b, n, queryLotsByHaversine)
+		})
+	}
+}
+
+func BenchmarkQueryLotsBySpatialIndex(b *testing.B) {
+	for _, n := range []int{1000, 20000} {
+		b.Run(fmt.Sprintf("lots=%d", n), func(b *testing.B) {
+			benchmarkNearby(b, n, queryLotsBySpatialIndex)
+		})
+	}
+}
+
+// TestNearbyQueriesAgree 兩種查詢路徑應回傳相同的停車場，且排除缺少經緯度的停車場
+func TestNearbyQueriesAgree(t *testing.T) {
+	setupTestDB(t, &models.ParkingLot{})
+
+	lots := []models.ParkingLot{
+		{Type: "flat", Address: "near", HourlyRate: 30, TotalSpots: 10, Latitude: 25.0500, Longitude: 121.5200},
+		{Type: "flat", Address: "far", HourlyRate: 30, TotalSpots: 10, Latitude: 25.1500, Longitude: 121.5200},
+		{Type: "flat", Address: "origin", HourlyRate: 30, TotalSpots: 10, Latitude: 0, Longitude: 0},
+	}
+	if err := database.DB.Create(&lots).Error; err != nil {
+		t.Fatalf("failed to seed parking lots: %v", err)
+	}
+	noCoords := models.ParkingLot{Type: "flat", Address: "no coordinates", HourlyRate: 30, TotalSpots: 10}
+	if err := database.DB.Create(&noCoords).Error; err != nil {
+		t.Fatalf("failed to seed parking lot: %v", err)
+	}
+	if err := database.DB.Exec("UPDATE parking_lot SET latitude = NULL, longitude = NULL WHERE parking_lot_id = ?",
+		noCoords.ParkingLotID).Error; err != nil {
+		t.Fatalf("failed to clear coordinates: %v", err)
+	}
+	database.EnsureParkingLotLocation()
+	if !database.SpatialEnabled {
+		t.Skip("spatial index unavailable")
+	}
+
+	for _, tc := range []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		want      []string
+	}{
+		{"taipei", benchLatitude, benchLongitude, []string{"near"}},
+		{"null island", 0, 0, []string{"origin"}},
+	} {
+		haversine, err := queryLotsByHaversine(tc.latitude, tc.longitude, benchRadius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spatial, err := queryLotsBySpatialIndex(tc.latitude, tc.longitude, benchRadius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, got := range map[string][]models.ParkingLot{"haversine": haversine, "spatial": spatial} {
+			if len(got) != len(tc.want) {
+				t.Fatalf("%s/%s: got %d lots, want %v", tc.name, path, len(got), tc.want)
+			}
+			for i, lot := range got {
+				if lot.Address != tc.want[i] {
+					t.Errorf("%s/%s: got %q, want %q", tc.name, path, lot.Address, tc.want[i])
+				}
+			}
+		}
+	}
+}
+
+// TestBoundingBoxesWKT 邊界框在跨越 ±180 度經線時應拆成兩個矩形
+func TestBoundingBoxesWKT(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		longitude float64
+		want      []string
+	}{
+		{"taipei", 121.5, []string{
+			"POLYGON((121.490995 -0.009005, 121.509005 -0.009005, 121.509005 0.009005, 121.490995 0.009005, 121.490995 -0.009005))",
+		}},
+		{"east of antimeridian", 179.995, []string{
+			"POLYGON((179.985995 -0.009005, 180.000000 -0.009005, 180.000000 0.009005, 179.985995 0.009005, 179.985995 -0.009005))",
+			"POLYGON((-180.000000 -0.009005, -179.995995 -0.009005, -179.995995 0.009005, -180.000000 0.009005, -180.000000 -0.009005))",
+		}},
+		{"west of antimeridian", -179.995, []string{
+			"POLYGON((179.995995 -0.009005, 180.000000 -0.009005, 180.000000 0.009005, 179.995995 0.009005, 179.995995 -0.009005))",
+			"POLYGON((-180.000000 -0.009005, -179.985995 -0.009005, -179.985995 0.009005, -180.000000 0.009005, -180.000000 -0.009005))",
+		}},
+	} {
+		got := boundingBoxesWKT(0, tc.longitude, 1)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d boxes %v, want %d", tc.name, len(got), got, len(tc.want))
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: box %d = %s, want %s", tc.name, i, got[i], tc.want[i])
+			}
+		}
+	}
+
+	// 極點附近經度取全範圍
+	if got := boundingBoxesWKT(90, 0, 1); len(got) != 1 || !strings.HasPrefix(got[0], "POLYGON((-180.000000 ") {
+		t.Errorf("pole: got %v, want a single full-longitude box", got)
+	}
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"project01/database"
+	"project01/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ParkingLotImportRow 匯入檔中的一列停車場資料
+type ParkingLotImportRow struct {
+	Row            int
+	ExternalRef    string
+	Type           string
+	Address        string
+	HourlyRate     float64
+	TotalSpots     int
+	Longitude      float64
+	Latitude       float64
+	VehicleClasses string
+	OpenTime       string
+	CloseTime      string
+	Fields         map[string]bool // 匯入檔中有填值的欄位，更新時只寫入這些欄位
+	Errors         []string        // 解析階段的錯誤
+}
+
+// importFields 匯入檔可寫入停車場的欄位
+var importFields = []string{
+	"type", "address", "hourly_rate", "total_spots", "longitude", "latitude",
+	"vehicle_classes", "open_time", "close_time",
+}
+
+// markImportFields 記錄有填值的欄位
+func markImportFields(row *ParkingLotImportRow, value func(name string) string) {
+	row.Fields = map[string]bool{}
+	for _, name := range importFields {
+		if value(name) != "" {
+			row.Fields[name] = true
+		}
+	}
+}
+
+// ImportRowResult 單列匯入結果
+type ImportRowResult struct {
+	Row          int      `json:"row"`
+	ExternalRef  string   `json:"external_ref"`
+	Action       string   `json:"action"` // create / update / error
+	ParkingLotID int      `json:"parking_lot_id,omitempty"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// ImportResult 批次匯入結果，任一列有錯誤時不寫入任何資料
+type ImportResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Applied bool              `json:"applied"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// GeoJSONFeatureCollection GeoJSON FeatureCollection
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature GeoJSON Feature（僅支援 Point）
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry GeoJSON Geometry
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// ParseParkingLotCSV 解析停車場 CSV（第一列為欄位名稱）
+func ParseParkingLotCSV(r io.Reader) ([]ParkingLotImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: failed to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	for _, required := range []string{"external_ref", "longitude", "latitude"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("invalid csv: missing column %s", required)
+		}
+	}
+
+	var rows []ParkingLotImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// 欄位數不符等錯誤只影響該列
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				rows = append(rows, ParkingLotImportRow{Row: line, Errors: []string{"wrong number of fields"}})
+				continue
+			}
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		get := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := ParkingLotImportRow{
+			Row:            line,
+			ExternalRef:    get("external_ref"),
+			Type:           get("type"),
+			Address:        get("address"),
+			VehicleClasses: get("vehicle_classes"),
+			OpenTime:       get("open_time"),
+			CloseTime:      get("close_time"),
+		}
+		row.HourlyRate = parseImportFloat(&row, "hourly_rate", get("hourly_rate"))
+		row.TotalSpots = parseImportInt(&row, "total_spots", get("total_spots"))
+		row.Longitude = parseImportFloat(&row, "longitude", get("longitude"))
+		row.Latitude = parseImportFloat(&row, "latitude", get("latitude"))
+		markImportFields(&row, get)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseParkingLotGeoJSON 解析 GeoJSON FeatureCollection，每個 Point Feature 為一個停車場
+func ParseParkingLotGeoJSON(r io.Reader) ([]ParkingLotImportRow, error) {
+	var fc GeoJSONFeatureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("invalid geojson: %w", err)
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("invalid geojson: type must be FeatureCollection")
+	}
+
+	rows := make([]ParkingLotImportRow, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		row := ParkingLotImportRow{Row: i + 1}
+		hasPoint := f.Geometry != nil && f.Geometry.Type == "Point" && len(f.Geometry.Coordinates) >= 2
+		if !hasPoint {
+			row.Errors = append(row.Errors, "geometry must be a Point with [longitude, latitude]")
+		} else {
+			row.Longitude = f.Geometry.Coordinates[0]
+			row.Latitude = f.Geometry.Coordinates[1]
+		}
+
+		prop := func(name string) string {
+			v, ok := f.Properties[name]
+			if !ok || v == nil {
+				return ""
+			}
+			if s, ok := v.(string); ok {
+				return strings.TrimSpace(s)
+			}
+			return strings.TrimSpace(fmt.Sprint(v))
+		}
+		row.ExternalRef = prop("external_ref")
+		if row.ExternalRef == "" && f.ID != nil {
+			row.ExternalRef = strings.TrimSpace(fmt.Sprint(f.ID))
+		}
+		row.Type = prop("type")
+		row.Address = prop("address")
+		row.VehicleClasses = prop("vehicle_classes")
+		row.OpenTime = prop("open_time")
+		row.CloseTime = prop("close_time")
+		row.HourlyRate = parseImportFloat(&row, "hourly_rate", prop("hourly_rate"))
+		row.TotalSpots = parseImportInt(&row, "total_spots", prop("total_spots"))
+		markImportFields(&row, prop)
+		// 經緯度來自 geometry 而非 properties
+		row.Fields["longitude"], row.Fields["latitude"] = hasPoint, hasPoint
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportFloat(row *ParkingLotImportRow, field, value string) float64 {
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		row.Errors = append(row.Errors, fmt.Sprintf("%s: invalid number %q", field, value))
+	}
+	return f
+}
+
+func parseImportInt(row *ParkingLotImportRow, field, value string) int {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		row.Errors = append(row.Errors, fmt.Sprintf("%s: invalid integer %q", field, value))
+	}
+	return n
+}
+
+// validateImportRow 驗證一列資料，回傳錯誤訊息清單
+// creating 為新增的停車場，須提供費率與經緯度；更新時未提供的欄位維持原值
+func validateImportRow(row *ParkingLotImportRow, creating bool) []string {
+	errs := append([]string{}, row.Errors...)
+
+	if row.ExternalRef == "" {
+		errs = append(errs, "external_ref is required")
+	} else if len(row.ExternalRef) > 64 {
+		errs = append(errs, "external_ref must be at most 64 characters")
+	}
+	if row.Type != "" && row.Type != "flat" && row.Type != "mechanical" {
+		errs = append(errs, "type must be flat or mechanical")
+	}
+	if len([]rune(row.Address)) > 100 {
+		errs = append(errs, "address must be at most 100 characters")
+	}
+	if row.Fields["hourly_rate"] && row.HourlyRate <= 0 {
+		errs = append(errs, "hourly_rate must be > 0")
+	}
+	if creating {
+		for _, name := range []string{"hourly_rate", "longitude", "latitude"} {
+			if !row.Fields[name] {
+				errs = append(errs, name+" is required for a new parking lot")
+			}
+		}
+	}
+	if row.TotalSpots < 0 {
+		errs = append(errs, "total_spots must be >= 0")
+	}
+	if row.Longitude < -180 || row.Longitude > 180 {
+		errs = append(errs, "longitude must be between -180 and 180")
+	}
+	if row.Latitude < -90 || row.Latitude > 90 {
+		errs = append(errs, "latitude must be between -90 and 90")
+	}
+	classes, err := NormalizeVehicleClasses(row.VehicleClasses)
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		row.VehicleClasses = classes
+	}
+	if err := validateOpeningTime(row.OpenTime); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := validateOpeningTime(row.CloseTime); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+// ImportParkingLots 依 external_ref 新增或更新停車場
+// dryRun 時只驗證不寫入；任一列有錯誤時整批不寫入
+func ImportParkingLots(rows []ParkingLotImportRow, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun, Total: len(rows), Rows: make([]ImportRowResult, 0, len(rows))}
+
+	refs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.ExternalRef != "" {
+			refs = append(refs, row.ExternalRef)
+		}
+	}
+
+	existing := map[string]models.ParkingLot{}
+	if len(refs) > 0 {
+		var lots []models.ParkingLot
+		if err := database.DB.Where("external_ref IN ?", refs).Find(&lots).Error; err != nil {
+			return nil, fmt.Errorf("failed to query existing parking lots: %w", err)
+		}
+		for _, lot := range lots {
+			existing[*lot.ExternalRef] = lot
+		}
+	}
+
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		res := ImportRowResult{Row: row.Row, ExternalRef: row.ExternalRef}
+		res.Errors = validateImportRow(row, existing[row.ExternalRef].ParkingLotID == 0)
+		if first, ok := seen[row.ExternalRef]; ok && row.ExternalRef != "" {
+			res.Errors = append(res.Errors, fmt.Sprintf("duplicate external_ref, first seen in row %d", first))
+		} else if row.ExternalRef != "" {
+			seen[row.ExternalRef] = row.Row
+		}
+
+		switch {
+		case len(res.Errors) > 0:
+			res.Action = "error"
+			result.Failed++
+		case existing[row.ExternalRef].ParkingLotID > 0:
+			res.Action = "update"
+			res.ParkingLotID = existing[row.ExternalRef].ParkingLotID
+			result.Updated++
+		default:
+			res.Action = "create"
+			result.Created++
+		}
+		result.Rows = append(result.Rows, res)
+	}
+
+	if dryRun || result.Failed > 0 {
+		return result, nil
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for i, row := range rows {
+			ref := row.ExternalRef
+			fields := map[string]interface{}{
+				"type":            row.Type,
+				"address":         row.Address,
+				"hourly_rate":     row.HourlyRate,
+				"total_spots":     row.TotalSpots,
+				"longitude":       row.Longitude,
+				"latitude":        row.Latitude,
+				"vehicle_classes": row.VehicleClasses,
+				"open_time":       row.OpenTime,
+				"close_time":      row.CloseTime,
+			}
+			if result.Rows[i].Action == "update" {
+				// 只更新匯入檔中有填值的欄位，未提供的欄位維持原值
+				for name := range fields {
+					if !row.Fields[name] {
+						delete(fields, name)
+					}
+				}
+				if len(fields) == 0 {
+					continue
+				}
+				if err := tx.Model(&models.ParkingLot{}).
+					Where("parking_lot_id = ?", result.Rows[i].ParkingLotID).
+					Updates(fields).Error; err != nil {
+					return fmt.Errorf("row %d: failed to update parking lot: %w", row.Row, err)
+				}
+				continue
+			}
+
+			lot := models.ParkingLot{
+				Type:           row.Type,
+				Address:        row.Address,
+				HourlyRate:     row.HourlyRate,
+				TotalSpots:     row.TotalSpots,
+				Longitude:      row.Longitude,
+				Latitude:       row.Latitude,
+				VehicleClasses: row.VehicleClasses,
+				OpenTime:       row.OpenTime,
+				CloseTime:      row.CloseTime,
+				ExternalRef:    &ref,
+			}
+			if err := tx.Create(&lot).Error; err != nil {
+				return fmt.Errorf("row %d: failed to create parking lot: %w", row.Row, err)
+			}
+			result.Rows[i].ParkingLotID = lot.ParkingLotID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Applied = true
+	log.Printf("PARKING_IMPORT | total=%d created=%d updated=%d", result.Total, result.Created, result.Updated)
+	return result, nil
+}
+
+// ExportParkingLotsGeoJSON 匯出所有停車場為 GeoJSON FeatureCollection（含即時剩餘車位）
+func ExportParkingLotsGeoJSON() (*GeoJSONFeatureCollection, error) {
+	lots, err := GetAllParkingLots(false)
+	if err != nil {
+		return nil, err
+	}
+
+	fc := &GeoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]GeoJSONFeature, 0, len(lots))}
+	for _, lot := range lots {
+		props := map[string]interface{}{
+			"parking_lot_id":  lot.ParkingLotID,
+			"type":            lot.Type,
+			"address":         lot.Address,
+			"hourly_rate":     lot.HourlyRate,
+			"total_spots":     lot.TotalSpots,
+			"remaining_spots": lot.RemainingSpots,
+			"vehicle_classes": lot.VehicleClasses,
+			"open_time":       lot.OpenTime,
+			"close_time":      lot.CloseTime,
+		}
+		if lot.ExternalRef != nil {
+			props["external_ref"] = *lot.ExternalRef
+		}
+		fc.Features = append(fc.Features, GeoJSONFeature{
+			Type:       "Feature",
+			ID:         lot.ParkingLotID,
+			Geometry:   &GeoJSONGeometry{Type: "Point", Coordinates: []float64{lot.Longitude, lot.Latitude}},
+			Properties: props,
+		})
+	}
+	return fc, nil
+}
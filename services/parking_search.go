@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"project01/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 附近停車場查詢的排序方式
+const (
+	SortByDistance  = "distance"
+	SortByPrice     = "price"
+	SortByRemaining = "remaining"
+)
+
+// 停車場可停車種
+var validVehicleClasses = map[string]bool{"car": true, "scooter": true}
+
+// AvailableLotQuery 附近停車場查詢條件
+type AvailableLotQuery struct {
+	Latitude      float64
+	Longitude     float64
+	Radius        float64
+	Type          string   // flat / mechanical，空值不篩選
+	MaxHourlyRate *float64 // 最高每小時費率
+	MinFreeSpots  int      // 最少剩餘車位，預設 1
+	IncludeFull   bool     // 不以剩餘車位篩選（供預估未來抵達時使用）
+	VehicleClass  string   // car / scooter，空值不篩選
+	OpenNow       bool     // 僅列出目前營業中的停車場
+	SortBy        string   // distance / price / remaining，預設 distance
+	Cursor        string   // 上一頁回傳的 next_cursor
+	Limit         int      // 每頁筆數，預設 20，最多 100
+}
+
+// lotCursor 分頁游標：排序方式、最後一筆的排序值與 ID
+type lotCursor struct {
+	SortBy string
+	Key    float64
+	ID     int
+}
+
+// NormalizeVehicleClasses 驗證並整理 vehicle_classes（逗號分隔），空值代表預設 car
+func NormalizeVehicleClasses(classes string) (string, error) {
+	seen := map[string]bool{}
+	list := []string{}
+	for _, c := range strings.Split(classes, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" || seen[c] {
+			continue
+		}
+		if !validVehicleClasses[c] {
+			return "", fmt.Errorf("invalid vehicle class: %s", c)
+		}
+		seen[c] = true
+		list = append(list, c)
+	}
+	if len(list) == 0 {
+		return "car", nil
+	}
+	return strings.Join(list, ","), nil
+}
+
+// validateOpeningTime 營業時間須為空值或 HH:MM
+func validateOpeningTime(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", value); err != nil || len(value) != 5 {
+		return fmt.Errorf("invalid opening time %q, expected HH:MM", value)
+	}
+	return nil
+}
+
+// sortKey 依排序方式取得停車場的排序值（皆為遞增排序）
+func sortKey(lot models.ParkingLot, sortBy string) float64 {
+	switch sortBy {
+	case SortByPrice:
+		return lot.HourlyRate
+	case SortByRemaining:
+		return -float64(lot.RemainingSpots)
+	default:
+		if lot.DistanceKm != nil {
+			return *lot.DistanceKm
+		}
+		return 0
+	}
+}
+
+// sortAndPaginateLots 排序後依游標取出一頁，回傳該頁與下一頁游標
+func sortAndPaginateLots(lots []models.ParkingLot, q AvailableLotQuery) ([]models.ParkingLot, string, error) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		ki, kj := sortKey(lots[i], q.SortBy), sortKey(lots[j], q.SortBy)
+		if ki != kj {
+			return ki < kj
+		}
+		return lots[i].ParkingLotID < lots[j].ParkingLotID
+	})
+
+	start := 0
+	if q.Cursor != "" {
+		cursor, err := decodeLotCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if cursor.SortBy != q.SortBy {
+			return nil, "", fmt.Errorf("invalid cursor: sort_by mismatch")
+		}
+		start = sort.Search(len(lots), func(i int) bool {
+			k := sortKey(lots[i], q.SortBy)
+			return k > cursor.Key || (k == cursor.Key && lots[i].ParkingLotID > cursor.ID)
+		})
+	}
+
+	end := min(start+q.Limit, len(lots))
+	page := lots[start:end]
+
+	nextCursor := ""
+	if end < len(lots) && len(page) > 0 {
+		last := page[len(page)-1]
+		nextCursor = encodeLotCursor(lotCursor{SortBy: q.SortBy, Key: sortKey(last, q.SortBy), ID: last.ParkingLotID})
+	}
+	return page, nextCursor, nil
+}
+
+func encodeLotCursor(c lotCursor) string {
+	raw := fmt.Sprintf("%s|%s|%d", c.SortBy, strconv.FormatFloat(c.Key, 'g', -1, 64), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLotCursor(s string) (lotCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return lotCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return lotCursor{}, fmt.Errorf("invalid cursor")
+	}
+	key, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return lotCursor{}, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return lotCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return lotCursor{SortBy: parts[0], Key: key, ID: id}, nil
+}
+
+// haversineKm 計算兩點間的球面距離（公里）
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
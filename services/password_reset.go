@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 密碼重設 token 預設有效分鐘數，以及同一會員兩次申請的最短間隔
+const (
+	DefaultPasswordResetMinutes = 30
+	passwordResetCooldown       = time.Minute
+)
+
+// passwordResetTTL 讀取 PASSWORD_RESET_MINUTES，未設定時使用預設值
+func passwordResetTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_MINUTES")); err == nil && v > 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return DefaultPasswordResetMinutes * time.Minute
+}
+
+// RequestPasswordReset 申請重設密碼；查詢與寄信都在背景執行，
+// 呼叫端的回應內容與時間不會因 email 是否存在而不同
+func RequestPasswordReset(email, ipAddress string) {
+	go func() {
+		if err := processPasswordReset(email, ipAddress); err != nil {
+			log.Printf("Failed to process password reset request: %v", err)
+		}
+	}()
+}
+
+// processPasswordReset 建立重設 token 並寄出郵件；email 不存在或申請過於頻繁時不做任何事
+func processPasswordReset(email, ipAddress string) error {
+	var member models.Member
+	result := database.DB.Where("email = ?", email).Limit(1).Find(&member)
+	if result.Error != nil {
+		return fmt.Errorf("failed to query member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("PASSWORD_RESET_UNKNOWN_EMAIL | ip=%s", ipAddress)
+		return nil
+	}
+
+	now := time.Now()
+	var recent int64
+	if err := database.DB.Model(&models.PasswordResetToken{}).
+		Where("member_id = ? AND created_at > ?", member.MemberID, now.Add(-passwordResetCooldown)).
+		Count(&recent).Error; err != nil {
+		return fmt.Errorf("failed to check password reset requests: %w", err)
+	}
+	if recent > 0 {
+		log.Printf("PASSWORD_RESET_THROTTLED | member_id=%d ip=%s", member.MemberID, ipAddress)
+		return nil
+	}
+
+	plain, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	ttl := passwordResetTTL()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 只保留最新的一組 token
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("member_id = ? AND used_at IS NULL", member.MemberID).
+			Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to invalidate previous reset tokens: %w", err)
+		}
+		record := models.PasswordResetToken{
+			MemberID:  member.MemberID,
+			TokenHash: hashToken(plain),
+			ExpiresAt: now.Add(ttl),
+			RequestIP: truncateString(ipAddress, 45),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to save reset token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := mailer.Send(passwordResetMail(member, plain, ttl)); err != nil {
+		return fmt.Errorf("failed to send password reset mail to member %d: %w", member.MemberID, err)
+	}
+
+	log.Printf("PASSWORD_RESET_REQUESTED | member_id=%d ip=%s", member.MemberID, ipAddress)
+	return nil
+}
+
+// passwordResetMail 組成重設密碼郵件；設定 PASSWORD_RESET_URL 時附上連結（token 接在網址後面）
+func passwordResetMail(member models.Member, token string, ttl time.Duration) MailMessage {
+	var body strings.Builder
+	name := member.Name
+	if name == "" {
+		name = member.Email
+	}
+	fmt.Fprintf(&body, "%s 您好：\n\n我們收到了重設密碼的申請。", name)
+	if base := os.Getenv("PASSWORD_RESET_URL"); base != "" {
+		fmt.Fprintf(&body, "請在 %d 分鐘內開啟以下連結設定新密碼：\n\n%s%s\n\n", int(ttl.Minutes()), base, token)
+	} else {
+		fmt.Fprintf(&body, "請在 %d 分鐘內使用以下重設代碼設定新密碼：\n\n%s\n\n", int(ttl.Minutes()), token)
+	}
+	body.WriteString("此連結（代碼）僅能使用一次。若您沒有申請重設密碼，請忽略這封郵件。\n")
+
+	return MailMessage{
+		To:      []string{member.Email},
+		Subject: "重設密碼",
+		Body:    body.String(),
+	}
+}
+
+// ConfirmPasswordReset 以重設 token 設定新密碼；token 使用後立即失效，並登出該會員的所有裝置
+func ConfirmPasswordReset(token, newPassword string) error {
+	if len(newPassword) < 8 ||
+		!regexp.MustCompile(`[a-zA-Z]`).MatchString(newPassword) ||
+		!regexp.MustCompile(`[0-9]`).MatchString(newPassword) {
+		return fmt.Errorf("password must be at least 8 characters and include both letters and numbers")
+	}
+
+	var record models.PasswordResetToken
+	result := database.DB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to query reset token: %w", result.Error)
+	}
+	now := time.Now()
+	if result.RowsAffected == 0 || record.UsedAt != nil || now.After(record.ExpiresAt) {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 條件式更新，確保 token 只能使用一次
+		used := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", now)
+		if used.Error != nil {
+			return fmt.Errorf("failed to consume reset token: %w", used.Error)
+		}
+		if used.RowsAffected == 0 {
+			return fmt.Errorf("invalid or expired reset token")
+		}
+		updated := tx.Model(&models.Member{}).
+			Where("member_id = ?", record.MemberID).
+			Update("password", hashedPassword)
+		if updated.Error != nil {
+			return fmt.Errorf("failed to update password: %w", updated.Error)
+		}
+		if updated.RowsAffected == 0 {
+			return fmt.Errorf("invalid or expired reset token")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := RevokeAllMemberTokens(record.MemberID); err != nil {
+		log.Printf("Failed to revoke tokens after password reset for member %d: %v", record.MemberID, err)
+		return err
+	}
+
+	log.Printf("PASSWORD_RESET_COMPLETED | member_id=%d", record.MemberID)
+	return nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"testing"
+	"time"
+)
+
+// TestPasswordResetTokenSingleUse 重設 token 只能使用一次，過期的 token 不能使用
+func TestPasswordResetTokenSingleUse(t *testing.T) {
+	setupTestDB(t, append(authTables, &models.PasswordResetToken{})...)
+	member := seedMember(t, 11, "renter")
+
+	now := time.Now()
+	tokens := []models.PasswordResetToken{
+		{MemberID: member.MemberID, TokenHash: hashToken("valid-token"), ExpiresAt: now.Add(time.Hour)},
+		{MemberID: member.MemberID, TokenHash: hashToken("expired-token"), ExpiresAt: now.Add(-time.Minute)},
+	}
+	if err := database.DB.Create(&tokens).Error; err != nil {
+		t.Fatalf("failed to seed reset tokens: %v", err)
+	}
+
+	if err := ConfirmPasswordReset("expired-token", "newpassw0rd"); err == nil {
+		t.Error("expired reset token accepted")
+	}
+	if err := ConfirmPasswordReset("valid-token", "newpassw0rd"); err != nil {
+		t.Fatalf("first use failed: %v", err)
+	}
+	if err := ConfirmPasswordReset("valid-token", "another1pass"); err == nil {
+		t.Fatal("reset token accepted twice")
+	}
+
+	var stored models.Member
+	if err := database.DB.First(&stored, member.MemberID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !utils.CheckPasswordHash("newpassw0rd", stored.Password) {
+		t.Error("password not set by the first reset")
+	}
+	if stored.TokenVersion != member.TokenVersion+1 {
+		t.Errorf("token_version = %d, want %d", stored.TokenVersion, member.TokenVersion+1)
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PlateListFilter 查詢黑白名單的篩選條件
+type PlateListFilter struct {
+	LicensePlate   string
+	ParkingLotID   *int
+	ListType       string
+	IncludeExpired bool
+}
+
+// EntryDenialFilter 查詢拒絕進場紀錄的篩選條件
+type EntryDenialFilter struct {
+	LicensePlate string
+	ParkingLotID int
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+}
+
+// CreatePlateListEntry 新增黑白名單項目
+func CreatePlateListEntry(entry *models.PlateListEntry) error {
+	plate, err := utils.ValidateLicensePlate(entry.LicensePlate)
+	if err != nil {
+		return err
+	}
+	entry.LicensePlate = plate
+
+	if entry.ListType != "block" && entry.ListType != "allow" {
+		return fmt.Errorf("invalid list_type: must be 'block' or 'allow'")
+	}
+	if entry.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if entry.ExpiresAt != nil && entry.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+
+	if entry.ParkingLotID != nil {
+		var count int64
+		if err := database.DB.Model(&models.ParkingLot{}).
+			Where("parking_lot_id = ?", *entry.ParkingLotID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check parking lot: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("parking lot %d not found", *entry.ParkingLotID)
+		}
+	}
+
+	if err := database.DB.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create plate list entry: %w", err)
+	}
+
+	log.Printf("PLATE_LIST_ADD | id=%d license_plate=%s list_type=%s parking_lot_id=%v reason=%s",
+		entry.ID, entry.LicensePlate, entry.ListType, entry.ParkingLotID, entry.Reason)
+	return nil
+}
+
+// GetPlateListEntries 查詢黑白名單
+func GetPlateListEntries(filter PlateListFilter) ([]models.PlateListEntry, error) {
+	var entries []models.PlateListEntry
+
+	query := database.DB.Model(&models.PlateListEntry{})
+	if filter.LicensePlate != "" {
+		query = query.Where("license_plate = ?", utils.NormalizeLicensePlate(filter.LicensePlate))
+	}
+	if filter.ParkingLotID != nil {
+		query = query.Where("parking_lot_id = ?", *filter.ParkingLotID)
+	}
+	if filter.ListType != "" {
+		query = query.Where("list_type = ?", filter.ListType)
+	}
+	if !filter.IncludeExpired {
+		query = query.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+
+	if err := query.Order("created_at DESC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to query plate list: %w", err)
+	}
+	return entries, nil
+}
+
+// DeletePlateListEntry 刪除黑白名單項目
+func DeletePlateListEntry(id int) error {
+	result := database.DB.Delete(&models.PlateListEntry{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete plate list entry %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("plate list entry %d not found", id)
+	}
+
+	log.Printf("PLATE_LIST_DELETE | id=%d", id)
+	return nil
+}
+
+// CheckPlateAccess 檢查車牌是否可進入指定停車場，拒絕時會寫入 entry_denial（licensePlate 需已正規化）
+func CheckPlateAccess(licensePlate string, lot models.ParkingLot, at time.Time) error {
+	// 黑名單：全域或該停車場，且未過期
+	var blocked models.PlateListEntry
+	err := database.DB.
+		Where("license_plate = ? AND list_type = ?", licensePlate, "block").
+		Where("parking_lot_id IS NULL OR parking_lot_id = ?", lot.ParkingLotID).
+		Where("expires_at IS NULL OR expires_at > ?", at).
+		Order("created_at DESC").
+		First(&blocked).Error
+	if err == nil {
+		reason := fmt.Sprintf("blocklisted: %s", blocked.Reason)
+		logEntryDenial(licensePlate, lot.ParkingLotID, reason, &blocked.ID, at)
+		return fmt.Errorf("entry denied: license_plate=%s is %s", licensePlate, reason)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to check blocklist: %w", err)
+	}
+
+	if !lot.AllowlistOnly {
+		return nil
+	}
+
+	// 白名單限定停車場：必須有全域或該停車場的有效白名單
+	var allowed models.PlateListEntry
+	err = database.DB.
+		Where("license_plate = ? AND list_type = ?", licensePlate, "allow").
+		Where("parking_lot_id IS NULL OR parking_lot_id = ?", lot.ParkingLotID).
+		Where("expires_at IS NULL OR expires_at > ?", at).
+		First(&allowed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		reason := "not on allowlist"
+		logEntryDenial(licensePlate, lot.ParkingLotID, reason, nil, at)
+		return fmt.Errorf("entry denied: license_plate=%s is %s for parking_lot_id=%d", licensePlate, reason, lot.ParkingLotID)
+	} else if err != nil {
+		return fmt.Errorf("failed to check allowlist: %w", err)
+	}
+
+	return nil
+}
+
+// logEntryDenial 寫入拒絕進場紀錄（失敗只記 log，不影響回應）
+func logEntryDenial(licensePlate string, parkingLotID int, reason string, listEntryID *int, at time.Time) {
+	denial := &models.EntryDenial{
+		LicensePlate: licensePlate,
+		ParkingLotID: parkingLotID,
+		Reason:       reason,
+		ListEntryID:  listEntryID,
+		DeniedAt:     at,
+	}
+	if err := database.DB.Create(denial).Error; err != nil {
+		log.Printf("Failed to record entry denial for %s: %v", licensePlate, err)
+		return
+	}
+
+	log.Printf("ENTRY_DENIED | license_plate=%s parking_lot_id=%d reason=%s",
+		licensePlate, parkingLotID, reason)
+}
+
+// GetEntryDenials 查詢拒絕進場紀錄
+func GetEntryDenials(filter EntryDenialFilter) ([]models.EntryDenial, error) {
+	var denials []models.EntryDenial
+
+	query := database.DB.Model(&models.EntryDenial{})
+	if filter.LicensePlate != "" {
+		query = query.Where("license_plate = ?", utils.NormalizeLicensePlate(filter.LicensePlate))
+	}
+	if filter.ParkingLotID > 0 {
+		query = query.Where("parking_lot_id = ?", filter.ParkingLotID)
+	}
+	if filter.From != nil {
+		query = query.Where("denied_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("denied_at <= ?", *filter.To)
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	if err := query.Order("denied_at DESC").Limit(filter.Limit).Find(&denials).Error; err != nil {
+		return nil, fmt.Errorf("failed to query entry denials: %w", err)
+	}
+	return denials, nil
+}
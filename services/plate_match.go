@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FuzzyPlateMaxDistance 模糊比對可接受的最大編輯距離
+const FuzzyPlateMaxDistance = 1.0
+
+// FuzzyPlateAutoCloseDistance 可自動結束的最大編輯距離
+// 只允許一處易混淆字元（如 8/B）的差異，其他差異都須人工確認
+const FuzzyPlateAutoCloseDistance = 0.5
+
+// leaveWithFuzzyMatch 在停車場的進行中紀錄裡找出與辨識車牌相近的候選
+// 只有一個候選且差異僅為易混淆字元時直接結束該筆並標記；其餘送入人工確認佇列
+func leaveWithFuzzyMatch(plateRead string, parkingLotID int, endTime time.Time) (*models.Rent, error) {
+	var activeRents []models.Rent
+	if err := database.DB.
+		Preload("ParkingLot").
+		Where("parking_lot_id = ? AND end_time IS NULL", parkingLotID).
+		Find(&activeRents).Error; err != nil {
+		return nil, fmt.Errorf("failed to query active parking records: %w", err)
+	}
+
+	var candidates []models.Rent
+	var bestDistance float64
+	for _, r := range activeRents {
+		distance := utils.PlateDistance(plateRead, r.LicensePlate)
+		if distance <= FuzzyPlateMaxDistance {
+			candidates = append(candidates, r)
+			bestDistance = distance
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no active parking record found: license_plate=%s", plateRead)
+	}
+	if len(candidates) == 1 && bestDistance <= FuzzyPlateAutoCloseDistance {
+		rent := &candidates[0]
+		rent.ExitPlate = &plateRead
+		rent.FuzzyMatched = true
+		log.Printf("FUZZY_EXIT | plate_read=%s matched=%s parking_lot_id=%d",
+			plateRead, rent.LicensePlate, parkingLotID)
+		return closeRent(rent, endTime)
+	}
+
+	plates := make([]string, len(candidates))
+	for i, r := range candidates {
+		plates[i] = r.LicensePlate
+	}
+	review := &models.ExitReview{
+		ParkingLotID: parkingLotID,
+		PlateRead:    plateRead,
+		Candidates:   strings.Join(plates, ","),
+		EndTime:      endTime,
+		Status:       "pending",
+		CreatedAt:    time.Now(),
+	}
+	if err := database.DB.Create(review).Error; err != nil {
+		return nil, fmt.Errorf("failed to create exit review: %w", err)
+	}
+
+	log.Printf("EXIT_REVIEW_QUEUED | review_id=%d plate_read=%s parking_lot_id=%d candidates=%s",
+		review.ID, plateRead, parkingLotID, review.Candidates)
+	return nil, fmt.Errorf("exit pending attendant review: review_id=%d candidates=%s", review.ID, review.Candidates)
+}
+
+// GetExitReviews 查詢人工確認佇列
+func GetExitReviews(status string, parkingLotID int) ([]models.ExitReview, error) {
+	var reviews []models.ExitReview
+
+	query := database.DB.Model(&models.ExitReview{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if parkingLotID > 0 {
+		query = query.Where("parking_lot_id = ?", parkingLotID)
+	}
+
+	if err := query.Order("created_at DESC").Find(&reviews).Error; err != nil {
+		return nil, fmt.Errorf("failed to query exit reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+// ResolveExitReview 由管理員指定正確車牌並結束該筆停車紀錄
+func ResolveExitReview(id int, licensePlate string, resolvedBy int) (*models.Rent, error) {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
+	review, err := getPendingExitReview(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsPlate(strings.Split(review.Candidates, ","), licensePlate) {
+		return nil, fmt.Errorf("license plate %s is not a candidate of exit review %d", licensePlate, id)
+	}
+
+	var rent models.Rent
+	if err := database.DB.
+		Preload("ParkingLot").
+		Where("license_plate = ? AND parking_lot_id = ? AND end_time IS NULL", licensePlate, review.ParkingLotID).
+		Order("start_time DESC").
+		First(&rent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("no active parking record found: license_plate=%s parking_lot_id=%d", licensePlate, review.ParkingLotID)
+		}
+		return nil, fmt.Errorf("failed to query active parking record: %w", err)
+	}
+
+	rent.ExitPlate = &review.PlateRead
+	rent.FuzzyMatched = true
+
+	// 結束停車紀錄與更新確認項目須同時成功，避免紀錄已結束但項目仍待處理
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ExitReview{}).
+			Where("id = ? AND status = ?", id, "pending").
+			Updates(map[string]interface{}{
+				"status":         "resolved",
+				"resolved_plate": licensePlate,
+				"resolved_by":    resolvedBy,
+				"resolved_at":    now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update exit review %d: %w", id, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("exit review %d is already resolved", id)
+		}
+		return closeRentTx(tx, &rent, review.EndTime)
+	})
+	if err != nil {
+		return nil, err
+	}
+	afterCloseRent(&rent)
+
+	log.Printf("EXIT_REVIEW_RESOLVED | review_id=%d license_plate=%s resolved_by=%d", id, licensePlate, resolvedBy)
+	return &rent, nil
+}
+
+// containsPlate 判斷車牌是否在候選清單中
+func containsPlate(plates []string, plate string) bool {
+	for _, p := range plates {
+		if p == plate {
+			return true
+		}
+	}
+	return false
+}
+
+// RejectExitReview 管理員判定無對應紀錄，關閉確認項目
+func RejectExitReview(id int, resolvedBy int) error {
+	review, err := getPendingExitReview(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(review).Updates(map[string]interface{}{
+		"status":      "rejected",
+		"resolved_by": resolvedBy,
+		"resolved_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update exit review %d: %w", id, err)
+	}
+
+	log.Printf("EXIT_REVIEW_REJECTED | review_id=%d resolved_by=%d", id, resolvedBy)
+	return nil
+}
+
+// getPendingExitReview 取得待處理的確認項目
+func getPendingExitReview(id int) (*models.ExitReview, error) {
+	var review models.ExitReview
+	if err := database.DB.First(&review, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("exit review %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to query exit review %d: %w", id, err)
+	}
+	if review.Status != "pending" {
+		return nil, fmt.Errorf("exit review %d is already %s", id, review.Status)
+	}
+	return &review, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"project01/models"
+	"sort"
+	"time"
+)
+
+// 推薦評分的預設權重
+const (
+	DefaultDistanceWeight     = 0.4
+	DefaultCostWeight         = 0.3
+	DefaultAvailabilityWeight = 0.3
+)
+
+// walkingSpeedKmh 步行速度；walkingDetourFactor 直線距離換算實際步行距離的係數
+const (
+	walkingSpeedKmh     = 5.0
+	walkingDetourFactor = 1.3
+)
+
+// RecommendationQuery 停車場推薦條件
+type RecommendationQuery struct {
+	Latitude        float64
+	Longitude       float64
+	DurationMinutes int       // 預計停車分鐘數
+	ArrivalTime     time.Time // 預計抵達時間，零值代表現在
+	MaxWalkKm       float64   // 可接受的最遠直線距離，預設 1 公里
+	MaxCost         *float64  // 可接受的最高預估費用
+	Type            string    // flat / mechanical
+	VehicleClass    string    // car / scooter
+	Weights         RecommendationWeights
+	Limit           int // 預設 10
+}
+
+// RecommendationWeights 各項評分權重（不需加總為 1）
+type RecommendationWeights struct {
+	Distance     float64 `json:"distance"`
+	Cost         float64 `json:"cost"`
+	Availability float64 `json:"availability"`
+}
+
+// ScoreBreakdown 各項分數（0~1，越高越好）與使用的權重
+type ScoreBreakdown struct {
+	Distance     float64               `json:"distance"`
+	Cost         float64               `json:"cost"`
+	Availability float64               `json:"availability"`
+	Weights      RecommendationWeights `json:"weights"`
+}
+
+// LotRecommendation 推薦結果
+type LotRecommendation struct {
+	Lot                  models.ParkingLotResponse `json:"lot"`
+	EstimatedCost        float64                   `json:"estimated_cost"`
+	WalkingMinutes       int                       `json:"walking_minutes"`
+	PredictedFreeSpots   int                       `json:"predicted_free_spots"`
+	ProbabilityAvailable float64                   `json:"probability_available"`
+	Score                float64                   `json:"score"`
+	Breakdown            ScoreBreakdown            `json:"breakdown"`
+}
+
+// EstimateRentCost 依停車場費率估算停車 durationMinutes 分鐘的費用
+func EstimateRentCost(lot models.ParkingLot, durationMinutes int) float64 {
+	return rentCostForMinutes(durationMinutes, lot.HourlyRate)
+}
+
+// RecommendParkingLots 以步行距離、預估費用、抵達時有空位的機率加權評分並排序附近停車場
+func RecommendParkingLots(q RecommendationQuery) ([]LotRecommendation, error) {
+	if q.DurationMinutes <= 0 {
+		return nil, fmt.Errorf("invalid duration: must be positive")
+	}
+	if q.MaxWalkKm <= 0 {
+		q.MaxWalkKm = 1.0
+	}
+	if q.Limit <= 0 || q.Limit > 50 {
+		q.Limit = 10
+	}
+	w := q.Weights
+	if w.Distance < 0 || w.Cost < 0 || w.Availability < 0 {
+		return nil, fmt.Errorf("invalid weights: must not be negative")
+	}
+	if w.Distance+w.Cost+w.Availability == 0 {
+		w = RecommendationWeights{DefaultDistanceWeight, DefaultCostWeight, DefaultAvailabilityWeight}
+	}
+
+	now := time.Now()
+	arrival := q.ArrivalTime
+	if arrival.IsZero() || arrival.Before(now) {
+		arrival = now
+	}
+
+	// 立即抵達時只考慮目前有空位的停車場；稍後抵達則由預測決定
+	lots, _, err := GetAvailableParkingLots(AvailableLotQuery{
+		Latitude:     q.Latitude,
+		Longitude:    q.Longitude,
+		Radius:       q.MaxWalkKm,
+		Type:         q.Type,
+		VehicleClass: q.VehicleClass,
+		IncludeFull:  arrival.Sub(now) > 30*time.Minute,
+		SortBy:       SortByDistance,
+		Limit:        100,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]models.ParkingLot, 0, len(lots))
+	costs := map[int]float64{}
+	for _, lot := range lots {
+		if !lot.IsOpenAt(arrival) {
+			continue
+		}
+		cost := EstimateRentCost(lot, q.DurationMinutes)
+		if q.MaxCost != nil && cost > *q.MaxCost {
+			continue
+		}
+		costs[lot.ParkingLotID] = cost
+		candidates = append(candidates, lot)
+	}
+	if len(candidates) == 0 {
+		return []LotRecommendation{}, nil
+	}
+
+	forecasts, err := ForecastParkingLots(candidates, arrival)
+	if err != nil {
+		return nil, err
+	}
+
+	minCost, maxCost := math.Inf(1), math.Inf(-1)
+	for _, c := range costs {
+		minCost = math.Min(minCost, c)
+		maxCost = math.Max(maxCost, c)
+	}
+
+	totalWeight := w.Distance + w.Cost + w.Availability
+	results := make([]LotRecommendation, len(candidates))
+	for i, lot := range candidates {
+		distance := 0.0
+		if lot.DistanceKm != nil {
+			distance = *lot.DistanceKm
+		}
+		cost := costs[lot.ParkingLotID]
+
+		breakdown := ScoreBreakdown{
+			Distance:     round3(math.Max(0, 1-distance/q.MaxWalkKm)),
+			Cost:         1,
+			Availability: forecasts[i].ProbabilityAvailable,
+			Weights:      w,
+		}
+		if maxCost > minCost {
+			breakdown.Cost = round3(1 - (cost-minCost)/(maxCost-minCost))
+		}
+		score := (w.Distance*breakdown.Distance + w.Cost*breakdown.Cost + w.Availability*breakdown.Availability) / totalWeight
+
+		results[i] = LotRecommendation{
+			Lot:                  lot.ToResponse(),
+			EstimatedCost:        cost,
+			WalkingMinutes:       int(math.Ceil(distance * walkingDetourFactor / walkingSpeedKmh * 60)),
+			PredictedFreeSpots:   forecasts[i].PredictedFreeSpots,
+			ProbabilityAvailable: forecasts[i].ProbabilityAvailable,
+			Score:                round3(score),
+			Breakdown:            breakdown,
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Lot.ParkingLotID < results[j].Lot.ParkingLotID
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -7,11 +7,29 @@ import (
 	"math"
 	"project01/database"
 	"project01/models"
+	"project01/utils"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// FreeGraceMinutes 前 15 分鐘免費
+const FreeGraceMinutes = 15
+
+// chargeableHours 扣除寬限期後的計費時數（每小時無條件進位），寬限期內為 0
+func chargeableHours(totalMinutes int) int {
+	if totalMinutes <= FreeGraceMinutes {
+		return 0
+	}
+	chargeableMinutes := totalMinutes - FreeGraceMinutes
+	return int(math.Ceil(float64(chargeableMinutes) / 60.0))
+}
+
+// rentCostForMinutes 依停車分鐘數與每小時費率計算費用（扣除寬限期後每小時無條件進位）
+func rentCostForMinutes(totalMinutes int, hourlyRate float64) float64 {
+	return float64(chargeableHours(totalMinutes)) * hourlyRate
+}
+
 // CalculateRentCost 計算租賃費用（前15分鐘完全免費！）
 func CalculateRentCost(startTime time.Time, endTime time.Time, parkingLot models.ParkingLot) (float64, error) {
 	if endTime.Before(startTime) {
@@ -26,26 +44,24 @@ func CalculateRentCost(startTime time.Time, endTime time.Time, parkingLot models
 	duration := endTime.Sub(startTime)
 	totalMinutes := int(math.Ceil(duration.Minutes()))
 
-	const FreeGraceMinutes = 15 //前 15 分鐘免費
-
 	if totalMinutes <= FreeGraceMinutes {
 		log.Printf("寬限期免費 | 停車 %d 分鐘 ≤ %d 分鐘，費用 0 元", totalMinutes, FreeGraceMinutes)
 		return 0, nil
 	}
 
 	// 超過寬限期才開始計費
-	chargeableMinutes := totalMinutes - FreeGraceMinutes
-	hours := int(math.Ceil(float64(chargeableMinutes) / 60.0))
-	totalCost := float64(hours) * parkingLot.HourlyRate
+	totalCost := rentCostForMinutes(totalMinutes, parkingLot.HourlyRate)
 
-	log.Printf("計費成功 | 總停 %d 分鐘，扣除寬限 %d 分鐘，計費 %d 分鐘，%d 小時 × %.0f 元 = %.0f 元",
-		totalMinutes, FreeGraceMinutes, chargeableMinutes, hours, parkingLot.HourlyRate, totalCost)
+	log.Printf("計費成功 | 總停 %d 分鐘，扣除寬限 %d 分鐘，%d 小時 × %.0f 元 = %.0f 元",
+		totalMinutes, FreeGraceMinutes, chargeableHours(totalMinutes), parkingLot.HourlyRate, totalCost)
 
 	return totalCost, nil
 }
 
 // EnterParkingSpot 進場記錄車牌和進場時間
 func EnterParkingSpot(licensePlate string, parkingLotID int, startTime time.Time) error {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
 	var vehicle models.Vehicle
 	if err := database.DB.Where("license_plate = ?", licensePlate).First(&vehicle).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -54,9 +70,31 @@ func EnterParkingSpot(licensePlate string, parkingLotID int, startTime time.Time
 		return fmt.Errorf("failed to query vehicle: %w", err)
 	}
 
+	// 車主須完成電子郵件與電話驗證才能進場
+	if err := RequireVerifiedMember(vehicle.MemberID); err != nil {
+		return err
+	}
+
+	var lot models.ParkingLot
+	if err := database.DB.First(&lot, parkingLotID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("parking lot not found: parking_lot_id=%d", parkingLotID)
+		}
+		return fmt.Errorf("failed to query parking lot: %w", err)
+	}
+	if lot.IsArchived() {
+		return fmt.Errorf("parking lot is archived: parking_lot_id=%d", parkingLotID)
+	}
+
+	// 黑白名單檢查
+	if err := CheckPlateAccess(licensePlate, lot, startTime); err != nil {
+		return err
+	}
+
 	rent := &models.Rent{
 		LicensePlate: licensePlate,
 		ParkingLotID: parkingLotID,
+		MemberID:     &vehicle.MemberID,
 		StartTime:    startTime,
 	}
 
@@ -71,7 +109,10 @@ func EnterParkingSpot(licensePlate string, parkingLotID int, startTime time.Time
 }
 
 // LeaveParkingSpot 出場記錄並計算費用
-func LeaveParkingSpot(licensePlate string, endTime time.Time) (*models.Rent, error) {
+// 找不到完全相符的車牌且有提供 parkingLotID 時，會在該停車場進行模糊比對
+func LeaveParkingSpot(licensePlate string, parkingLotID int, endTime time.Time) (*models.Rent, error) {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
 	var rent models.Rent
 
 	if err := database.DB.
@@ -81,62 +122,73 @@ func LeaveParkingSpot(licensePlate string, endTime time.Time) (*models.Rent, err
 		First(&rent).Error; err != nil {
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if parkingLotID > 0 {
+				return leaveWithFuzzyMatch(licensePlate, parkingLotID, endTime)
+			}
 			return nil, fmt.Errorf("no active parking record found: license_plate=%s", licensePlate)
 		}
 		return nil, fmt.Errorf("failed to query active parking record: %w", err)
 	}
 
+	return closeRent(&rent, endTime)
+}
+
+// closeRent 結束一筆停車紀錄並計算費用（rent 需已 Preload ParkingLot）
+func closeRent(rent *models.Rent, endTime time.Time) (*models.Rent, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return closeRentTx(tx, rent, endTime)
+	})
+	if err != nil {
+		return nil, err
+	}
+	afterCloseRent(rent)
+	return rent, nil // 關鍵！回傳完整 rent 紀錄
+}
+
+// closeRentTx 在指定的交易中計算費用、寫入出場時間並累加每日彙總
+// 交易提交後需呼叫 afterCloseRent 記錄
+func closeRentTx(tx *gorm.DB, rent *models.Rent, endTime time.Time) error {
 	totalCost, err := CalculateRentCost(rent.StartTime, endTime, rent.ParkingLot)
 	if err != nil {
-		return nil, fmt.Errorf("fee calculation failed: %w", err)
+		return fmt.Errorf("fee calculation failed: %w", err)
 	}
 
 	rent.EndTime = &endTime
 	rent.TotalCost = &totalCost
 
-	if err := database.DB.Save(&rent).Error; err != nil {
-		return nil, fmt.Errorf("exit update failed: %w", err)
+	if err := tx.Save(rent).Error; err != nil {
+		return fmt.Errorf("exit update failed: %w", err)
 	}
 
-	duration := endTime.Sub(rent.StartTime).Hours()
-	log.Printf("EXIT_SUCCESS | license_plate=%s parking_lot_id=%d duration_hours=%.2f cost=%.2f exit_time=%s",
-		licensePlate, rent.ParkingLotID, duration, totalCost, endTime.Format(time.RFC3339))
-
-	return &rent, nil // 關鍵！回傳完整 rent 紀錄
-}
-
-// GetRentRecordsByMemberID 查詢會員的所有租用紀錄
-func GetRentRecordsByMemberID(memberID int) ([]models.Rent, error) {
-	var rents []models.Rent
-
-	// 先查這個 member 的所有車牌
-	var vehiclePlates []string
-	if err := database.DB.
-		Model(&models.Vehicle{}).
-		Where("member_id = ?", memberID).
-		Pluck("license_plate", &vehiclePlates).Error; err != nil {
-		return nil, fmt.Errorf("failed to get vehicles for member %d: %w", memberID, err)
+	// 彙總失敗只回滾到儲存點並記錄，不影響出場；夜間重建會修正
+	if err := tx.Transaction(func(sp *gorm.DB) error {
+		return recordDailyStatTx(sp, rent)
+	}); err != nil {
+		log.Printf("DAILY_STAT_UPDATE_FAILED | parking_lot_id=%d license_plate=%s err=%v",
+			rent.ParkingLotID, rent.LicensePlate, err)
 	}
+	return nil
+}
 
-	if len(vehiclePlates) == 0 {
-		return []models.Rent{}, nil // 沒車就回空陣列，不算錯誤
-	}
+// afterCloseRent 出場寫入成功後記錄
+func afterCloseRent(rent *models.Rent) {
+	duration := rent.EndTime.Sub(rent.StartTime).Hours()
+	log.Printf("EXIT_SUCCESS | license_plate=%s parking_lot_id=%d duration_hours=%.2f cost=%.2f exit_time=%s",
+		rent.LicensePlate, rent.ParkingLotID, duration, *rent.TotalCost, rent.EndTime.Format(time.RFC3339))
+}
 
-	// 再查這些車牌的所有租賃紀錄
-	if err := database.DB.
-		Preload("ParkingLot").
-		Where("license_plate IN ?", vehiclePlates).
-		Order("start_time DESC").
-		Find(&rents).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch rent records: %w", err)
+// GetRentRecordsByMemberID 查詢會員的租用紀錄（進場當下屬於該會員的車輛，支援篩選與分頁）
+func GetRentRecordsByMemberID(memberID int, filter RentSearchFilter) ([]models.Rent, string, error) {
+	if memberID <= 0 {
+		return nil, "", fmt.Errorf("invalid member_id: %d", memberID)
 	}
-
-	return rents, nil
+	filter.MemberID = memberID
+	return SearchRentRecords(filter)
 }
 
 // CheckParkingAvailability 查詢特定停車場可用位子
 func CheckParkingAvailability(parkingLotID int) (int64, error) {
-	var totalSpots, parkingCount int64
+	var totalSpots int64
 
 	if err := database.DB.Model(&models.ParkingLot{}).
 		Where("parking_lot_id = ?", parkingLotID).
@@ -144,16 +196,13 @@ func CheckParkingAvailability(parkingLotID int) (int64, error) {
 		return 0, fmt.Errorf("failed to get total spots: %w", err)
 	}
 
-	if err := database.DB.Model(&models.Rent{}).
-		Where("parking_lot_id = ? AND end_time IS NULL", parkingLotID).
-		Count(&parkingCount).Error; err != nil {
-		return 0, fmt.Errorf("failed to count parked vehicles: %w", err)
+	counts, err := GetActiveCounts([]int{parkingLotID})
+	if err != nil {
+		return 0, err
 	}
+	parkingCount := int64(counts[parkingLotID])
 
-	available := totalSpots - parkingCount
-	if available < 0 {
-		available = 0
-	}
+	available := max(totalSpots-parkingCount, 0)
 
 	log.Printf("AVAILABILITY | parking_lot_id=%d total_spots=%d occupied=%d available=%d",
 		parkingLotID, totalSpots, parkingCount, available)
@@ -189,6 +238,8 @@ func GetCurrentlyRentedSpotsByMemberID(memberID int) ([]models.Rent, error) {
 
 // GetTotalCostByLicensePlate 計算車牌歷史總消費
 func GetTotalCostByLicensePlate(licensePlate string) (float64, error) {
+	licensePlate = utils.NormalizeLicensePlate(licensePlate)
+
 	var total float64
 
 	err := database.DB.Model(&models.Rent{}).
@@ -208,10 +259,9 @@ func GetTotalCostByLicensePlate(licensePlate string) (float64, error) {
 func GetTotalCostByMemberID(memberID int) (float64, error) {
 	var total float64
 
-	// 先查這個 member 擁有的所有車牌 → 再去 rent 表算總金額
+	// 依進場當下的車主加總，車輛過戶後過戶前的費用仍歸原車主
 	err := database.DB.Model(&models.Rent{}).
-		Joins("JOIN vehicle ON rent.license_plate = vehicle.license_plate").
-		Where("vehicle.member_id = ? AND rent.end_time IS NOT NULL", memberID).
+		Where("rent.member_id = ? AND rent.end_time IS NOT NULL", memberID).
 		Select("COALESCE(SUM(rent.total_cost), 0)").
 		Scan(&total).Error
 
@@ -219,6 +269,6 @@ func GetTotalCostByMemberID(memberID int) (float64, error) {
 		return 0, fmt.Errorf("failed to query total cost for member %d: %w", memberID, err)
 	}
 
-	log.Printf("MemberID %d total parking cost: %.0f TWD (via vehicle join)", memberID, total)
+	log.Printf("MemberID %d total parking cost: %.0f TWD", memberID, total)
 	return total, nil
 }
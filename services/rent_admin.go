@@ -0,0 +1,221 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RentKey 以車牌與進場時間識別一筆停車紀錄
+type RentKey struct {
+	LicensePlate string
+	StartTime    time.Time
+}
+
+// RentEdit 管理員修改停車紀錄的欄位（nil 代表不修改）
+type RentEdit struct {
+	StartTime *time.Time
+	EndTime   *time.Time
+}
+
+// RentAuditFilter 查詢稽核紀錄的篩選條件
+type RentAuditFilter struct {
+	LicensePlate string
+	Action       string
+	Limit        int
+}
+
+// ForceCloseRent 強制結束進行中的停車紀錄並依結束時間計價；已結束的紀錄請改用 EditRent 修正
+func ForceCloseRent(key RentKey, endTime time.Time, reason string, adminID int) (*models.Rent, error) {
+	return adminUpdateRent(key, "force_close", reason, adminID, func(tx *gorm.DB, rent *models.Rent) (map[string]interface{}, error) {
+		if rent.Voided {
+			return nil, fmt.Errorf("rent has been voided")
+		}
+		if rent.EndTime != nil {
+			return nil, fmt.Errorf("rent has already ended, use edit to correct its end time")
+		}
+		cost, err := CalculateRentCost(rent.StartTime, endTime, rent.ParkingLot)
+		if err != nil {
+			return nil, fmt.Errorf("fee calculation failed: %w", err)
+		}
+		rent.EndTime = &endTime
+		rent.TotalCost = &cost
+		return map[string]interface{}{"end_time": endTime, "total_cost": cost}, nil
+	})
+}
+
+// EditRent 修改停車紀錄的進出場時間，已結束的紀錄會重新計價
+func EditRent(key RentKey, edit RentEdit, reason string, adminID int) (*models.Rent, error) {
+	if edit.StartTime == nil && edit.EndTime == nil {
+		return nil, fmt.Errorf("no fields provided for update")
+	}
+
+	return adminUpdateRent(key, "edit", reason, adminID, func(tx *gorm.DB, rent *models.Rent) (map[string]interface{}, error) {
+		updates := make(map[string]interface{})
+		if edit.StartTime != nil {
+			rent.StartTime = *edit.StartTime
+			updates["start_time"] = *edit.StartTime
+		}
+		if edit.EndTime != nil {
+			rent.EndTime = edit.EndTime
+			updates["end_time"] = *edit.EndTime
+		}
+
+		if rent.EndTime != nil && !rent.Voided {
+			cost, err := CalculateRentCost(rent.StartTime, *rent.EndTime, rent.ParkingLot)
+			if err != nil {
+				return nil, fmt.Errorf("fee calculation failed: %w", err)
+			}
+			rent.TotalCost = &cost
+			updates["total_cost"] = cost
+		}
+		return updates, nil
+	})
+}
+
+// VoidRent 作廢停車紀錄（費用歸零、不計入收入），進行中的紀錄會一併結束
+func VoidRent(key RentKey, reason string, adminID int) (*models.Rent, error) {
+	return adminUpdateRent(key, "void", reason, adminID, func(tx *gorm.DB, rent *models.Rent) (map[string]interface{}, error) {
+		if rent.Voided {
+			return nil, fmt.Errorf("rent has already been voided")
+		}
+		zero := 0.0
+		updates := map[string]interface{}{"voided": true, "total_cost": zero}
+		if rent.EndTime == nil {
+			now := time.Now()
+			rent.EndTime = &now
+			updates["end_time"] = now
+		}
+		rent.Voided = true
+		rent.TotalCost = &zero
+		return updates, nil
+	})
+}
+
+// MoveRent 將停車紀錄移到另一個停車場，已結束的紀錄會依新費率重新計價
+func MoveRent(key RentKey, parkingLotID int, reason string, adminID int) (*models.Rent, error) {
+	return adminUpdateRent(key, "move", reason, adminID, func(tx *gorm.DB, rent *models.Rent) (map[string]interface{}, error) {
+		if rent.ParkingLotID == parkingLotID {
+			return nil, fmt.Errorf("rent is already in parking lot %d", parkingLotID)
+		}
+
+		var lot models.ParkingLot
+		if err := tx.First(&lot, parkingLotID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("parking lot %d not found", parkingLotID)
+			}
+			return nil, fmt.Errorf("failed to query parking lot %d: %w", parkingLotID, err)
+		}
+		if lot.IsArchived() {
+			return nil, fmt.Errorf("parking lot is archived: parking_lot_id=%d", parkingLotID)
+		}
+		rent.ParkingLotID = parkingLotID
+		rent.ParkingLot = lot
+		updates := map[string]interface{}{"parking_lot_id": parkingLotID}
+
+		if rent.EndTime != nil && !rent.Voided {
+			cost, err := CalculateRentCost(rent.StartTime, *rent.EndTime, lot)
+			if err != nil {
+				return nil, fmt.Errorf("fee calculation failed: %w", err)
+			}
+			rent.TotalCost = &cost
+			updates["total_cost"] = cost
+		}
+		return updates, nil
+	})
+}
+
+// adminUpdateRent 在交易中讀取紀錄、套用修改並寫入稽核軌跡
+func adminUpdateRent(key RentKey, action, reason string, adminID int,
+	apply func(tx *gorm.DB, rent *models.Rent) (map[string]interface{}, error)) (*models.Rent, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	key.LicensePlate = utils.NormalizeLicensePlate(key.LicensePlate)
+
+	tx := database.DB.Begin()
+
+	var rent models.Rent
+	if err := tx.Preload("ParkingLot").
+		Where("license_plate = ? AND start_time = ?", key.LicensePlate, key.StartTime).
+		First(&rent).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("rent not found: license_plate=%s start_time=%s", key.LicensePlate, key.StartTime.Format(time.RFC3339))
+		}
+		return nil, fmt.Errorf("failed to query rent: %w", err)
+	}
+	original := rent
+	before, _ := json.Marshal(rent.ToResponse())
+
+	updates, err := apply(tx, &rent)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if rent.EndTime != nil && rent.EndTime.Before(rent.StartTime) {
+		tx.Rollback()
+		return nil, fmt.Errorf("end_time cannot be earlier than start_time")
+	}
+
+	if err := tx.Model(&models.Rent{}).
+		Where("license_plate = ? AND start_time = ?", key.LicensePlate, key.StartTime).
+		Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update rent: %w", err)
+	}
+
+	after, _ := json.Marshal(rent.ToResponse())
+	audit := &models.RentAudit{
+		LicensePlate: key.LicensePlate,
+		StartTime:    key.StartTime,
+		Action:       action,
+		Reason:       reason,
+		Before:       string(before),
+		After:        string(after),
+		PerformedBy:  adminID,
+		CreatedAt:    time.Now(),
+	}
+	if err := tx.Create(audit).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to write rent audit: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit rent %s: %w", action, err)
+	}
+
+	refreshDailyStatsForRentChange(original, rent)
+
+	log.Printf("RENT_ADMIN | action=%s license_plate=%s start_time=%s admin=%d reason=%s",
+		action, key.LicensePlate, key.StartTime.Format(time.RFC3339), adminID, reason)
+	return &rent, nil
+}
+
+// GetRentAudits 查詢停車紀錄稽核軌跡
+func GetRentAudits(filter RentAuditFilter) ([]models.RentAudit, error) {
+	var audits []models.RentAudit
+
+	query := database.DB.Model(&models.RentAudit{})
+	if filter.LicensePlate != "" {
+		query = query.Where("license_plate = ?", utils.NormalizeLicensePlate(filter.LicensePlate))
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to query rent audits: %w", err)
+	}
+	return audits, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"strings"
+	"time"
+)
+
+// 租用紀錄狀態篩選
+const (
+	RentStatusActive    = "active"    // 進行中
+	RentStatusCompleted = "completed" // 已結束且未作廢
+	RentStatusVoided    = "voided"    // 已作廢
+)
+
+// 租用紀錄每頁筆數
+const (
+	DefaultRentPageSize = 50
+	MaxRentPageSize     = 200
+)
+
+// RentSearchFilter 租用紀錄查詢條件；依進場時間新到舊排序，同一時間再依車牌排序
+type RentSearchFilter struct {
+	MemberID     int // 進場當下的車主，0 代表不限會員（僅管理員）
+	LicensePlate string
+	ParkingLotID int
+	From         *time.Time // 進場時間下限（含）
+	To           *time.Time // 進場時間上限（含）
+	Status       string
+	Cursor       string // 上一頁回傳的 next_cursor
+	Limit        int
+}
+
+// rentCursor 分頁游標：最後一筆的主鍵（進場時間、車牌）
+type rentCursor struct {
+	StartTime    time.Time
+	LicensePlate string
+}
+
+// SearchRentRecords 依條件查詢租用紀錄並分頁，回傳本頁紀錄與下一頁游標（沒有下一頁時為空字串）
+func SearchRentRecords(filter RentSearchFilter) ([]models.Rent, string, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultRentPageSize
+	}
+	if filter.Limit > MaxRentPageSize {
+		filter.Limit = MaxRentPageSize
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return nil, "", fmt.Errorf("invalid date range: to is before from")
+	}
+
+	query := database.DB.Model(&models.Rent{})
+	if filter.MemberID > 0 {
+		query = query.Where("member_id = ?", filter.MemberID)
+	}
+	if filter.LicensePlate != "" {
+		query = query.Where("license_plate = ?", utils.NormalizeLicensePlate(filter.LicensePlate))
+	}
+	if filter.ParkingLotID > 0 {
+		query = query.Where("parking_lot_id = ?", filter.ParkingLotID)
+	}
+	if filter.From != nil {
+		query = query.Where("start_time >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("start_time <= ?", *filter.To)
+	}
+	switch filter.Status {
+	case "":
+	case RentStatusActive:
+		query = query.Where("end_time IS NULL")
+	case RentStatusCompleted:
+		query = query.Where("end_time IS NOT NULL AND voided = ?", false)
+	case RentStatusVoided:
+		query = query.Where("voided = ?", true)
+	default:
+		return nil, "", fmt.Errorf("invalid status: %s", filter.Status)
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeRentCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(start_time < ? OR (start_time = ? AND license_plate < ?))",
+			cursor.StartTime, cursor.StartTime, cursor.LicensePlate)
+	}
+
+	var rents []models.Rent
+	if err := query.
+		Order("start_time DESC, license_plate DESC").
+		Limit(filter.Limit + 1).
+		Find(&rents).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to search rent records: %w", err)
+	}
+
+	nextCursor := ""
+	if len(rents) > filter.Limit {
+		rents = rents[:filter.Limit]
+		last := rents[len(rents)-1]
+		nextCursor = encodeRentCursor(rentCursor{StartTime: last.StartTime, LicensePlate: last.LicensePlate})
+	}
+	return rents, nextCursor, nil
+}
+
+func encodeRentCursor(c rentCursor) string {
+	raw := c.StartTime.Format(time.RFC3339Nano) + "|" + c.LicensePlate
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRentCursor(s string) (rentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return rentCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return rentCursor{}, fmt.Errorf("invalid cursor")
+	}
+	startTime, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return rentCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return rentCursor{StartTime: startTime, LicensePlate: parts[1]}, nil
+}
@@ -0,0 +1,563 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"project01/database"
+	"project01/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// 定期報表的類型、期間與輸出方式
+const (
+	ReportTypeIncome      = "income"
+	ReportTypeRentHistory = "rent_history"
+
+	ReportPeriodPreviousDay   = "previous_day"
+	ReportPeriodPreviousWeek  = "previous_week"
+	ReportPeriodPreviousMonth = "previous_month"
+
+	ReportDestinationDirectory = "directory"
+	ReportDestinationEmail     = "email"
+)
+
+// DefaultReportOutputDir 報表根目錄（REPORT_OUTPUT_DIR 未設定時）
+const DefaultReportOutputDir = "reports"
+
+// ReportAttachment 報表郵件附件
+type ReportAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ReportEmail 報表郵件
+type ReportEmail struct {
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []ReportAttachment
+}
+
+// ReportMailer 寄送報表的介面，可替換為實際的郵件服務
+type ReportMailer interface {
+	SendReport(email ReportEmail) error
+}
+
+// LogReportMailer 以 log 記錄報表郵件（未設定郵件服務時使用）
+type LogReportMailer struct{}
+
+func (LogReportMailer) SendReport(email ReportEmail) error {
+	names := make([]string, len(email.Attachments))
+	for i, a := range email.Attachments {
+		names[i] = fmt.Sprintf("%s(%d bytes)", a.Filename, len(a.Data))
+	}
+	log.Printf("REPORT_MAIL | to=%s subject=%s attachments=%s",
+		strings.Join(email.To, ","), email.Subject, strings.Join(names, ","))
+	return nil
+}
+
+// reportMailer 目前使用的報表寄送器
+var reportMailer ReportMailer = LogReportMailer{}
+
+// SetReportMailer 設定報表寄送器
+func SetReportMailer(mailer ReportMailer) {
+	reportMailer = mailer
+}
+
+// reportOutputDir 讀取 REPORT_OUTPUT_DIR，未設定時使用預設值
+func reportOutputDir() string {
+	if dir := os.Getenv("REPORT_OUTPUT_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultReportOutputDir
+}
+
+// reportScheduler 已註冊到 cron 的排程
+var reportScheduler struct {
+	mu      sync.Mutex
+	cron    *cron.Cron
+	entries map[int]cron.EntryID
+	running map[int]bool
+}
+
+// InitReportScheduler 將所有啟用中的報表排程註冊到 cron
+func InitReportScheduler(c *cron.Cron) error {
+	reportScheduler.mu.Lock()
+	reportScheduler.cron = c
+	reportScheduler.entries = map[int]cron.EntryID{}
+	reportScheduler.running = map[int]bool{}
+	reportScheduler.mu.Unlock()
+
+	var schedules []models.ReportSchedule
+	if err := database.DB.Where("enabled = ?", true).Find(&schedules).Error; err != nil {
+		return fmt.Errorf("failed to load report schedules: %w", err)
+	}
+	for _, s := range schedules {
+		if err := registerReportSchedule(s); err != nil {
+			log.Printf("REPORT_SCHEDULE_SKIPPED | id=%d cron=%s err=%v", s.ID, s.CronSpec, err)
+		}
+	}
+	log.Printf("REPORT_SCHEDULER_STARTED | schedules=%d", len(schedules))
+	return nil
+}
+
+// registerReportSchedule 重新註冊排程（先移除舊的 cron 項目；停用的排程只移除）
+func registerReportSchedule(s models.ReportSchedule) error {
+	reportScheduler.mu.Lock()
+	defer reportScheduler.mu.Unlock()
+
+	if reportScheduler.cron == nil {
+		return nil
+	}
+	if entryID, ok := reportScheduler.entries[s.ID]; ok {
+		reportScheduler.cron.Remove(entryID)
+		delete(reportScheduler.entries, s.ID)
+	}
+	if !s.Enabled {
+		return nil
+	}
+
+	id := s.ID
+	entryID, err := reportScheduler.cron.AddFunc(s.CronSpec, func() {
+		if _, err := RunReportSchedule(id, "schedule", nil, false); err != nil {
+			log.Printf("REPORT_RUN_FAILED | schedule_id=%d err=%v", id, err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	reportScheduler.entries[s.ID] = entryID
+	return nil
+}
+
+// unregisterReportSchedule 移除排程的 cron 項目
+func unregisterReportSchedule(id int) {
+	reportScheduler.mu.Lock()
+	defer reportScheduler.mu.Unlock()
+
+	if entryID, ok := reportScheduler.entries[id]; ok && reportScheduler.cron != nil {
+		reportScheduler.cron.Remove(entryID)
+		delete(reportScheduler.entries, id)
+	}
+}
+
+// validateReportSchedule 檢查報表排程設定並正規化欄位
+func validateReportSchedule(s *models.ReportSchedule) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("invalid schedule: name is required")
+	}
+	switch s.ReportType {
+	case ReportTypeIncome, ReportTypeRentHistory:
+	default:
+		return fmt.Errorf("invalid report_type: %s", s.ReportType)
+	}
+	switch s.Period {
+	case ReportPeriodPreviousDay, ReportPeriodPreviousWeek, ReportPeriodPreviousMonth:
+	default:
+		return fmt.Errorf("invalid period: %s", s.Period)
+	}
+	if s.Format != ExportFormatCSV && s.Format != ExportFormatXLSX {
+		return fmt.Errorf("invalid format: %s", s.Format)
+	}
+	if _, err := cron.ParseStandard(s.CronSpec); err != nil {
+		return fmt.Errorf("invalid cron_spec %q: %v", s.CronSpec, err)
+	}
+
+	lotIDs, err := parseReportLotIDs(s.ParkingLotIDs)
+	if err != nil {
+		return err
+	}
+	if len(lotIDs) > 0 {
+		var count int64
+		if err := database.DB.Model(&models.ParkingLot{}).Where("parking_lot_id IN ?", lotIDs).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check parking lots: %w", err)
+		}
+		if int(count) != len(lotIDs) {
+			return fmt.Errorf("invalid parking_lot_ids: some parking lots not found")
+		}
+	}
+	s.ParkingLotIDs = joinLotIDs(lotIDs)
+
+	switch s.Destination {
+	case ReportDestinationDirectory:
+		// 只允許報表根目錄下的子目錄
+		target := filepath.Clean(strings.TrimSpace(s.Target))
+		if target == "." {
+			target = ""
+		}
+		if filepath.IsAbs(target) || target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid target: directory must be relative to the report output directory")
+		}
+		s.Target = target
+	case ReportDestinationEmail:
+		list, err := mail.ParseAddressList(s.Target)
+		if err != nil || len(list) == 0 {
+			return fmt.Errorf("invalid target: expected comma-separated email addresses")
+		}
+		addrs := make([]string, len(list))
+		for i, a := range list {
+			addrs[i] = a.Address
+		}
+		s.Target = strings.Join(addrs, ",")
+	default:
+		return fmt.Errorf("invalid destination: %s", s.Destination)
+	}
+	return nil
+}
+
+// parseReportLotIDs 解析排程中以逗號分隔的停車場 ID（去除重複）
+func parseReportLotIDs(s string) ([]int, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid parking_lot_ids: %q", part)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func joinLotIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// CreateReportSchedule 新增報表排程並註冊到 cron
+func CreateReportSchedule(s *models.ReportSchedule) error {
+	if err := validateReportSchedule(s); err != nil {
+		return err
+	}
+	if err := database.DB.Create(s).Error; err != nil {
+		return fmt.Errorf("failed to create report schedule: %w", err)
+	}
+	if err := registerReportSchedule(*s); err != nil {
+		return fmt.Errorf("failed to register report schedule: %w", err)
+	}
+
+	log.Printf("REPORT_SCHEDULE_CREATED | id=%d type=%s period=%s cron=%s destination=%s created_by=%d",
+		s.ID, s.ReportType, s.Period, s.CronSpec, s.Destination, s.CreatedBy)
+	return nil
+}
+
+// UpdateReportSchedule 以 input 取代排程的設定欄位並重新註冊
+func UpdateReportSchedule(id int, input models.ReportSchedule) (*models.ReportSchedule, error) {
+	s, err := GetReportSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Name = input.Name
+	s.ReportType = input.ReportType
+	s.ParkingLotIDs = input.ParkingLotIDs
+	s.SplitByLot = input.SplitByLot
+	s.Period = input.Period
+	s.Format = input.Format
+	s.CronSpec = input.CronSpec
+	s.Destination = input.Destination
+	s.Target = input.Target
+	s.Enabled = input.Enabled
+	if err := validateReportSchedule(s); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Save(s).Error; err != nil {
+		return nil, fmt.Errorf("failed to update report schedule %d: %w", id, err)
+	}
+	if err := registerReportSchedule(*s); err != nil {
+		return nil, fmt.Errorf("failed to register report schedule: %w", err)
+	}
+
+	log.Printf("REPORT_SCHEDULE_UPDATED | id=%d enabled=%t cron=%s", s.ID, s.Enabled, s.CronSpec)
+	return s, nil
+}
+
+// DeleteReportSchedule 刪除報表排程（保留執行紀錄）
+func DeleteReportSchedule(id int) error {
+	result := database.DB.Delete(&models.ReportSchedule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete report schedule %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("report schedule %d not found", id)
+	}
+	unregisterReportSchedule(id)
+
+	log.Printf("REPORT_SCHEDULE_DELETED | id=%d", id)
+	return nil
+}
+
+// GetReportSchedule 取得單一報表排程
+func GetReportSchedule(id int) (*models.ReportSchedule, error) {
+	var s models.ReportSchedule
+	if err := database.DB.First(&s, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("report schedule %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to get report schedule %d: %w", id, err)
+	}
+	return &s, nil
+}
+
+// GetReportSchedules 取得所有報表排程
+func GetReportSchedules() ([]models.ReportSchedule, error) {
+	var schedules []models.ReportSchedule
+	if err := database.DB.Order("id").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to query report schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetReportRuns 取得排程的執行紀錄（新到舊），scheduleID 為 0 時查詢全部
+func GetReportRuns(scheduleID int, limit int) ([]models.ReportRun, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	var runs []models.ReportRun
+	query := database.DB.Model(&models.ReportRun{})
+	if scheduleID > 0 {
+		query = query.Where("schedule_id = ?", scheduleID)
+	}
+	if err := query.Order("started_at DESC, id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to query report runs: %w", err)
+	}
+	return runs, nil
+}
+
+// reportPeriod 依執行時間計算報表期間（CST，結束日含當天）
+func reportPeriod(period string, now time.Time) (time.Time, time.Time) {
+	today := dayStart(now)
+	var start, end time.Time
+	switch period {
+	case ReportPeriodPreviousWeek:
+		// 上週一到上週日
+		thisMonday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		start, end = thisMonday.AddDate(0, 0, -7), thisMonday.AddDate(0, 0, -1)
+	case ReportPeriodPreviousMonth:
+		firstOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, cstZone)
+		start, end = firstOfMonth.AddDate(0, -1, 0), firstOfMonth.AddDate(0, 0, -1)
+	default:
+		start, end = today.AddDate(0, 0, -1), today.AddDate(0, 0, -1)
+	}
+	return start, end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+}
+
+// RunReportSchedule 執行一次報表排程並記錄結果
+// async 為 true 時建立執行紀錄後即返回，於背景產生報表
+func RunReportSchedule(id int, trigger string, triggeredBy *int, async bool) (*models.ReportRun, error) {
+	s, err := GetReportSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	reportScheduler.mu.Lock()
+	if reportScheduler.running == nil {
+		reportScheduler.running = map[int]bool{}
+	}
+	if reportScheduler.running[id] {
+		reportScheduler.mu.Unlock()
+		return nil, fmt.Errorf("report schedule %d is already running", id)
+	}
+	reportScheduler.running[id] = true
+	reportScheduler.mu.Unlock()
+
+	start, end := reportPeriod(s.Period, time.Now())
+	run := &models.ReportRun{
+		ScheduleID:  s.ID,
+		Trigger:     trigger,
+		Status:      "running",
+		PeriodStart: start,
+		PeriodEnd:   dayStart(end),
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+	}
+	if err := database.DB.Create(run).Error; err != nil {
+		reportScheduler.mu.Lock()
+		delete(reportScheduler.running, id)
+		reportScheduler.mu.Unlock()
+		return nil, fmt.Errorf("failed to create report run: %w", err)
+	}
+
+	execute := func() {
+		defer func() {
+			reportScheduler.mu.Lock()
+			delete(reportScheduler.running, id)
+			reportScheduler.mu.Unlock()
+		}()
+		executeReportRun(s, run, start, end)
+	}
+	if async {
+		// 背景執行會更新 run，回傳建立時的副本給呼叫端，避免同時讀寫
+		created := *run
+		go execute()
+		return &created, nil
+	}
+	execute()
+	return run, nil
+}
+
+// reportJob 單一輸出檔
+type reportJob struct {
+	filename string
+	lotIDs   []int
+}
+
+// executeReportRun 產生報表、輸出到目的地並更新執行紀錄
+func executeReportRun(s *models.ReportSchedule, run *models.ReportRun, start, end time.Time) {
+	jobs, err := buildReportJobs(s, start, end)
+
+	var files []string
+	var attachments []ReportAttachment
+	rows := 0
+	if err == nil {
+		for _, job := range jobs {
+			var n int
+			if s.Destination == ReportDestinationEmail {
+				var buf bytes.Buffer
+				if n, err = writeScheduledReport(&buf, s, job, start, end); err != nil {
+					break
+				}
+				attachments = append(attachments, ReportAttachment{
+					Filename:    job.filename,
+					ContentType: reportContentType(s.Format),
+					Data:        buf.Bytes(),
+				})
+			} else if n, err = writeReportFile(s, job, start, end); err != nil {
+				break
+			}
+			files = append(files, job.filename)
+			rows += n
+		}
+	}
+	if err == nil && s.Destination == ReportDestinationEmail {
+		err = reportMailer.SendReport(ReportEmail{
+			To:      strings.Split(s.Target, ","),
+			Subject: fmt.Sprintf("%s（%s ~ %s）", s.Name, start.Format("2006-01-02"), end.Format("2006-01-02")),
+			Body: fmt.Sprintf("附件為 %s 的報表，期間 %s 至 %s，共 %d 筆。",
+				s.Name, start.Format("2006-01-02"), end.Format("2006-01-02"), rows),
+			Attachments: attachments,
+		})
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Files = strings.Join(files, ",")
+	run.Rows = rows
+	run.Status = "succeeded"
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		if len(run.Error) > 500 {
+			run.Error = run.Error[:500]
+		}
+	}
+	if dbErr := database.DB.Save(run).Error; dbErr != nil {
+		log.Printf("Failed to save report run %d: %v", run.ID, dbErr)
+	}
+	if dbErr := database.DB.Model(&models.ReportSchedule{}).Where("id = ?", s.ID).
+		Update("last_run_at", finished).Error; dbErr != nil {
+		log.Printf("Failed to update report schedule %d: %v", s.ID, dbErr)
+	}
+
+	log.Printf("REPORT_RUN | schedule_id=%d run_id=%d trigger=%s status=%s files=%d rows=%d period=%s~%s err=%v",
+		s.ID, run.ID, run.Trigger, run.Status, len(files), rows,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), err)
+}
+
+// buildReportJobs 依排程決定要產生的檔案；分停車場時未指定停車場即為所有啟用中的停車場
+func buildReportJobs(s *models.ReportSchedule, start, end time.Time) ([]reportJob, error) {
+	lotIDs, err := parseReportLotIDs(s.ParkingLotIDs)
+	if err != nil {
+		return nil, err
+	}
+	period := start.Format("20060102") + "_" + end.Format("20060102")
+	ext := s.Format
+
+	if !s.SplitByLot {
+		scope := "all"
+		if len(lotIDs) > 0 {
+			scope = "lots" + strings.ReplaceAll(joinLotIDs(lotIDs), ",", "-")
+		}
+		return []reportJob{{filename: fmt.Sprintf("%s_%s_%s.%s", s.ReportType, scope, period, ext), lotIDs: lotIDs}}, nil
+	}
+
+	if len(lotIDs) == 0 {
+		if err := database.DB.Model(&models.ParkingLot{}).
+			Where("archived_at IS NULL").Order("parking_lot_id").
+			Pluck("parking_lot_id", &lotIDs).Error; err != nil {
+			return nil, fmt.Errorf("failed to query parking lots: %w", err)
+		}
+	}
+	jobs := make([]reportJob, len(lotIDs))
+	for i, id := range lotIDs {
+		jobs[i] = reportJob{filename: fmt.Sprintf("%s_lot%d_%s.%s", s.ReportType, id, period, ext), lotIDs: []int{id}}
+	}
+	return jobs, nil
+}
+
+// writeScheduledReport 將單一檔案的內容寫到 out
+func writeScheduledReport(out io.Writer, s *models.ReportSchedule, job reportJob, start, end time.Time) (int, error) {
+	filter := ExportFilter{Format: s.Format, ParkingLotIDs: job.lotIDs, StartDate: start, EndDate: end}
+	if s.ReportType == ReportTypeRentHistory {
+		return StreamRentHistoryExport(out, filter)
+	}
+	return StreamIncomeExport(out, filter)
+}
+
+// writeReportFile 寫入報表目錄；先寫暫存檔再改名，避免共用資料夾出現不完整的檔案
+func writeReportFile(s *models.ReportSchedule, job reportJob, start, end time.Time) (int, error) {
+	dir := filepath.Join(reportOutputDir(), s.Target)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".report-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create report file: %w", err)
+	}
+	n, err := writeScheduledReport(tmp, s, job, start, end)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, job.filename)); err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to save report file: %w", err)
+	}
+	return n, nil
+}
+
+func reportContentType(format string) string {
+	if format == ExportFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 簡訊寄送方式（SMS_DRIVER）
+const (
+	SMSDriverLog  = "log"
+	SMSDriverFile = "file"
+	SMSDriverHTTP = "http"
+)
+
+// DefaultSMSFileDir file 寄送方式的輸出目錄（SMS_FILE_DIR 未設定時）
+const DefaultSMSFileDir = "sms"
+
+// smsHTTPTimeout 呼叫簡訊閘道的逾時時間
+const smsHTTPTimeout = 10 * time.Second
+
+// SMSSender 寄送簡訊的介面，可替換為實際的簡訊服務
+type SMSSender interface {
+	SendSMS(phone, message string) error
+}
+
+// LogSMSSender 以 log 記錄簡訊（本機開發用）
+// 內文含驗證碼，只記錄長度；需要檢視內文時請改用 file driver
+type LogSMSSender struct{}
+
+func (LogSMSSender) SendSMS(phone, message string) error {
+	log.Printf("SMS | to=%s message_bytes=%d (message redacted)", phone, len(message))
+	return nil
+}
+
+// FileSMSSender 將簡訊以文字檔寫入目錄（本機測試用）
+type FileSMSSender struct {
+	Dir string
+}
+
+func (s FileSMSSender) SendSMS(phone, message string) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create sms directory: %w", err)
+	}
+	suffix, err := randomHex(4)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.Dir, time.Now().Format("20060102-150405.000000")+"-"+suffix+".txt")
+	if err := os.WriteFile(path, []byte("To: "+phone+"\n\n"+message+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to write sms file: %w", err)
+	}
+	log.Printf("SMS_FILE | to=%s path=%s", phone, path)
+	return nil
+}
+
+// HTTPSMSSender 以 JSON POST 到簡訊閘道：{"to": 電話, "message": 內文}
+// 設定 Token 時帶上 Authorization: Bearer 標頭，回應非 2xx 視為失敗
+type HTTPSMSSender struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (s HTTPSMSSender) SendSMS(phone, message string) error {
+	payload, err := json.Marshal(map[string]string{"to": phone, "message": message})
+	if err != nil {
+		return fmt.Errorf("failed to encode sms: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build sms request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: smsHTTPTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sms gateway returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	log.Printf("SMS_SENT | to=%s", phone)
+	return nil
+}
+
+// smsSender 目前使用的簡訊寄送器
+var smsSender SMSSender = LogSMSSender{}
+
+// SetSMSSender 設定簡訊寄送器
+func SetSMSSender(sender SMSSender) {
+	smsSender = sender
+}
+
+// InitSMSSender 依 SMS_DRIVER 設定簡訊寄送器（log／file／http，預設 log）
+func InitSMSSender() error {
+	switch driver := os.Getenv("SMS_DRIVER"); driver {
+	case "", SMSDriverLog:
+		// log driver 不會真的寄出簡訊，正式環境使用時會員收不到電話驗證碼
+		if os.Getenv("GIN_MODE") == "release" {
+			return fmt.Errorf("SMS_DRIVER=log is not allowed when GIN_MODE=release, set SMS_DRIVER=http")
+		}
+		SetSMSSender(LogSMSSender{})
+	case SMSDriverFile:
+		dir := os.Getenv("SMS_FILE_DIR")
+		if dir == "" {
+			dir = DefaultSMSFileDir
+		}
+		SetSMSSender(FileSMSSender{Dir: dir})
+	case SMSDriverHTTP:
+		url := os.Getenv("SMS_HTTP_URL")
+		if url == "" {
+			return fmt.Errorf("SMS_HTTP_URL is required when SMS_DRIVER=http")
+		}
+		SetSMSSender(HTTPSMSSender{URL: url, Token: os.Getenv("SMS_HTTP_TOKEN")})
+	default:
+		return fmt.Errorf("invalid SMS_DRIVER: %s", driver)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestInitSMSSender 依 SMS_DRIVER 選擇寄送器，正式環境不允許 log driver
+func TestInitSMSSender(t *testing.T) {
+	previous := smsSender
+	defer SetSMSSender(previous)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+		want    SMSSender
+	}{
+		{"default", map[string]string{"SMS_DRIVER": "", "GIN_MODE": ""}, false, LogSMSSender{}},
+		{"log in release", map[string]string{"SMS_DRIVER": "log", "GIN_MODE": "release"}, true, nil},
+		{"default in release", map[string]string{"SMS_DRIVER": "", "GIN_MODE": "release"}, true, nil},
+		{"file", map[string]string{"SMS_DRIVER": "file", "SMS_FILE_DIR": "out"}, false, FileSMSSender{Dir: "out"}},
+		{"http without url", map[string]string{"SMS_DRIVER": "http", "SMS_HTTP_URL": ""}, true, nil},
+		{"http", map[string]string{"SMS_DRIVER": "http", "SMS_HTTP_URL": "https://sms.example.com/send", "SMS_HTTP_TOKEN": "secret"},
+			false, HTTPSMSSender{URL: "https://sms.example.com/send", Token: "secret"}},
+		{"unknown", map[string]string{"SMS_DRIVER": "pigeon"}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			SetSMSSender(nil)
+			err := InitSMSSender()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitSMSSender() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && smsSender != tt.want {
+				t.Errorf("sender = %#v, want %#v", smsSender, tt.want)
+			}
+		})
+	}
+}
+
+// TestLogSMSSenderRedactsMessage log driver 不能把驗證碼寫進 log
+func TestLogSMSSenderRedactsMessage(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(previous)
+
+	if err := (LogSMSSender{}).SendSMS("0912345678", "您的驗證碼為 654321"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "654321") {
+		t.Errorf("log contains the message: %s", buf.String())
+	}
+}
+
+// TestHTTPSMSSender 以 JSON POST 到閘道，非 2xx 回應視為失敗
+func TestHTTPSMSSender(t *testing.T) {
+	var got map[string]string
+	var auth string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sender := HTTPSMSSender{URL: server.URL, Token: "secret"}
+	if err := sender.SendSMS("0912345678", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got["to"] != "0912345678" || got["message"] != "hello" {
+		t.Errorf("payload = %v", got)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	status = http.StatusBadGateway
+	if err := sender.SendSMS("0912345678", "hello"); err == nil {
+		t.Error("non-2xx response treated as success")
+	}
+}
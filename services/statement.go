@@ -0,0 +1,368 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StatementSession 月結單中的一筆停車紀錄
+type StatementSession struct {
+	ParkingLotID    int     `json:"parking_lot_id"`
+	Address         string  `json:"address"`
+	LicensePlate    string  `json:"license_plate"`
+	StartTime       string  `json:"start_time"`
+	EndTime         string  `json:"end_time"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Cost            float64 `json:"cost"`
+}
+
+// StatementVehicle 月結單中單一車輛的小計
+type StatementVehicle struct {
+	LicensePlate string  `json:"license_plate"`
+	Sessions     int     `json:"sessions"`
+	TotalMinutes int     `json:"total_minutes"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+// StatementTotals 月結單合計
+type StatementTotals struct {
+	Sessions     int     `json:"sessions"`
+	TotalMinutes int     `json:"total_minutes"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+// Statement 會員月結單（依出場時間歸屬月份，不含作廢紀錄）
+type Statement struct {
+	MemberID    int                `json:"member_id"`
+	MemberName  string             `json:"member_name"`
+	Month       string             `json:"month"`
+	PeriodStart string             `json:"period_start"`
+	PeriodEnd   string             `json:"period_end"`
+	GeneratedAt string             `json:"generated_at"`
+	Final       bool               `json:"final"` // 月份已結束且已保存；當月為暫定
+	Sessions    []StatementSession `json:"sessions"`
+	Vehicles    []StatementVehicle `json:"vehicles"`
+	Totals      StatementTotals    `json:"totals"`
+}
+
+// parseStatementMonth 解析 YYYY-MM（CST），回傳該月第一天與下個月第一天
+func parseStatementMonth(month string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, cstZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// GetMemberStatement 取得會員某月的月結單
+// 已結束的月份由每月 1 日的排程保存（排程前查詢時則當下產生並保存），之後回傳保存的版本（regenerate 為 true 時重新產生）；當月只產生暫定版本不保存
+func GetMemberStatement(memberID int, month string, regenerate bool) (*Statement, error) {
+	start, end, err := parseStatementMonth(month)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if start.After(now) {
+		return nil, fmt.Errorf("invalid month %s: month has not started", month)
+	}
+	final := !end.After(now)
+
+	if final && !regenerate {
+		var stored models.MemberStatement
+		err := database.DB.Where("member_id = ? AND month = ?", memberID, month).First(&stored).Error
+		if err == nil {
+			var st Statement
+			if err := json.Unmarshal([]byte(stored.Data), &st); err != nil {
+				return nil, fmt.Errorf("failed to decode statement %d: %w", stored.ID, err)
+			}
+			return &st, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to query statement: %w", err)
+		}
+	}
+
+	st, err := buildStatement(memberID, month, start, end, now)
+	if err != nil {
+		return nil, err
+	}
+	if !final {
+		return st, nil
+	}
+
+	st.Final = true
+	data, err := json.Marshal(st)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode statement: %w", err)
+	}
+	record := models.MemberStatement{
+		MemberID:    memberID,
+		Month:       month,
+		Sessions:    st.Totals.Sessions,
+		TotalCost:   st.Totals.TotalCost,
+		Data:        string(data),
+		GeneratedAt: now,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("member_id = ? AND month = ?", memberID, month).Delete(&models.MemberStatement{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save statement: %w", err)
+	}
+
+	log.Printf("STATEMENT_GENERATED | member_id=%d month=%s sessions=%d total_cost=%.2f regenerate=%t",
+		memberID, month, st.Totals.Sessions, st.Totals.TotalCost, regenerate)
+	return st, nil
+}
+
+// RunMonthlyStatementSnapshot 保存上個月有停車紀錄的會員的月結單（供每月 1 日的 cron 呼叫）
+// 只包含該月有出場且未作廢紀錄的會員；已保存的月結單不重新產生，避免事後的修正改變已開立的內容
+func RunMonthlyStatementSnapshot() {
+	now := time.Now().In(cstZone)
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, cstZone)
+	start := end.AddDate(0, -1, 0)
+	month := start.Format("2006-01")
+
+	var memberIDs []int
+	err := database.DB.Model(&models.Rent{}).
+		Distinct("member_id").
+		Where("member_id IS NOT NULL AND voided = ? AND end_time >= ? AND end_time < ?", false, start, end).
+		Where("member_id NOT IN (SELECT member_id FROM member_statement WHERE month = ?)", month).
+		Pluck("member_id", &memberIDs).Error
+	if err != nil {
+		log.Printf("Failed to load members for statement snapshot %s: %v", month, err)
+		return
+	}
+
+	failed := 0
+	for _, id := range memberIDs {
+		if _, err := GetMemberStatement(id, month, false); err != nil {
+			log.Printf("Failed to snapshot statement %s for member %d: %v", month, id, err)
+			failed++
+		}
+	}
+	log.Printf("STATEMENT_SNAPSHOT | month=%s members=%d failed=%d", month, len(memberIDs), failed)
+}
+
+// GetMemberStatements 列出會員已保存的月結單（新到舊）
+func GetMemberStatements(memberID int) ([]models.MemberStatement, error) {
+	var statements []models.MemberStatement
+	if err := database.DB.Omit("data").
+		Where("member_id = ?", memberID).
+		Order("month DESC").
+		Find(&statements).Error; err != nil {
+		return nil, fmt.Errorf("failed to query statements: %w", err)
+	}
+	return statements, nil
+}
+
+// buildStatement 由 rent 產生月結單：進場當下屬於該會員的車輛在該月出場、未作廢的紀錄
+// 車輛過戶後，過戶前的紀錄仍歸原車主
+func buildStatement(memberID int, month string, start, end, now time.Time) (*Statement, error) {
+	var member models.Member
+	if err := database.DB.First(&member, memberID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("member %d not found", memberID)
+		}
+		return nil, fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+
+	var rows []struct {
+		LicensePlate string
+		ParkingLotID int
+		Address      string
+		StartTime    time.Time
+		EndTime      time.Time
+		TotalCost    *float64
+	}
+	err := database.DB.Table("rent").
+		Select("rent.license_plate, rent.parking_lot_id, COALESCE(parking_lot.address, '') AS address, "+
+			"rent.start_time, rent.end_time, rent.total_cost").
+		Joins("LEFT JOIN parking_lot ON parking_lot.parking_lot_id = rent.parking_lot_id").
+		Where("rent.member_id = ?", memberID).
+		Where("rent.voided = ? AND rent.end_time >= ? AND rent.end_time < ?", false, start, end).
+		Order("rent.end_time, rent.license_plate").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query statement sessions: %w", err)
+	}
+
+	st := &Statement{
+		MemberID:    memberID,
+		MemberName:  member.Name,
+		Month:       month,
+		PeriodStart: start.Format("2006-01-02"),
+		PeriodEnd:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		GeneratedAt: now.Format(time.RFC3339),
+		Sessions:    make([]StatementSession, len(rows)),
+		Vehicles:    []StatementVehicle{},
+	}
+
+	byPlate := map[string]*StatementVehicle{}
+	var totalCost float64
+	for i, r := range rows {
+		cost := 0.0
+		if r.TotalCost != nil {
+			cost = *r.TotalCost
+		}
+		minutes := int(math.Ceil(r.EndTime.Sub(r.StartTime).Minutes()))
+		st.Sessions[i] = StatementSession{
+			ParkingLotID:    r.ParkingLotID,
+			Address:         r.Address,
+			LicensePlate:    r.LicensePlate,
+			StartTime:       r.StartTime.Format("2006-01-02 15:04"),
+			EndTime:         r.EndTime.Format("2006-01-02 15:04"),
+			DurationMinutes: minutes,
+			Cost:            cost,
+		}
+
+		v := byPlate[r.LicensePlate]
+		if v == nil {
+			v = &StatementVehicle{LicensePlate: r.LicensePlate}
+			byPlate[r.LicensePlate] = v
+		}
+		v.Sessions++
+		v.TotalMinutes += minutes
+		v.TotalCost += cost
+
+		st.Totals.Sessions++
+		st.Totals.TotalMinutes += minutes
+		totalCost += cost
+	}
+	for _, v := range byPlate {
+		v.TotalCost = math.Round(v.TotalCost*100) / 100
+		st.Vehicles = append(st.Vehicles, *v)
+	}
+	sort.Slice(st.Vehicles, func(i, j int) bool { return st.Vehicles[i].LicensePlate < st.Vehicles[j].LicensePlate })
+	st.Totals.TotalCost = math.Round(totalCost*100) / 100
+
+	return st, nil
+}
+
+// WriteStatementCSV 以 UTF-8 BOM 的 CSV 輸出月結單：明細、各車輛小計、合計
+func WriteStatementCSV(w io.Writer, st *Statement) error {
+	buf := bufio.NewWriter(w)
+	if _, err := buf.WriteString("\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(buf)
+
+	records := [][]string{
+		{"會員", st.MemberName, "會員 ID", strconv.Itoa(st.MemberID)},
+		{"月份", st.Month, "期間", st.PeriodStart + " ~ " + st.PeriodEnd},
+		{},
+		{"進場時間", "出場時間", "車牌", "停車場 ID", "停車場地址", "停車分鐘數", "費用"},
+	}
+	for _, s := range st.Sessions {
+		records = append(records, []string{s.StartTime, s.EndTime, s.LicensePlate, strconv.Itoa(s.ParkingLotID),
+			s.Address, strconv.Itoa(s.DurationMinutes), fmt.Sprintf("%.2f", s.Cost)})
+	}
+	records = append(records, []string{}, []string{"車牌", "次數", "停車分鐘數", "費用小計"})
+	for _, v := range st.Vehicles {
+		records = append(records, []string{v.LicensePlate, strconv.Itoa(v.Sessions),
+			strconv.Itoa(v.TotalMinutes), fmt.Sprintf("%.2f", v.TotalCost)})
+	}
+	records = append(records, []string{"合計", strconv.Itoa(st.Totals.Sessions),
+		strconv.Itoa(st.Totals.TotalMinutes), fmt.Sprintf("%.2f", st.Totals.TotalCost)})
+
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// WriteStatementPDF 以 PDF 輸出月結單
+func WriteStatementPDF(w io.Writer, st *Statement) error {
+	const (
+		left       = 40.0
+		rowHeight  = 14.0
+		bottom     = 800.0
+		fontSize   = 9.0
+		addressMax = 17
+	)
+	columns := []struct {
+		x     float64
+		title string
+	}{
+		{left, "進場時間"}, {left + 80, "出場時間"}, {left + 160, "車牌"},
+		{left + 220, "停車場"}, {left + 385, "分鐘"}, {left + 435, "費用"},
+	}
+
+	doc := utils.NewPDFDocument()
+	y := 0.0
+	header := func() {
+		doc.AddPage()
+		doc.Text(left, 50, 16, "停車月結單 "+st.Month)
+		doc.Text(left, 72, 10, fmt.Sprintf("會員：%s（ID %d）", st.MemberName, st.MemberID))
+		doc.Text(left, 86, 10, fmt.Sprintf("期間：%s ~ %s", st.PeriodStart, st.PeriodEnd))
+		doc.Text(utils.PDFPageWidth-80, 86, 8, fmt.Sprintf("頁 %d", doc.PageCount()))
+		for _, col := range columns {
+			doc.Text(col.x, 112, fontSize, col.title)
+		}
+		doc.Line(left, 116, utils.PDFPageWidth-left, 116)
+		y = 130
+	}
+	ensure := func(lines int) {
+		if y+float64(lines)*rowHeight > bottom {
+			header()
+		}
+	}
+
+	header()
+	if len(st.Sessions) == 0 {
+		doc.Text(left, y, fontSize, "本月無停車紀錄")
+		y += rowHeight
+	}
+	for _, s := range st.Sessions {
+		ensure(1)
+		address := []rune(s.Address)
+		if len(address) > addressMax {
+			address = append(address[:addressMax-1], '…')
+		}
+		cells := []string{s.StartTime, s.EndTime, s.LicensePlate, string(address),
+			strconv.Itoa(s.DurationMinutes), fmt.Sprintf("%.2f", s.Cost)}
+		for i, col := range columns {
+			doc.Text(col.x, y, fontSize, cells[i])
+		}
+		y += rowHeight
+	}
+
+	// 小計標題至少與第一輛車同頁，之後逐行換頁，車輛多時不會超出頁尾
+	ensure(3)
+	y += rowHeight / 2
+	doc.Line(left, y-10, utils.PDFPageWidth-left, y-10)
+	doc.Text(left, y+4, 10, "各車輛小計")
+	y += rowHeight + 4
+	for _, v := range st.Vehicles {
+		ensure(1)
+		doc.Text(left, y, fontSize, fmt.Sprintf("%s　%d 次　%d 分鐘　%.2f 元",
+			v.LicensePlate, v.Sessions, v.TotalMinutes, v.TotalCost))
+		y += rowHeight
+	}
+	ensure(2)
+	doc.Text(left, y+6, 11, fmt.Sprintf("合計：%d 次，%d 分鐘，%.2f 元",
+		st.Totals.Sessions, st.Totals.TotalMinutes, st.Totals.TotalCost))
+	if !st.Final {
+		doc.Text(left, y+24, 8, "本月尚未結束，此為暫定月結單")
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
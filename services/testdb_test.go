@@ -0,0 +1,53 @@
+package services
+
+import (
+	"os"
+	"project01/database"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	testDBOnce sync.Once
+	testDB     *gorm.DB
+	testDBErr  error
+)
+
+// setupTestDB 連線到 TEST_DB_DSN 指定的 MySQL 並建立、清空指定資料表，未設定時略過測試
+// 測試會清空資料表，TEST_DB_DSN 須指向專用的測試資料庫，例如
+// user:pass@tcp(127.0.0.1:3306)/parking_test?charset=utf8mb4&parseTime=True&loc=Asia%2FShanghai
+func setupTestDB(tb testing.TB, tables ...interface{}) {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_DB_DSN not set")
+	}
+
+	testDBOnce.Do(func() {
+		testDB, testDBErr = gorm.Open(mysql.Open(dsn), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+			NamingStrategy: schema.NamingStrategy{
+				SingularTable: true,
+			},
+			DisableForeignKeyConstraintWhenMigrating: true,
+		})
+	})
+	if testDBErr != nil {
+		tb.Fatalf("failed to open test database: %v", testDBErr)
+	}
+	database.DB = testDB
+
+	if err := testDB.AutoMigrate(tables...); err != nil {
+		tb.Fatalf("failed to migrate test tables: %v", err)
+	}
+	for _, table := range tables {
+		if err := testDB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(table).Error; err != nil {
+			tb.Fatalf("failed to clear test table: %v", err)
+		}
+	}
+}
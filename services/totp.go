@@ -0,0 +1,584 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 兩步驟驗證參數
+const (
+	DefaultTOTPIssuer       = "Parking"
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	totpRecoveryCodeCount   = 10
+	totpAllowedSkew         = 1 // 允許前後各一個時間區段（±30 秒）的時鐘誤差
+
+	// 會員層級的鎖定：連續失敗達門檻後暫停驗證，之後每次失敗鎖定時間加倍
+	mfaLockoutThreshold = 5
+	mfaLockoutBase      = time.Minute
+	mfaLockoutMax       = 24 * time.Hour
+)
+
+// TOTPEnrollment 開始設定時回傳的金鑰與 otpauth URI（可轉成 QR code 給驗證器 App 掃描）
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TOTPStatus 會員的兩步驟驗證狀態
+type TOTPStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// LoginChallenge 密碼正確但仍需第二步驟時回傳；SetupRequired 表示政策要求但尚未設定兩步驟驗證
+type LoginChallenge struct {
+	MFARequired   bool      `json:"mfa_required"`
+	MFAToken      string    `json:"mfa_token"`
+	ExpiresAt     time.Time `json:"mfa_expires_at"`
+	SetupRequired bool      `json:"setup_required"`
+}
+
+// totpIssuer 讀取 TOTP_ISSUER，未設定時使用預設值
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return DefaultTOTPIssuer
+}
+
+// AdminTOTPRequired 是否強制管理員使用兩步驟驗證（ADMIN_2FA_REQUIRED）
+func AdminTOTPRequired() bool {
+	required, _ := strconv.ParseBool(os.Getenv("ADMIN_2FA_REQUIRED"))
+	return required
+}
+
+// totpRequiredFor 該角色是否必須使用兩步驟驗證
+func totpRequiredFor(role string) bool {
+	return role == "admin" && AdminTOTPRequired()
+}
+
+// getMemberTOTP 取得會員的 TOTP 設定，不存在時回傳 nil
+func getMemberTOTP(memberID int) (*models.MemberTOTP, error) {
+	var totp models.MemberTOTP
+	result := database.DB.Where("member_id = ?", memberID).Limit(1).Find(&totp)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get 2fa settings: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &totp, nil
+}
+
+// GetTOTPStatus 查詢會員的兩步驟驗證狀態
+func GetTOTPStatus(memberID int) (*TOTPStatus, error) {
+	var member models.Member
+	if err := database.DB.First(&member, memberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("member %d not found", memberID)
+		}
+		return nil, fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	totp, err := getMemberTOTP(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TOTPStatus{
+		Enabled:  totp != nil && totp.ConfirmedAt != nil,
+		Required: totpRequiredFor(member.Role),
+	}
+	if status.Enabled {
+		if err := database.DB.Model(&models.TOTPRecoveryCode{}).
+			Where("member_id = ? AND used_at IS NULL", memberID).
+			Count(&status.RecoveryCodesRemaining).Error; err != nil {
+			return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// BeginTOTPEnrollment 產生新的 TOTP 金鑰；需以 ConfirmTOTPEnrollment 輸入一次驗證碼後才會啟用
+func BeginTOTPEnrollment(memberID int) (*TOTPEnrollment, error) {
+	var member models.Member
+	if err := database.DB.First(&member, memberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("member %d not found", memberID)
+		}
+		return nil, fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	existing, err := getMemberTOTP(memberID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ConfirmedAt != nil {
+		return nil, fmt.Errorf("2fa already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptPaymentInfo(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	// 重新開始設定時，取代尚未確認的金鑰
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("member_id = ?", memberID).Delete(&models.MemberTOTP{}).Error; err != nil {
+			return fmt.Errorf("failed to reset 2fa enrollment: %w", err)
+		}
+		if err := tx.Create(&models.MemberTOTP{MemberID: memberID, Secret: encrypted}).Error; err != nil {
+			return fmt.Errorf("failed to save 2fa enrollment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account := member.Email
+	if account == "" {
+		account = member.Phone
+	}
+	log.Printf("TOTP_ENROLL_STARTED | member_id=%d", memberID)
+	return &TOTPEnrollment{Secret: secret, URI: utils.TOTPURI(totpIssuer(), account, secret)}, nil
+}
+
+// ConfirmTOTPEnrollment 以驗證碼確認金鑰並啟用兩步驟驗證，回傳備用碼（只會顯示這一次）
+func ConfirmTOTPEnrollment(memberID int, code string) ([]string, error) {
+	totp, err := getMemberTOTP(memberID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, fmt.Errorf("2fa enrollment not started")
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, fmt.Errorf("2fa already enabled")
+	}
+
+	secret, err := utils.DecryptPaymentInfo(totp.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), totpAllowedSkew)
+	if !ok {
+		return nil, fmt.Errorf("invalid 2fa code")
+	}
+
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.MemberTOTP{}).
+			Where("member_id = ? AND confirmed_at IS NULL", memberID).
+			Updates(map[string]interface{}{"confirmed_at": now, "last_used_step": step})
+		if result.Error != nil {
+			return fmt.Errorf("failed to enable 2fa: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("2fa already enabled")
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, memberID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("TOTP_ENABLED | member_id=%d", memberID)
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes 以目前的驗證碼重新產生備用碼，舊的備用碼全部失效
+func RegenerateRecoveryCodes(memberID int, code string) ([]string, error) {
+	if err := verifySecondFactorWithLockout(memberID, code, ""); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, memberID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("TOTP_RECOVERY_CODES_REGENERATED | member_id=%d", memberID)
+	return codes, nil
+}
+
+// DisableTOTP 以驗證碼或備用碼停用兩步驟驗證；政策要求使用的角色不可停用
+func DisableTOTP(memberID int, code, recoveryCode string) error {
+	var member models.Member
+	if err := database.DB.First(&member, memberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("member %d not found", memberID)
+		}
+		return fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	if totpRequiredFor(member.Role) {
+		return fmt.Errorf("2fa is required by policy")
+	}
+	if err := verifySecondFactorWithLockout(memberID, code, recoveryCode); err != nil {
+		return err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("member_id = ?", memberID).Delete(&models.MemberTOTP{}).Error; err != nil {
+			return fmt.Errorf("failed to disable 2fa: %w", err)
+		}
+		if err := tx.Where("member_id = ?", memberID).Delete(&models.TOTPRecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("TOTP_DISABLED | member_id=%d", memberID)
+	return nil
+}
+
+// replaceRecoveryCodes 刪除舊備用碼並產生新的一組
+func replaceRecoveryCodes(tx *gorm.DB, memberID int) ([]string, error) {
+	if err := tx.Where("member_id = ?", memberID).Delete(&models.TOTPRecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, totpRecoveryCodeCount)
+	records := make([]models.TOTPRecoveryCode, totpRecoveryCodeCount)
+	for i := range codes {
+		raw, err := randomHex(5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		records[i] = models.TOTPRecoveryCode{MemberID: memberID, CodeHash: recoveryCodeHash(memberID, codes[i])}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// recoveryCodeHash 備用碼雜湊；忽略大小寫、空白與連字號
+func recoveryCodeHash(memberID int, code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	return hashToken(fmt.Sprintf("%d:%s", memberID, normalized))
+}
+
+// verifyTOTPCode 驗證已啟用的 TOTP；同一時間區段的驗證碼只能使用一次
+func verifyTOTPCode(memberID int, code string) error {
+	totp, err := getMemberTOTP(memberID)
+	if err != nil {
+		return err
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		return fmt.Errorf("2fa not enabled")
+	}
+
+	secret, err := utils.DecryptPaymentInfo(totp.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), totpAllowedSkew)
+	if !ok {
+		return fmt.Errorf("invalid 2fa code")
+	}
+
+	result := database.DB.Model(&models.MemberTOTP{}).
+		Where("member_id = ? AND last_used_step < ?", memberID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record 2fa usage: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("invalid 2fa code: code already used")
+	}
+	return nil
+}
+
+// useRecoveryCode 使用一組備用碼
+func useRecoveryCode(memberID int, code string) error {
+	result := database.DB.Model(&models.TOTPRecoveryCode{}).
+		Where("member_id = ? AND code_hash = ? AND used_at IS NULL", memberID, recoveryCodeHash(memberID, code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("invalid 2fa code")
+	}
+	log.Printf("TOTP_RECOVERY_CODE_USED | member_id=%d", memberID)
+	return nil
+}
+
+// verifySecondFactor 驗證 TOTP 驗證碼或備用碼（擇一）
+func verifySecondFactor(memberID int, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		totp, err := getMemberTOTP(memberID)
+		if err != nil {
+			return err
+		}
+		if totp == nil || totp.ConfirmedAt == nil {
+			return fmt.Errorf("2fa not enabled")
+		}
+		return useRecoveryCode(memberID, recoveryCode)
+	}
+	return verifyTOTPCode(memberID, code)
+}
+
+// verifySecondFactorWithLockout 套用會員層級鎖定的 verifySecondFactor：
+// 鎖定期間不驗證，驗證碼錯誤時累計失敗次數，成功時清除
+func verifySecondFactorWithLockout(memberID int, code, recoveryCode string) error {
+	if err := checkMFALock(memberID); err != nil {
+		return err
+	}
+	if err := verifySecondFactor(memberID, code, recoveryCode); err != nil {
+		if strings.Contains(err.Error(), "invalid 2fa code") {
+			recordMFAFailure(memberID)
+		}
+		return err
+	}
+	resetMFAFailures(memberID)
+	return nil
+}
+
+// StartSecondFactor 密碼驗證通過後呼叫；已啟用兩步驟驗證或政策要求時建立登入挑戰，否則回傳 nil
+func StartSecondFactor(member *models.Member) (*LoginChallenge, error) {
+	totp, err := getMemberTOTP(member.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := totp != nil && totp.ConfirmedAt != nil
+	if !enabled && !totpRequiredFor(member.Role) {
+		return nil, nil
+	}
+
+	plain, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	challenge := models.MFAChallenge{
+		MemberID:  member.MemberID,
+		TokenHash: hashToken(plain),
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if err := database.DB.Create(&challenge).Error; err != nil {
+		return nil, fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+
+	log.Printf("MFA_CHALLENGE_CREATED | member_id=%d setup_required=%t", member.MemberID, !enabled)
+	return &LoginChallenge{
+		MFARequired:   true,
+		MFAToken:      plain,
+		ExpiresAt:     challenge.ExpiresAt,
+		SetupRequired: !enabled,
+	}, nil
+}
+
+// loadMFAChallenge 取得仍有效的登入挑戰
+func loadMFAChallenge(mfaToken string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	result := database.DB.Where("token_hash = ?", hashToken(mfaToken)).Limit(1).Find(&challenge)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get mfa challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || challenge.UsedAt != nil ||
+		time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= mfaChallengeMaxAttempts {
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+	return &challenge, nil
+}
+
+// checkMFALock 會員因連續驗證失敗而鎖定時回傳錯誤
+func checkMFALock(memberID int) error {
+	totp, err := getMemberTOTP(memberID)
+	if err != nil {
+		return err
+	}
+	if totp != nil && totp.LockedUntil != nil && time.Now().Before(*totp.LockedUntil) {
+		return fmt.Errorf("2fa temporarily locked until %s", totp.LockedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// reserveMFAAttempt 驗證前先檢查會員是否鎖定，並以條件式更新保留挑戰的一次嘗試
+// 並行的請求也無法超過挑戰的次數上限
+func reserveMFAAttempt(challenge *models.MFAChallenge) error {
+	if err := checkMFALock(challenge.MemberID); err != nil {
+		return err
+	}
+
+	result := database.DB.Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL AND attempts < ?", challenge.ID, mfaChallengeMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to record mfa attempt: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("invalid or expired mfa token")
+	}
+	return nil
+}
+
+// recordMFAFailure 累計會員的連續失敗次數，達門檻後鎖定並逐次加倍鎖定時間
+// 次數跨登入挑戰累計，重新登入取得新挑戰也無法重置
+func recordMFAFailure(memberID int) {
+	var failures int
+	var lockedUntil *time.Time
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var totp models.MemberTOTP
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("member_id = ?", memberID).Limit(1).Find(&totp)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		failures = totp.FailedAttempts + 1
+		updates := map[string]interface{}{"failed_attempts": failures}
+		if failures >= mfaLockoutThreshold {
+			lockout := mfaLockoutMax
+			if shift := failures - mfaLockoutThreshold; shift < 20 {
+				lockout = min(mfaLockoutBase<<shift, mfaLockoutMax)
+			}
+			until := time.Now().Add(lockout)
+			lockedUntil = &until
+			updates["locked_until"] = until
+		}
+		return tx.Model(&models.MemberTOTP{}).Where("member_id = ?", memberID).Updates(updates).Error
+	})
+	if err != nil {
+		log.Printf("Failed to record mfa failure for member %d: %v", memberID, err)
+		return
+	}
+	if lockedUntil != nil {
+		log.Printf("MFA_LOCKED | member_id=%d failures=%d until=%s", memberID, failures, lockedUntil.Format(time.RFC3339))
+		return
+	}
+	log.Printf("MFA_FAILED | member_id=%d failures=%d", memberID, failures)
+}
+
+// resetMFAFailures 驗證成功後清除失敗次數與鎖定
+func resetMFAFailures(memberID int) {
+	if err := database.DB.Model(&models.MemberTOTP{}).
+		Where("member_id = ? AND (failed_attempts > 0 OR locked_until IS NOT NULL)", memberID).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error; err != nil {
+		log.Printf("Failed to reset mfa failures for member %d: %v", memberID, err)
+	}
+}
+
+// consumeMFAChallenge 將登入挑戰標記為已使用並簽發 token
+func consumeMFAChallenge(challenge *models.MFAChallenge, userAgent, ipAddress string) (*TokenPair, *models.Member, error) {
+	result := database.DB.Model(&models.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("failed to consume mfa challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	var member models.Member
+	if err := database.DB.First(&member, challenge.MemberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, fmt.Errorf("invalid or expired mfa token")
+		}
+		return nil, nil, fmt.Errorf("failed to get member %d: %w", challenge.MemberID, err)
+	}
+	tokens, err := IssueTokenPair(&member, userAgent, ipAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, &member, nil
+}
+
+// CompleteSecondFactor 以驗證碼或備用碼完成登入第二步驟
+func CompleteSecondFactor(mfaToken, code, recoveryCode, userAgent, ipAddress string) (*TokenPair, *models.Member, error) {
+	challenge, err := loadMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := reserveMFAAttempt(challenge); err != nil {
+		return nil, nil, err
+	}
+	if err := verifySecondFactorWithLockout(challenge.MemberID, code, recoveryCode); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, member, err := consumeMFAChallenge(challenge, userAgent, ipAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("MFA_LOGIN_SUCCESS | member_id=%d", member.MemberID)
+	return tokens, member, nil
+}
+
+// BeginChallengeEnrollment 政策要求但尚未設定時，以登入挑戰開始設定兩步驟驗證
+func BeginChallengeEnrollment(mfaToken string) (*TOTPEnrollment, error) {
+	challenge, err := loadMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return BeginTOTPEnrollment(challenge.MemberID)
+}
+
+// CompleteChallengeEnrollment 以登入挑戰確認兩步驟驗證設定並完成登入，同時回傳備用碼
+func CompleteChallengeEnrollment(mfaToken, code, userAgent, ipAddress string) (*TokenPair, []string, *models.Member, error) {
+	challenge, err := loadMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := reserveMFAAttempt(challenge); err != nil {
+		return nil, nil, nil, err
+	}
+	codes, err := ConfirmTOTPEnrollment(challenge.MemberID, code)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid 2fa code") {
+			recordMFAFailure(challenge.MemberID)
+		}
+		return nil, nil, nil, err
+	}
+	resetMFAFailures(challenge.MemberID)
+
+	tokens, member, err := consumeMFAChallenge(challenge, userAgent, ipAddress)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	log.Printf("MFA_LOGIN_SUCCESS | member_id=%d enrolled=true", member.MemberID)
+	return tokens, codes, member, nil
+}
+
+// requireSecondFactorPolicy 政策要求兩步驟驗證但會員尚未設定時回傳錯誤（換發 token 時使用）
+func requireSecondFactorPolicy(member *models.Member) error {
+	if !totpRequiredFor(member.Role) {
+		return nil
+	}
+	totp, err := getMemberTOTP(member.MemberID)
+	if err != nil {
+		return err
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		return fmt.Errorf("2fa setup required")
+	}
+	return nil
+}
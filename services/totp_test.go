@@ -0,0 +1,131 @@
+package services
+
+import (
+	"project01/database"
+	"project01/models"
+	"project01/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedTOTP 為會員建立已啟用的兩步驟驗證，回傳金鑰
+func seedTOTP(t *testing.T, memberID int) string {
+	t.Helper()
+	if len(utils.AESKey) == 0 {
+		utils.AESKey = []byte("test-aes-key-0123456789abcdefghi")
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := utils.EncryptPaymentInfo(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := database.DB.Create(&models.MemberTOTP{MemberID: memberID, Secret: encrypted, ConfirmedAt: &now}).Error; err != nil {
+		t.Fatalf("failed to seed totp: %v", err)
+	}
+	return secret
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// wrongTOTPCode 不在允許誤差範圍內的驗證碼
+func wrongTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	step := utils.TOTPStep(time.Now())
+	for _, candidate := range []string{"000000", "111111", "222222", "333333"} {
+		if _, ok := utils.ValidateTOTP(secret, candidate, time.Now(), totpAllowedSkew); !ok {
+			return candidate
+		}
+	}
+	t.Fatalf("no wrong code found for step %d", step)
+	return ""
+}
+
+// TestTOTPManagementLockout 停用與重新產生備用碼也適用連續失敗鎖定
+func TestTOTPManagementLockout(t *testing.T) {
+	setupTestDB(t, &models.Member{}, &models.MemberTOTP{}, &models.TOTPRecoveryCode{})
+	member := seedMember(t, 31, "renter")
+	secret := seedTOTP(t, member.MemberID)
+	wrong := wrongTOTPCode(t, secret)
+
+	for i := 0; i < mfaLockoutThreshold; i++ {
+		var err error
+		if i%2 == 0 {
+			err = DisableTOTP(member.MemberID, wrong, "")
+		} else {
+			_, err = RegenerateRecoveryCodes(member.MemberID, wrong)
+		}
+		if err == nil || !strings.Contains(err.Error(), "invalid 2fa code") {
+			t.Fatalf("attempt %d: err = %v, want invalid 2fa code", i+1, err)
+		}
+	}
+
+	code := currentTOTPCode(t, secret)
+	if _, err := RegenerateRecoveryCodes(member.MemberID, code); err == nil || !strings.Contains(err.Error(), "temporarily locked") {
+		t.Errorf("RegenerateRecoveryCodes while locked: err = %v", err)
+	}
+	if err := DisableTOTP(member.MemberID, code, ""); err == nil || !strings.Contains(err.Error(), "temporarily locked") {
+		t.Errorf("DisableTOTP while locked: err = %v", err)
+	}
+	if err := DisableTOTP(member.MemberID, "", "any-recovery-code"); err == nil || !strings.Contains(err.Error(), "temporarily locked") {
+		t.Errorf("DisableTOTP with recovery code while locked: err = %v", err)
+	}
+
+	totp, err := getMemberTOTP(member.MemberID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		t.Fatal("2fa disabled while locked")
+	}
+	if totp.FailedAttempts != mfaLockoutThreshold {
+		t.Errorf("failed_attempts = %d, want %d", totp.FailedAttempts, mfaLockoutThreshold)
+	}
+}
+
+// TestTOTPManagementResetsFailures 驗證成功後清除累計的失敗次數
+func TestTOTPManagementResetsFailures(t *testing.T) {
+	setupTestDB(t, &models.Member{}, &models.MemberTOTP{}, &models.TOTPRecoveryCode{})
+	member := seedMember(t, 32, "renter")
+	secret := seedTOTP(t, member.MemberID)
+	wrong := wrongTOTPCode(t, secret)
+
+	for i := 0; i < mfaLockoutThreshold-1; i++ {
+		if _, err := RegenerateRecoveryCodes(member.MemberID, wrong); err == nil {
+			t.Fatal("wrong code accepted")
+		}
+	}
+	codes, err := RegenerateRecoveryCodes(member.MemberID, currentTOTPCode(t, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != totpRecoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(codes), totpRecoveryCodeCount)
+	}
+
+	totp, err := getMemberTOTP(member.MemberID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totp.FailedAttempts != 0 || totp.LockedUntil != nil {
+		t.Errorf("failures not reset: failed_attempts=%d locked_until=%v", totp.FailedAttempts, totp.LockedUntil)
+	}
+
+	if err := DisableTOTP(member.MemberID, "", codes[0]); err != nil {
+		t.Fatalf("DisableTOTP with recovery code: %v", err)
+	}
+	if totp, err := getMemberTOTP(member.MemberID); err != nil || totp != nil {
+		t.Errorf("2fa still enabled after disable: %v %v", totp, err)
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"project01/database"
+	"project01/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 驗證管道
+const (
+	VerificationChannelEmail = "email"
+	VerificationChannelPhone = "phone"
+)
+
+// 驗證碼限制：重寄間隔、每小時上限、每組驗證碼可嘗試次數
+const (
+	DefaultVerificationCodeMinutes = 10
+	verificationResendCooldown     = time.Minute
+	verificationHourlyLimit        = 5
+	verificationMaxAttempts        = 5
+)
+
+// verificationCodeTTL 讀取 VERIFICATION_CODE_MINUTES，未設定時使用預設值
+func verificationCodeTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("VERIFICATION_CODE_MINUTES")); err == nil && v > 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return DefaultVerificationCodeMinutes * time.Minute
+}
+
+// verificationTarget 取得會員在該管道的電子郵件或電話，以及是否已驗證
+func verificationTarget(member models.Member, channel string) (string, bool, error) {
+	switch channel {
+	case VerificationChannelEmail:
+		return member.Email, member.EmailVerifiedAt != nil, nil
+	case VerificationChannelPhone:
+		return member.Phone, member.PhoneVerifiedAt != nil, nil
+	default:
+		return "", false, fmt.Errorf("invalid channel: %s", channel)
+	}
+}
+
+// verificationCodeHash 驗證碼只有 6 位數，雜湊時加入會員與管道，避免相同驗證碼有相同雜湊
+func verificationCodeHash(memberID int, channel, code string) string {
+	return hashToken(fmt.Sprintf("%d:%s:%s", memberID, channel, code))
+}
+
+// generateVerificationCode 產生 6 位數驗證碼
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate verification code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// SendVerificationCode 寄送驗證碼到會員的電子郵件或電話；舊的驗證碼立即失效
+func SendVerificationCode(memberID int, channel string) error {
+	var member models.Member
+	if err := database.DB.First(&member, memberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("member %d not found", memberID)
+		}
+		return fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	target, verified, err := verificationTarget(member, channel)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return fmt.Errorf("invalid channel: member has no %s", channel)
+	}
+	if verified {
+		return fmt.Errorf("%s already verified", channel)
+	}
+
+	now := time.Now()
+	var recent []models.VerificationCode
+	if err := database.DB.
+		Where("member_id = ? AND channel = ? AND created_at > ?", memberID, channel, now.Add(-time.Hour)).
+		Order("created_at DESC").
+		Find(&recent).Error; err != nil {
+		return fmt.Errorf("failed to check verification codes: %w", err)
+	}
+	if len(recent) >= verificationHourlyLimit ||
+		(len(recent) > 0 && now.Sub(recent[0].CreatedAt) < verificationResendCooldown) {
+		return fmt.Errorf("verification code requested too frequently")
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return err
+	}
+	ttl := verificationCodeTTL()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.VerificationCode{}).
+			Where("member_id = ? AND channel = ? AND consumed_at IS NULL", memberID, channel).
+			Update("consumed_at", now).Error; err != nil {
+			return fmt.Errorf("failed to invalidate previous codes: %w", err)
+		}
+		record := models.VerificationCode{
+			MemberID:  memberID,
+			Channel:   channel,
+			Target:    target,
+			CodeHash:  verificationCodeHash(memberID, channel, code),
+			ExpiresAt: now.Add(ttl),
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to save verification code: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("您的驗證碼為 %s，%d 分鐘內有效。請勿將驗證碼提供給他人。", code, int(ttl.Minutes()))
+	if channel == VerificationChannelEmail {
+		err = mailer.Send(MailMessage{To: []string{target}, Subject: "電子郵件驗證碼", Body: message})
+	} else {
+		err = smsSender.SendSMS(target, message)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	log.Printf("VERIFICATION_CODE_SENT | member_id=%d channel=%s", memberID, channel)
+	return nil
+}
+
+// SendVerificationCodesAsync 在背景寄送指定管道的驗證碼（註冊或變更聯絡方式後使用）
+func SendVerificationCodesAsync(memberID int, channels ...string) {
+	go func() {
+		for _, channel := range channels {
+			if err := SendVerificationCode(memberID, channel); err != nil {
+				log.Printf("Failed to send %s verification code to member %d: %v", channel, memberID, err)
+			}
+		}
+	}()
+}
+
+// ConfirmVerificationCode 以驗證碼完成電子郵件或電話驗證
+// 驗證碼只對寄送當下的電子郵件／電話有效，期間變更過聯絡方式則需重新申請
+func ConfirmVerificationCode(memberID int, channel, code string) error {
+	var member models.Member
+	if err := database.DB.First(&member, memberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("member %d not found", memberID)
+		}
+		return fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	target, verified, err := verificationTarget(member, channel)
+	if err != nil {
+		return err
+	}
+	if verified {
+		return fmt.Errorf("%s already verified", channel)
+	}
+
+	now := time.Now()
+	var record models.VerificationCode
+	result := database.DB.
+		Where("member_id = ? AND channel = ? AND consumed_at IS NULL", memberID, channel).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to get verification code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || record.Target != target || now.After(record.ExpiresAt) {
+		return fmt.Errorf("invalid or expired verification code")
+	}
+
+	// 比對前先以條件式更新保留一次嘗試，並行的請求也無法超過次數上限
+	reserved := database.DB.Model(&models.VerificationCode{}).
+		Where("id = ? AND attempts < ?", record.ID, verificationMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if reserved.Error != nil {
+		return fmt.Errorf("failed to record verification attempt: %w", reserved.Error)
+	}
+	if reserved.RowsAffected == 0 {
+		return fmt.Errorf("invalid or expired verification code")
+	}
+
+	expected := verificationCodeHash(memberID, channel, code)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(record.CodeHash)) != 1 {
+		return fmt.Errorf("invalid verification code")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		consumed := tx.Model(&models.VerificationCode{}).
+			Where("id = ? AND consumed_at IS NULL", record.ID).
+			Update("consumed_at", now)
+		if consumed.Error != nil {
+			return fmt.Errorf("failed to consume verification code: %w", consumed.Error)
+		}
+		if consumed.RowsAffected == 0 {
+			return fmt.Errorf("invalid or expired verification code")
+		}
+		// 只有電子郵件／電話仍與寄送當下相同時才標記為已驗證
+		verifiedColumn := channel + "_verified_at"
+		updated := tx.Model(&models.Member{}).
+			Where("member_id = ? AND "+channel+" = ?", memberID, target).
+			Update(verifiedColumn, now)
+		if updated.Error != nil {
+			return fmt.Errorf("failed to mark %s verified: %w", channel, updated.Error)
+		}
+		if updated.RowsAffected == 0 {
+			return fmt.Errorf("invalid or expired verification code")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("VERIFICATION_COMPLETED | member_id=%d channel=%s", memberID, channel)
+	return nil
+}
+
+// RequireVerifiedMember 確認會員的電子郵件與電話都已驗證
+func RequireVerifiedMember(memberID int) error {
+	var member models.Member
+	if err := database.DB.Select("member_id, email_verified_at, phone_verified_at").First(&member, memberID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("member %d not found", memberID)
+		}
+		return fmt.Errorf("failed to get member %d: %w", memberID, err)
+	}
+	if !member.IsVerified() {
+		return fmt.Errorf("member not verified: member_id=%d", memberID)
+	}
+	return nil
+}
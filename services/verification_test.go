@@ -0,0 +1,86 @@
+package services
+
+import (
+	"project01/database"
+	"project01/models"
+	"regexp"
+	"testing"
+)
+
+// captureSMSSender 記錄最後一封簡訊，供測試取出驗證碼
+type captureSMSSender struct {
+	phone, message string
+}
+
+func (s *captureSMSSender) SendSMS(phone, message string) error {
+	s.phone, s.message = phone, message
+	return nil
+}
+
+// sendPhoneCode 寄送電話驗證碼並回傳簡訊中的驗證碼
+func sendPhoneCode(t *testing.T, memberID int) string {
+	t.Helper()
+	capture := &captureSMSSender{}
+	previous := smsSender
+	SetSMSSender(capture)
+	defer SetSMSSender(previous)
+
+	if err := SendVerificationCode(memberID, VerificationChannelPhone); err != nil {
+		t.Fatal(err)
+	}
+	code := regexp.MustCompile(`\d{6}`).FindString(capture.message)
+	if code == "" {
+		t.Fatalf("no code in sms %q", capture.message)
+	}
+	return code
+}
+
+// TestVerificationCodeAttemptLimit 錯誤次數達上限後，即使輸入正確的驗證碼也不能完成驗證
+func TestVerificationCodeAttemptLimit(t *testing.T) {
+	setupTestDB(t, &models.Member{}, &models.VerificationCode{})
+	member := seedMember(t, 21, "renter")
+
+	code := sendPhoneCode(t, member.MemberID)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "000001"
+	}
+	for i := 0; i < verificationMaxAttempts; i++ {
+		if err := ConfirmVerificationCode(member.MemberID, VerificationChannelPhone, wrong); err == nil {
+			t.Fatalf("attempt %d: wrong code accepted", i+1)
+		}
+	}
+	if err := ConfirmVerificationCode(member.MemberID, VerificationChannelPhone, code); err == nil {
+		t.Fatal("correct code accepted after the attempt limit")
+	}
+
+	var stored models.Member
+	if err := database.DB.First(&stored, member.MemberID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.PhoneVerifiedAt != nil {
+		t.Error("phone marked verified after the attempt limit")
+	}
+}
+
+// TestVerificationCodeConfirm 正確的驗證碼完成驗證，且只能使用一次
+func TestVerificationCodeConfirm(t *testing.T) {
+	setupTestDB(t, &models.Member{}, &models.VerificationCode{})
+	member := seedMember(t, 22, "renter")
+
+	code := sendPhoneCode(t, member.MemberID)
+	if err := ConfirmVerificationCode(member.MemberID, VerificationChannelPhone, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfirmVerificationCode(member.MemberID, VerificationChannelPhone, code); err == nil {
+		t.Error("verification code accepted twice")
+	}
+
+	var stored models.Member
+	if err := database.DB.First(&stored, member.MemberID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.PhoneVerifiedAt == nil {
+		t.Error("phone not marked verified")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 頁面尺寸（點）
+const (
+	PDFPageWidth  = 595.0
+	PDFPageHeight = 842.0
+)
+
+// PDFDocument 簡易 PDF 產生器，只支援文字與直線
+// 文字使用 PDF 閱讀器內建的 MSung-Light（Adobe-CNS1）字型，不需嵌入字型即可顯示中文
+type PDFDocument struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+}
+
+// NewPDFDocument 建立空白文件
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{}
+}
+
+// AddPage 新增一頁，之後的繪製都在這一頁
+func (d *PDFDocument) AddPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+}
+
+// PageCount 目前頁數
+func (d *PDFDocument) PageCount() int {
+	return len(d.pages)
+}
+
+// Text 在 (x, y) 寫入文字，y 由頁面上緣起算
+func (d *PDFDocument) Text(x, y, size float64, text string) {
+	if d.page == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.page, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, PDFPageHeight-y, pdfUCS2Hex(text))
+}
+
+// Line 由 (x1, y1) 畫直線到 (x2, y2)，y 由頁面上緣起算
+func (d *PDFDocument) Line(x1, y1, x2, y2 float64) {
+	if d.page == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+// WriteTo 輸出完整的 PDF
+func (d *PDFDocument) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// 物件編號：1 目錄、2 頁面樹、3 字型、4 CID 字型、5 字型描述，其後每頁各佔頁面與內容兩個物件
+	var objects []string
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 頁面樹於下方填入
+		"<< /Type /Font /Subtype /Type0 /BaseFont /MSung-Light-UniCNS-UCS2-H /Encoding /UniCNS-UCS2-H /DescendantFonts [4 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /MSung-Light "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (CNS1) /Supplement 0 >> "+
+			"/FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /MSung-Light /Flags 6 /FontBBox [-160 -249 1015 888] "+
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	)
+
+	kids := make([]string, len(d.pages))
+	for i, page := range d.pages {
+		pageObj := len(objects) + 1
+		contentObj := pageObj + 1
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+				"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", PDFPageWidth, PDFPageHeight, contentObj),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// pdfUCS2Hex 將文字轉為 UCS-2 大端序十六進位字串；超出基本平面的字元以 ? 取代
+func pdfUCS2Hex(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// PlateFormatRule 車牌格式規則（以正規化後的車牌比對）
+type PlateFormatRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// regionPlateRules 各地區預設車牌格式
+var regionPlateRules = map[string][]PlateFormatRule{
+	"TW": {
+		{Name: "car", Pattern: regexp.MustCompile(`^[A-Z]{3}[0-9]{4}$`)},            // 新式自用小客車 ABC-1234
+		{Name: "car_legacy", Pattern: regexp.MustCompile(`^[A-Z0-9]{2}[0-9]{4}$`)},  // 舊式小客車 AB-1234 / 1A-2345
+		{Name: "car_legacy", Pattern: regexp.MustCompile(`^[0-9]{4}[A-Z0-9]{2}$`)},  // 舊式小客車 1234-AB
+		{Name: "scooter", Pattern: regexp.MustCompile(`^[A-Z]{3}[0-9]{3}$`)},        // 新式機車 ABC-123
+		{Name: "scooter_legacy", Pattern: regexp.MustCompile(`^[0-9]{3}[A-Z]{3}$`)}, // 舊式機車 123-ABC
+	},
+}
+
+// PlateRules 目前啟用的車牌格式規則
+var PlateRules []PlateFormatRule
+
+// InitPlateRules 依 PLATE_REGION 載入車牌規則，PLATE_FORMAT_PATTERNS 可追加自訂規則
+// PLATE_FORMAT_PATTERNS 格式：name=regex;name=regex
+func InitPlateRules() error {
+	region := strings.ToUpper(os.Getenv("PLATE_REGION"))
+	if region == "" {
+		region = "TW"
+	}
+
+	rules, ok := regionPlateRules[region]
+	if !ok {
+		return fmt.Errorf("unsupported PLATE_REGION: %s", region)
+	}
+	PlateRules = append([]PlateFormatRule{}, rules...)
+
+	if extra := os.Getenv("PLATE_FORMAT_PATTERNS"); extra != "" {
+		for _, item := range strings.Split(extra, ";") {
+			name, expr, found := strings.Cut(strings.TrimSpace(item), "=")
+			if !found || name == "" || expr == "" {
+				return fmt.Errorf("invalid PLATE_FORMAT_PATTERNS entry: %q", item)
+			}
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("invalid pattern for plate rule %s: %w", name, err)
+			}
+			PlateRules = append(PlateRules, PlateFormatRule{Name: name, Pattern: pattern})
+		}
+	}
+
+	log.Printf("Plate format rules loaded: region=%s rules=%d", region, len(PlateRules))
+	return nil
+}
+
+// NormalizeLicensePlate 將車牌轉為標準格式：全形轉半形、轉大寫、去除空白與連字號
+// 例如 "abc-1234"、"ABC 1234"、"ＡＢＣ１２３４" 都會變成 "ABC1234"
+func NormalizeLicensePlate(raw string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		// 全形英數字 (U+FF01 ~ U+FF5E) 轉半形
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// ValidateLicensePlate 正規化車牌並檢查是否符合任一格式規則，回傳正規化後的車牌
+func ValidateLicensePlate(raw string) (string, error) {
+	plate := NormalizeLicensePlate(raw)
+	if plate == "" {
+		return "", fmt.Errorf("license_plate is empty")
+	}
+
+	rules := PlateRules
+	if rules == nil {
+		rules = regionPlateRules["TW"]
+	}
+	for _, rule := range rules {
+		if rule.Pattern.MatchString(plate) {
+			return plate, nil
+		}
+	}
+	return "", fmt.Errorf("invalid license_plate format: %s", raw)
+}
+
+// confusablePlateChars ANPR 容易誤判的字元組
+var confusablePlateChars = map[[2]rune]bool{}
+
+func init() {
+	pairs := []string{"8B", "0D", "0O", "DO", "1I", "5S", "2Z", "6G", "QO", "Q0", "4A"}
+	for _, p := range pairs {
+		r := []rune(p)
+		confusablePlateChars[[2]rune{r[0], r[1]}] = true
+		confusablePlateChars[[2]rune{r[1], r[0]}] = true
+	}
+}
+
+// PlateDistance 計算兩個已正規化車牌的編輯距離
+// 易混淆字元（如 8/B、0/D）的替換成本為 0.5，其餘插入、刪除、替換皆為 1
+func PlateDistance(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]float64, len(rb)+1)
+	curr := make([]float64, len(rb)+1)
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = float64(i)
+		for j := 1; j <= len(rb); j++ {
+			subCost := 1.0
+			if ra[i-1] == rb[j-1] {
+				subCost = 0
+			} else if confusablePlateChars[[2]rune{ra[i-1], rb[j-1]}] {
+				subCost = 0.5
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+subCost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附錄 B 的 SHA-1 金鑰 "12345678901234567890"（base32）
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestTOTPCodeRFC6238 RFC 6238 附錄 B 的 SHA-1 測試向量（8 位數取末 6 位）
+func TestTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(t=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+// TestValidateTOTPSkew 只接受前後 skew 個時間區段內的驗證碼，並回傳相符的區段
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(current), 1, current, true},
+		{"previous step", code(current - 1), 1, current - 1, true},
+		{"next step", code(current + 1), 1, current + 1, true},
+		{"two steps behind", code(current - 2), 1, 0, false},
+		{"two steps ahead", code(current + 2), 1, 0, false},
+		{"previous step without skew", code(current - 1), 0, 0, false},
+		{"surrounding spaces", " " + code(current) + " ", 1, current, true},
+		{"wrong length", code(current)[:5], 1, 0, false},
+		{"not a code", "abcdef", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP() = (%d, %t), want (%d, %t)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := ValidateTOTP("not base32!", code(current), now, 1); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+// TestTOTPURI URI 須能被解析，且帶有驗證器 App 需要的參數
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Parking Lot", "user@example.com", rfc6238Secret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("invalid uri %q: %v", uri, err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("uri = %q, want otpauth://totp/...", uri)
+	}
+	if u.Path != "/Parking Lot:user@example.com" {
+		t.Errorf("label = %q", u.Path)
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "Parking Lot",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+}
+
+// TestGenerateTOTPSecret 產生的金鑰可解碼為 160 位元
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("key length = %d, want 20", len(key))
+	}
+}